@@ -0,0 +1,75 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command static-lb-ccm is a cloud-controller-manager that runs only the service controller with the static-lb
+// provider. Run it with --cloud-provider=static-lb and optionally --cloud-config=<path to the provider config>.
+package main
+
+import (
+	"os"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/cloud-provider/app"
+	"k8s.io/cloud-provider/app/config"
+	"k8s.io/cloud-provider/names"
+	"k8s.io/cloud-provider/options"
+	"k8s.io/component-base/cli"
+	cliflag "k8s.io/component-base/cli/flag"
+	_ "k8s.io/component-base/logs/json/register"          // register optional JSON log format
+	_ "k8s.io/component-base/metrics/prometheus/clientgo" // load all the prometheus client-go plugins
+	_ "k8s.io/component-base/metrics/prometheus/version"  // for version metric registration
+	"k8s.io/klog/v2"
+
+	_ "github.com/isac322/static-lb/pkg/cloudprovider" // register the static-lb provider
+)
+
+func main() {
+	ccmOptions, err := options.NewCloudControllerManagerOptions()
+	if err != nil {
+		klog.Fatalf("unable to initialize command options: %v", err)
+	}
+
+	command := app.NewCloudControllerManagerCommand(
+		ccmOptions,
+		cloudInitializer,
+		controllerInitializers(),
+		names.CCMControllerAliases(),
+		cliflag.NamedFlagSets{},
+		wait.NeverStop,
+	)
+	os.Exit(cli.Run(command))
+}
+
+// controllerInitializers keeps only the service controller, since the provider implements nothing but LoadBalancer.
+func controllerInitializers() map[string]app.ControllerInitFuncConstructor {
+	constructor := app.DefaultInitFuncConstructors[names.ServiceLBController]
+	constructor.InitContext.ClientName = "static-lb-service-controller"
+	return map[string]app.ControllerInitFuncConstructor{names.ServiceLBController: constructor}
+}
+
+func cloudInitializer(cfg *config.CompletedConfig) cloudprovider.Interface {
+	cloudConfig := cfg.ComponentConfig.KubeCloudShared.CloudProvider
+
+	cloud, err := cloudprovider.InitCloudProvider(cloudConfig.Name, cloudConfig.CloudConfigFile)
+	if err != nil {
+		klog.Fatalf("cloud provider could not be initialized: %v", err)
+	}
+	if cloud == nil {
+		klog.Fatalf("cloud provider %q is not registered", cloudConfig.Name)
+	}
+	return cloud
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/isac322/static-lb/internal/application"
+	"github.com/isac322/static-lb/internal/pkg/endpointslice"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+type GatewayLister interface {
+	ListBackedBy(ctx context.Context, svcKey types.NamespacedName) (gatewayv1.GatewayList, error)
+}
+
+// GatewayReconciler reconciles a Gateway object of GatewayClassName
+type GatewayReconciler struct {
+	client.Client
+	Scheme           *runtime.Scheme
+	Usecase          application.Usecase
+	Gateways         GatewayLister
+	GatewayClassName gatewayv1.ObjectName
+	Options          ReconcileOptions
+	// Shard limits reconciles to Gateways of the local shard. Every Gateway is reconciled if nil.
+	Shard ShardFilter
+}
+
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gateways,verbs=get;list;watch
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gateways/status,verbs=update
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=referencegrants,verbs=get;list;watch
+
+// Reconcile assigns node addresses to status.addresses of the Gateway.
+func (r *GatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	logger.WithValues("gateway", req.NamespacedName)
+
+	var gw gatewayv1.Gateway
+	if err := r.Get(ctx, req.NamespacedName, &gw); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			logger.Error(err, "unable to fetch Gateway")
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !r.isManaged(gw) {
+		return ctrl.Result{}, nil
+	}
+
+	if err := r.Usecase.AssignGatewayAddresses(ctx, gw); err != nil {
+		logger.Error(err, "unable to assign addresses to Gateway")
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GatewayReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	bldr := ctrl.NewControllerManagedBy(mgr).
+		WithOptions(r.Options.controllerOptions()).
+		For(
+			&gatewayv1.Gateway{},
+			builder.WithPredicates(predicate.NewPredicateFuncs(func(object client.Object) bool {
+				gw, ok := object.(*gatewayv1.Gateway)
+				return ok && r.isManaged(*gw)
+			})),
+		).
+		Watches(
+			&corev1.Service{},
+			handler.EnqueueRequestsFromMapFunc(r.findGatewaysByService),
+		).
+		Watches(
+			&discoveryv1.EndpointSlice{},
+			handler.EnqueueRequestsFromMapFunc(r.findGatewaysByEndpointSlice),
+		).
+		Watches(
+			&corev1.Node{},
+			handler.EnqueueRequestsFromMapFunc(r.findAllGateways),
+			builder.WithPredicates(nodeChangedPredicate),
+		).
+		Watches(
+			&gatewayv1beta1.ReferenceGrant{},
+			handler.EnqueueRequestsFromMapFunc(r.findAllGateways),
+		)
+
+	shardSource, err := shardChangeSource(mgr, r.Shard, r.listManagedGateways)
+	if err != nil {
+		return err
+	}
+	if shardSource != nil {
+		bldr = bldr.WatchesRawSource(shardSource, &handler.EnqueueRequestForObject{})
+	}
+
+	return bldr.Complete(r)
+}
+
+// isManaged reports whether gw is of GatewayClassName and belongs to the local shard.
+func (r *GatewayReconciler) isManaged(gw gatewayv1.Gateway) bool {
+	return gw.Spec.GatewayClassName == r.GatewayClassName && owns(r.Shard, client.ObjectKeyFromObject(&gw))
+}
+
+func (r *GatewayReconciler) listManagedGateways(ctx context.Context) ([]client.Object, error) {
+	var gateways gatewayv1.GatewayList
+	if err := r.List(ctx, &gateways); err != nil {
+		return nil, err
+	}
+
+	result := make([]client.Object, 0, len(gateways.Items))
+	for i := range gateways.Items {
+		if r.isManaged(gateways.Items[i]) {
+			result = append(result, &gateways.Items[i])
+		}
+	}
+	return result, nil
+}
+
+func (r *GatewayReconciler) findGatewaysByService(ctx context.Context, svc client.Object) []reconcile.Request {
+	return r.findGatewaysBackedBy(ctx, client.ObjectKeyFromObject(svc))
+}
+
+func (r *GatewayReconciler) findGatewaysByEndpointSlice(
+	ctx context.Context,
+	endpointSlice client.Object,
+) []reconcile.Request {
+	epSlice, ok := endpointSlice.(*discoveryv1.EndpointSlice)
+	if !ok {
+		return []reconcile.Request{}
+	}
+	serviceName, err := endpointslice.ServiceKeyForSlice(epSlice)
+	if err != nil {
+		return []reconcile.Request{}
+	}
+	return r.findGatewaysBackedBy(ctx, serviceName)
+}
+
+func (r *GatewayReconciler) findGatewaysBackedBy(
+	ctx context.Context,
+	svcKey types.NamespacedName,
+) []reconcile.Request {
+	gateways, err := r.Gateways.ListBackedBy(ctx, svcKey)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "unable to list Gateways", "service", svcKey)
+		return []reconcile.Request{}
+	}
+	return r.requestsOf(gateways.Items)
+}
+
+func (r *GatewayReconciler) findAllGateways(ctx context.Context, _ client.Object) []reconcile.Request {
+	var gateways gatewayv1.GatewayList
+	if err := r.List(ctx, &gateways); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list Gateways")
+		return []reconcile.Request{}
+	}
+	return r.requestsOf(gateways.Items)
+}
+
+func (r *GatewayReconciler) requestsOf(gateways []gatewayv1.Gateway) []reconcile.Request {
+	requests := make([]reconcile.Request, 0, len(gateways))
+	for _, gw := range gateways {
+		if !r.isManaged(gw) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&gw)})
+	}
+	return requests
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/package controllers
+
+import (
+	"context"
+
+	"github.com/isac322/static-lb/internal/application"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// IngressReconciler reconciles an Ingress object of IngressClasses
+type IngressReconciler struct {
+	client.Client
+	Scheme         *runtime.Scheme
+	Usecase        application.Usecase
+	IngressClasses []string
+	// ControllerPods selects Pods of the ingress controller. Its changes trigger reconcile of every Ingress.
+	ControllerPods application.IngressControllerPods
+	Options        ReconcileOptions
+	// Shard limits reconciles to Ingresses of the local shard. Every Ingress is reconciled if nil.
+	Shard ShardFilter
+}
+
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses/status,verbs=update
+
+// Reconcile assigns IPs of nodes running the ingress controller to status.loadBalancer.ingress of the Ingress.
+func (r *IngressReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	logger.WithValues("ingress", req.NamespacedName)
+
+	var ing networkingv1.Ingress
+	if err := r.Get(ctx, req.NamespacedName, &ing); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			logger.Error(err, "unable to fetch Ingress")
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !r.isManaged(ing) {
+		return ctrl.Result{}, nil
+	}
+
+	if err := r.Usecase.AssignIngressIPs(ctx, ing); err != nil {
+		logger.Error(err, "unable to assign IPs to Ingress")
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *IngressReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	bldr := ctrl.NewControllerManagedBy(mgr).
+		WithOptions(r.Options.controllerOptions()).
+		For(
+			&networkingv1.Ingress{},
+			builder.WithPredicates(predicate.NewPredicateFuncs(func(object client.Object) bool {
+				ing, ok := object.(*networkingv1.Ingress)
+				return ok && r.isManaged(*ing)
+			})),
+		).
+		Watches(
+			&corev1.Pod{},
+			handler.EnqueueRequestsFromMapFunc(r.findAllIngresses),
+			builder.WithPredicates(predicate.NewPredicateFuncs(r.isControllerPod)),
+		).
+		Watches(
+			&corev1.Node{},
+			handler.EnqueueRequestsFromMapFunc(r.findAllIngresses),
+			builder.WithPredicates(nodeChangedPredicate),
+		)
+
+	shardSource, err := shardChangeSource(mgr, r.Shard, r.listManagedIngresses)
+	if err != nil {
+		return err
+	}
+	if shardSource != nil {
+		bldr = bldr.WatchesRawSource(shardSource, &handler.EnqueueRequestForObject{})
+	}
+
+	return bldr.Complete(r)
+}
+
+// isManaged reports whether ing is of IngressClasses and belongs to the local shard.
+func (r *IngressReconciler) isManaged(ing networkingv1.Ingress) bool {
+	if !owns(r.Shard, client.ObjectKeyFromObject(&ing)) {
+		return false
+	}
+
+	class := application.IngressClassOf(ing)
+	for _, c := range r.IngressClasses {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *IngressReconciler) listManagedIngresses(ctx context.Context) ([]client.Object, error) {
+	var ingresses networkingv1.IngressList
+	if err := r.List(ctx, &ingresses); err != nil {
+		return nil, err
+	}
+
+	result := make([]client.Object, 0, len(ingresses.Items))
+	for i := range ingresses.Items {
+		if r.isManaged(ingresses.Items[i]) {
+			result = append(result, &ingresses.Items[i])
+		}
+	}
+	return result, nil
+}
+
+func (r *IngressReconciler) isControllerPod(object client.Object) bool {
+	if r.ControllerPods.Namespace != "" && object.GetNamespace() != r.ControllerPods.Namespace {
+		return false
+	}
+	return r.ControllerPods.Selector.Matches(labels.Set(object.GetLabels()))
+}
+
+func (r *IngressReconciler) findAllIngresses(ctx context.Context, _ client.Object) []reconcile.Request {
+	var ingresses networkingv1.IngressList
+	if err := r.List(ctx, &ingresses); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list Ingresses")
+		return []reconcile.Request{}
+	}
+
+	requests := make([]reconcile.Request, 0, len(ingresses.Items))
+	for _, ing := range ingresses.Items {
+		if !r.isManaged(ing) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&ing)})
+	}
+	return requests
+}
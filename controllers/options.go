@@ -0,0 +1,81 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+)
+
+// ReconcileOptions tunes how many reconciles of a controller run at once and how fast failed or repeated requests
+// are retried. The zero value keeps defaults of controller-runtime.
+type ReconcileOptions struct {
+	// MaxConcurrentReconciles is the number of workers of a controller.
+	MaxConcurrentReconciles int
+	// BaseDelay and MaxDelay bound the per-item exponential backoff.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// QPS and Burst configure the token bucket shared by every item of a controller.
+	QPS   float64
+	Burst int
+}
+
+// Validate reports options that contradict each other, e.g. MaxDelay shorter than BaseDelay.
+func (o ReconcileOptions) Validate() error {
+	if o.MaxDelay > 0 && o.MaxDelay < o.BaseDelay {
+		return fmt.Errorf("max delay %s must not be shorter than base delay %s", o.MaxDelay, o.BaseDelay)
+	}
+	return nil
+}
+
+// controllerOptions builds options of a single controller. Each call creates its own rate limiter,
+// since a rate limiter keeps the backoff state by request and must not be shared across controllers.
+func (o ReconcileOptions) controllerOptions() controller.Options {
+	opts := controller.Options{MaxConcurrentReconciles: o.MaxConcurrentReconciles}
+	if o.BaseDelay <= 0 && o.MaxDelay <= 0 && o.QPS <= 0 {
+		return opts
+	}
+
+	baseDelay, maxDelay := o.BaseDelay, o.MaxDelay
+	if baseDelay <= 0 {
+		baseDelay = 5 * time.Millisecond
+	}
+	switch {
+	case maxDelay <= 0:
+		maxDelay = 1000 * time.Second
+	case maxDelay < baseDelay:
+		// rejected by Validate
+		maxDelay = baseDelay
+	}
+	limit, burst := rate.Limit(o.QPS), o.Burst
+	if o.QPS <= 0 {
+		limit = rate.Inf
+	}
+	if burst <= 0 {
+		burst = 100
+	}
+
+	opts.RateLimiter = workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(baseDelay, maxDelay),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(limit, burst)},
+	)
+	return opts
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcileOptions_controllerOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                    string
+		opts                    ReconcileOptions
+		expectedRateLimiter     bool
+		expectedFirstDelay      time.Duration
+		expectedUnthrottledRuns int
+	}{
+		{
+			name:                "defaults of controller-runtime",
+			opts:                ReconcileOptions{MaxConcurrentReconciles: 2},
+			expectedRateLimiter: false,
+		},
+		{
+			name:                    "base delay with unlimited qps",
+			opts:                    ReconcileOptions{BaseDelay: time.Second},
+			expectedRateLimiter:     true,
+			expectedFirstDelay:      time.Second,
+			expectedUnthrottledRuns: 1000,
+		},
+		{
+			name:                    "burst of qps",
+			opts:                    ReconcileOptions{QPS: 0.001, Burst: 3},
+			expectedRateLimiter:     true,
+			expectedFirstDelay:      5 * time.Millisecond,
+			expectedUnthrottledRuns: 3,
+		},
+		{
+			name:                    "default burst",
+			opts:                    ReconcileOptions{QPS: 0.001},
+			expectedRateLimiter:     true,
+			expectedFirstDelay:      5 * time.Millisecond,
+			expectedUnthrottledRuns: 100,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			opts := tc.opts.controllerOptions()
+			assert.Equal(t, tc.opts.MaxConcurrentReconciles, opts.MaxConcurrentReconciles)
+			if !tc.expectedRateLimiter {
+				assert.Nil(t, opts.RateLimiter)
+				return
+			}
+			require.NotNil(t, opts.RateLimiter)
+
+			assert.Equal(t, tc.expectedFirstDelay, opts.RateLimiter.When("first"))
+			// distinct items only spend tokens of the bucket, so they are delayed once the burst is exhausted
+			for i := 1; i < tc.expectedUnthrottledRuns; i++ {
+				assert.LessOrEqual(t, opts.RateLimiter.When(i), tc.expectedFirstDelay)
+			}
+			if tc.opts.QPS > 0 {
+				assert.Greater(t, opts.RateLimiter.When("throttled"), time.Minute)
+			}
+		})
+	}
+}
+
+func TestReconcileOptions_controllerOptions_separateLimiters(t *testing.T) {
+	t.Parallel()
+
+	opts := ReconcileOptions{QPS: 0.001, Burst: 1}
+	first, second := opts.controllerOptions(), opts.controllerOptions()
+
+	assert.Equal(t, 5*time.Millisecond, first.RateLimiter.When("item"))
+	assert.Equal(t, 5*time.Millisecond, second.RateLimiter.When("item"), "controllers must not share the bucket")
+}
+
+func TestReconcileOptions_Validate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		opts     ReconcileOptions
+		hasError bool
+	}{
+		{
+			name: "zero value",
+		},
+		{
+			name: "max delay longer than base delay",
+			opts: ReconcileOptions{BaseDelay: 10 * time.Second, MaxDelay: time.Minute},
+		},
+		{
+			name: "default max delay",
+			opts: ReconcileOptions{BaseDelay: 10 * time.Second},
+		},
+		{
+			name:     "max delay shorter than base delay",
+			opts:     ReconcileOptions{BaseDelay: 10 * time.Second, MaxDelay: 5 * time.Second},
+			hasError: true,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.opts.Validate()
+			if tc.hasError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestReconcileOptions_controllerOptions_maxDelayShorterThanBaseDelay(t *testing.T) {
+	t.Parallel()
+
+	opts := ReconcileOptions{BaseDelay: 10 * time.Second, MaxDelay: 5 * time.Second}.controllerOptions()
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, 10*time.Second, opts.RateLimiter.When("item"), "the delay is clamped to the base delay")
+	}
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// nodeChangedPredicate passes Node events that may change the set of published node IPs.
+var nodeChangedPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldNode, ok := e.ObjectOld.(*corev1.Node)
+		if !ok {
+			return false
+		}
+		newNode, ok := e.ObjectNew.(*corev1.Node)
+		if !ok {
+			return false
+		}
+		return isNodeReady(*oldNode) != isNodeReady(*newNode) ||
+			!equality.Semantic.DeepEqual(oldNode.Labels, newNode.Labels) ||
+			!equality.Semantic.DeepEqual(oldNode.Annotations, newNode.Annotations) ||
+			!equality.Semantic.DeepEqual(oldNode.Status.Addresses, newNode.Status.Addresses)
+	},
+}
+
+// podCIDRsChanged reports whether pod CIDRs differ between the old and new Node.
+func podCIDRsChanged(oldObj, newObj client.Object) bool {
+	oldNode, ok := oldObj.(*corev1.Node)
+	if !ok {
+		return false
+	}
+	newNode, ok := newObj.(*corev1.Node)
+	if !ok {
+		return false
+	}
+	return oldNode.Spec.PodCIDR != newNode.Spec.PodCIDR ||
+		!equality.Semantic.DeepEqual(oldNode.Spec.PodCIDRs, newNode.Spec.PodCIDRs)
+}
+
+func isNodeReady(node corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -18,34 +18,59 @@ package controllers
 
 import (
 	"context"
+	"time"
 
 	"github.com/isac322/static-lb/internal/application"
 	"github.com/isac322/static-lb/internal/pkg/endpointslice"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
 	corev1 "k8s.io/api/core/v1"
 	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
+// tracer records spans to the global TracerProvider, which drops them unless tracing is set up.
+var tracer = otel.Tracer("github.com/isac322/static-lb/controllers")
+
 // ServiceReconciler reconciles a Service object
 type ServiceReconciler struct {
 	client.Client
 	Scheme  *runtime.Scheme
 	Usecase application.Usecase
+	// ResyncPeriod is the interval to reconcile every LoadBalancer Service even if nothing changes.
+	// Periodic resync is disabled if it is zero.
+	ResyncPeriod time.Duration
+	Options      ReconcileOptions
+	// Shard limits reconciles to Services of the local shard. Every Service is reconciled if nil.
+	Shard ShardFilter
+	// PortClaimChanges receives Services whose conflicts of ports may have changed, which are reconciled again.
+	// It is ignored if nil.
+	PortClaimChanges *ServiceKeySet
 }
 
 //+kubebuilder:rbac:groups="",resources=services;endpoints;nodes,verbs=get;list;watch
 //+kubebuilder:rbac:groups=discovery.k8s.io,resources=endpointslices,verbs=get;list;watch
 //+kubebuilder:rbac:groups="",resources=services;services/status,verbs=update
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;create;update
+//+kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;list;create;update;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -56,7 +81,19 @@ type ServiceReconciler struct {
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.13.0/pkg/reconcile
-func (r *ServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *ServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, err error) {
+	ctx, span := tracer.Start(ctx, "ServiceReconciler.Reconcile", trace.WithAttributes(
+		attribute.String("service.namespace", req.Namespace),
+		attribute.String("service.name", req.Name),
+	))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
 	logger := log.FromContext(ctx)
 	logger.WithValues("service", req.NamespacedName)
 
@@ -65,10 +102,23 @@ func (r *ServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		logger.Error(err, "unable to fetch Service")
 		return ctrl.Result{}, err
 	}
+	if service.Name == "" {
+		// the Service has been deleted
+		if err = r.Usecase.Remove(ctx, req.NamespacedName); err != nil {
+			logger.Error(err, "unable to remove Service from inventory")
+		}
+		return ctrl.Result{}, err
+	}
+	if !owns(r.Shard, req.NamespacedName) {
+		// the Service has been moved to another shard, which takes over its inventory entry too
+		r.Usecase.Forget(req.NamespacedName)
+		return ctrl.Result{}, nil
+	}
 
-	if err = r.Usecase.AssignIPs(ctx, service); err != nil {
+	result, err := r.Usecase.AssignIPs(ctx, service)
+	if err != nil {
 		logger.Error(err, "unable to assign IPs to Service")
-		return ctrl.Result{Requeue: true}, err
+		return ctrl.Result{}, err
 	}
 
 	logger.Info(
@@ -79,12 +129,13 @@ func (r *ServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		service.Spec.ExternalIPs,
 	)
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: result.RequeueAfter}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	bldr := ctrl.NewControllerManagedBy(mgr).
+		WithOptions(r.Options.controllerOptions()).
 		For(
 			&corev1.Service{},
 			builder.WithPredicates(predicate.Funcs{
@@ -93,7 +144,8 @@ func (r *ServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 					if !ok {
 						return false
 					}
-					return service.Spec.Type == corev1.ServiceTypeLoadBalancer
+					return service.Spec.Type == corev1.ServiceTypeLoadBalancer &&
+						owns(r.Shard, client.ObjectKeyFromObject(service))
 				},
 				UpdateFunc: func(e event.UpdateEvent) bool {
 					newService, ok := e.ObjectNew.(*corev1.Service)
@@ -105,8 +157,9 @@ func (r *ServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 						return false
 					}
 
-					return oldService.Spec.Type == corev1.ServiceTypeLoadBalancer ||
-						newService.Spec.Type == corev1.ServiceTypeLoadBalancer
+					return (oldService.Spec.Type == corev1.ServiceTypeLoadBalancer ||
+						newService.Spec.Type == corev1.ServiceTypeLoadBalancer) &&
+						owns(r.Shard, client.ObjectKeyFromObject(newService))
 				},
 			}),
 		).
@@ -114,11 +167,174 @@ func (r *ServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 			&discoveryv1.EndpointSlice{},
 			handler.EnqueueRequestsFromMapFunc(r.findLinkedServiceByEndpointSlice),
 		).
-		Complete(r)
+		Watches(
+			&corev1.Node{},
+			handler.EnqueueRequestsFromMapFunc(r.findServicesInShard),
+			builder.WithPredicates(nodeChangedPredicate),
+		).
+		Watches(&corev1.Service{}, r.observeServices()).
+		Watches(&corev1.Node{}, r.observeNodes())
+
+	if r.PortClaimChanges != nil {
+		portClaimEvents := make(chan event.GenericEvent)
+		if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			return r.forwardPortClaimChanges(ctx, portClaimEvents)
+		})); err != nil {
+			return err
+		}
+		bldr = bldr.WatchesRawSource(&source.Channel{Source: portClaimEvents}, &handler.EnqueueRequestForObject{})
+	}
+
+	if r.ResyncPeriod > 0 || r.Shard != nil {
+		// subscribe before the manager starts, so that the first membership is not missed
+		changes, resyncEvents := shardChanges(r.Shard), make(chan event.GenericEvent)
+		if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			return r.resync(ctx, changes, resyncEvents)
+		})); err != nil {
+			return err
+		}
+		bldr = bldr.WatchesRawSource(&source.Channel{Source: resyncEvents}, &handler.EnqueueRequestForObject{})
+	}
+
+	return bldr.Complete(r)
+}
+
+// resync sends every LoadBalancer Service of the local shard to events on each ResyncPeriod,
+// and whenever the shard changes, until ctx is done.
+func (r *ServiceReconciler) resync(
+	ctx context.Context,
+	changes <-chan struct{},
+	events chan<- event.GenericEvent,
+) error {
+	logger := log.FromContext(ctx)
+
+	var tick <-chan time.Time
+	if r.ResyncPeriod > 0 {
+		ticker := time.NewTicker(r.ResyncPeriod)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tick:
+		case <-changes:
+		}
+
+		services, err := r.listServicesInShard(ctx)
+		if err != nil {
+			logger.Error(err, "unable to list Services to resync")
+			continue
+		}
+
+		for i := range services {
+			select {
+			case events <- event.GenericEvent{Object: &services[i]}:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
+
+// observeServices tells the usecase about every Service, including ones of other shards, so that conflicts of ports
+// with them are detected. It enqueues nothing.
+func (r *ServiceReconciler) observeServices() handler.EventHandler {
+	observe := func(obj client.Object) {
+		if service, ok := obj.(*corev1.Service); ok {
+			r.Usecase.ObserveService(*service)
+		}
+	}
+	return handler.Funcs{
+		CreateFunc: func(_ context.Context, e event.CreateEvent, _ workqueue.RateLimitingInterface) {
+			observe(e.Object)
+		},
+		UpdateFunc: func(_ context.Context, e event.UpdateEvent, _ workqueue.RateLimitingInterface) {
+			observe(e.ObjectNew)
+		},
+		DeleteFunc: func(_ context.Context, e event.DeleteEvent, _ workqueue.RateLimitingInterface) {
+			r.Usecase.ObserveServiceDeletion(client.ObjectKeyFromObject(e.Object))
+		},
+	}
+}
+
+// observeNodes tells the usecase that pod CIDRs of nodes may have changed, which changes no published IP by itself.
+// It enqueues nothing.
+func (r *ServiceReconciler) observeNodes() handler.EventHandler {
+	return handler.Funcs{
+		CreateFunc: func(context.Context, event.CreateEvent, workqueue.RateLimitingInterface) {
+			r.Usecase.InvalidateReservedIPNets()
+		},
+		UpdateFunc: func(_ context.Context, e event.UpdateEvent, _ workqueue.RateLimitingInterface) {
+			if podCIDRsChanged(e.ObjectOld, e.ObjectNew) {
+				r.Usecase.InvalidateReservedIPNets()
+			}
+		},
+		DeleteFunc: func(context.Context, event.DeleteEvent, workqueue.RateLimitingInterface) {
+			r.Usecase.InvalidateReservedIPNets()
+		},
+	}
+}
+
+// forwardPortClaimChanges sends Services of PortClaimChanges that the local shard owns to events, until ctx is done.
+func (r *ServiceReconciler) forwardPortClaimChanges(ctx context.Context, events chan<- event.GenericEvent) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-r.PortClaimChanges.Ready():
+		}
+
+		for _, key := range r.PortClaimChanges.Take() {
+			if !owns(r.Shard, key) {
+				continue
+			}
+
+			service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name}}
+			select {
+			case events <- event.GenericEvent{Object: service}:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
+
+func (r *ServiceReconciler) listServicesInShard(ctx context.Context) ([]corev1.Service, error) {
+	var services corev1.ServiceList
+	if err := r.List(ctx, &services); err != nil {
+		return nil, err
+	}
+
+	result := make([]corev1.Service, 0, len(services.Items))
+	for _, service := range services.Items {
+		if service.Spec.Type != corev1.ServiceTypeLoadBalancer ||
+			!owns(r.Shard, client.ObjectKeyFromObject(&service)) {
+			continue
+		}
+		result = append(result, service)
+	}
+	return result, nil
+}
+
+func (r *ServiceReconciler) findServicesInShard(ctx context.Context, _ client.Object) []reconcile.Request {
+	services, err := r.listServicesInShard(ctx)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "unable to list Services")
+		return []reconcile.Request{}
+	}
+
+	requests := make([]reconcile.Request, 0, len(services))
+	for _, service := range services {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&service)})
+	}
+	return requests
 }
 
 func (r *ServiceReconciler) findLinkedServiceByEndpointSlice(
-	_ context.Context,
+	ctx context.Context,
 	endpointSlice client.Object,
 ) []reconcile.Request {
 	epSlice, ok := endpointSlice.(*discoveryv1.EndpointSlice)
@@ -126,7 +342,13 @@ func (r *ServiceReconciler) findLinkedServiceByEndpointSlice(
 		return []reconcile.Request{}
 	}
 	serviceName, err := endpointslice.ServiceKeyForSlice(epSlice)
-	if err != nil {
+	if err != nil || !owns(r.Shard, serviceName) {
+		return []reconcile.Request{}
+	}
+
+	// changes of the Service itself, including its type, are handled by the Service watch
+	var service corev1.Service
+	if err = r.Get(ctx, serviceName, &service); err != nil || service.Spec.Type != corev1.ServiceTypeLoadBalancer {
 		return []reconcile.Request{}
 	}
 	return []reconcile.Request{{NamespacedName: serviceName}}
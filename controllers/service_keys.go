@@ -0,0 +1,70 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ServiceKeySet collects keys of Services to reconcile again. Adding never blocks, since it is called while
+// informers deliver events, and keys added more than once are reconciled once.
+type ServiceKeySet struct {
+	mu    sync.Mutex
+	keys  map[types.NamespacedName]struct{}
+	ready chan struct{}
+}
+
+func NewServiceKeySet() *ServiceKeySet {
+	return &ServiceKeySet{
+		keys:  map[types.NamespacedName]struct{}{},
+		ready: make(chan struct{}, 1),
+	}
+}
+
+func (s *ServiceKeySet) Add(key types.NamespacedName) {
+	s.mu.Lock()
+	s.keys[key] = struct{}{}
+	s.mu.Unlock()
+
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Ready notifies that some keys may have been added since the last take.
+func (s *ServiceKeySet) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Take removes every key from the set and returns them in order.
+func (s *ServiceKeySet) Take() []types.NamespacedName {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]types.NamespacedName, 0, len(s.keys))
+	for key := range s.keys {
+		result = append(result, key)
+	}
+	s.keys = map[types.NamespacedName]struct{}{}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].String() < result[j].String() })
+	return result
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceKeySet(t *testing.T) {
+	t.Parallel()
+
+	a := types.NamespacedName{Namespace: "default", Name: "a"}
+	b := types.NamespacedName{Namespace: "default", Name: "b"}
+
+	set := NewServiceKeySet()
+	set.Add(b)
+	set.Add(a)
+	set.Add(b)
+
+	select {
+	case <-set.Ready():
+	default:
+		assert.Fail(t, "the set is not ready")
+	}
+	assert.Equal(t, []types.NamespacedName{a, b}, set.Take())
+	assert.Empty(t, set.Take())
+
+	select {
+	case <-set.Ready():
+		assert.Fail(t, "the set is ready without new keys")
+	default:
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// ShardFilter tells which objects the local replica is responsible for, when objects are sharded across
+// multiple active replicas.
+type ShardFilter interface {
+	Owns(key types.NamespacedName) bool
+	// Changes returns a new channel that notifies that ownership of some objects may have moved. Each consumer
+	// calls it once and keeps the channel.
+	Changes() <-chan struct{}
+}
+
+// owns reports whether key belongs to the local shard. Every key belongs to it if shard is nil.
+func owns(shard ShardFilter, key types.NamespacedName) bool {
+	return shard == nil || shard.Owns(key)
+}
+
+// shardChanges returns changes of shard, or nil that blocks forever if shard is nil.
+func shardChanges(shard ShardFilter) <-chan struct{} {
+	if shard == nil {
+		return nil
+	}
+	return shard.Changes()
+}
+
+// shardChangeSource adds a runnable to mgr that sends every object of list to the returned source whenever shard
+// changes, so that objects that moved into the local shard are reconciled. It returns nil if shard is nil.
+func shardChangeSource(
+	mgr ctrl.Manager,
+	shard ShardFilter,
+	list func(ctx context.Context) ([]client.Object, error),
+) (source.Source, error) {
+	if shard == nil {
+		return nil, nil
+	}
+
+	changes := shard.Changes()
+	events := make(chan event.GenericEvent)
+	err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-changes:
+			}
+
+			objects, err := list(ctx)
+			if err != nil {
+				log.FromContext(ctx).Error(err, "unable to list objects of the shard")
+				continue
+			}
+			for _, object := range objects {
+				select {
+				case events <- event.GenericEvent{Object: object}:
+				case <-ctx.Done():
+					return nil
+				}
+			}
+		}
+	}))
+	if err != nil {
+		return nil, err
+	}
+	return &source.Channel{Source: events}, nil
+}
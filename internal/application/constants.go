@@ -1,18 +1,155 @@
 package application
 
-type IPMappingTarget string
+import (
+	"github.com/isac322/static-lb/pkg/staticlb"
+)
+
+type (
+	IPMappingTarget      = staticlb.IPMappingTarget
+	NodeAddressType      = staticlb.NodeAddressType
+	NodeAddressSelection = staticlb.NodeAddressSelection
+)
+
+const (
+	IPMappingTargetIngress  = staticlb.IPMappingTargetIngress
+	IPMappingTargetExternal = staticlb.IPMappingTargetExternal
+)
 
 const (
-	IPMappingTargetIngress  IPMappingTarget = "ingress"
-	IPMappingTargetExternal IPMappingTarget = "external"
+	NodeAddressTypeInternal   = staticlb.NodeAddressTypeInternal
+	NodeAddressTypeExternal   = staticlb.NodeAddressTypeExternal
+	NodeAddressTypeHostname   = staticlb.NodeAddressTypeHostname
+	NodeAddressTypeAnnotation = staticlb.NodeAddressTypeAnnotation
 )
 
 const (
-	LabelIncludeIngressIPNets  = "static-lb.bhyoo.com/include-ingress-ip-nets"
-	LabelIncludeExternalIPNets = "static-lb.bhyoo.com/include-external-ip-nets"
-	LabelExcludeIngressIPNets  = "static-lb.bhyoo.com/exclude-ingress-ip-nets"
-	LabelExcludeExternalIPNets = "static-lb.bhyoo.com/exclude-external-ip-nets"
+	NodeAddressSelectionAll            = staticlb.NodeAddressSelectionAll
+	NodeAddressSelectionFirstPerFamily = staticlb.NodeAddressSelectionFirstPerFamily
+)
+
+type EmptyIPsMode string
+
+const (
+	// EmptyIPsModeClear removes all assigned IPs as soon as no candidate remains.
+	EmptyIPsModeClear EmptyIPsMode = "clear"
+	// EmptyIPsModeKeepLast leaves previously assigned IPs until new candidates appear.
+	EmptyIPsModeKeepLast EmptyIPsMode = "keep-last"
+	// EmptyIPsModeHoldFor leaves previously assigned IPs for a grace period, then clears them.
+	EmptyIPsModeHoldFor EmptyIPsMode = "hold-for"
+)
 
-	LabelInternalIPMappings = "static-lb.bhyoo.com/internal-ip-mappings"
-	LabelExternalIPMappings = "static-lb.bhyoo.com/external-ip-mappings"
+// NoNodePortsMode decides how IPs of a LoadBalancer Service whose node ports are not allocated
+// (spec.allocateLoadBalancerNodePorts false) are published.
+type NoNodePortsMode string
+
+const (
+	// NoNodePortsModePublish publishes ingress IPs anyway. They work only if something routes them on nodes.
+	NoNodePortsModePublish NoNodePortsMode = "publish"
+	// NoNodePortsModeExternalOnly publishes ingress IPs as external IPs, which kube-proxy routes on nodes.
+	NoNodePortsModeExternalOnly NoNodePortsMode = "external-only"
+	// NoNodePortsModeSkip publishes no ingress IPs.
+	NoNodePortsModeSkip NoNodePortsMode = "skip"
+)
+
+// PortConflictPolicy decides what to do with IPs of a Service whose ports are already published on them by another
+// Service.
+type PortConflictPolicy string
+
+const (
+	// PortConflictPolicyWarn publishes conflicting IPs anyway, and only reports the conflict.
+	PortConflictPolicyWarn PortConflictPolicy = "warn"
+	// PortConflictPolicyExclude does not publish conflicting IPs.
+	PortConflictPolicyExclude PortConflictPolicy = "exclude"
+)
+
+// annotationDomain prefixes every annotation of static-lb. An instance named "x" reads "x.static-lb.bhyoo.com/..."
+// instead, except for LabelInstance.
+const annotationDomain = "static-lb.bhyoo.com"
+
+const (
+	// LabelInstance binds the Service to the static-lb instance of its value.
+	// Services without it are bound to the instance without a name.
+	LabelInstance = "static-lb.bhyoo.com/instance"
+
+	LabelIncludeIngressIPNets  = staticlb.LabelIncludeIngressIPNets
+	LabelIncludeExternalIPNets = staticlb.LabelIncludeExternalIPNets
+	LabelExcludeIngressIPNets  = staticlb.LabelExcludeIngressIPNets
+	LabelExcludeExternalIPNets = staticlb.LabelExcludeExternalIPNets
+
+	LabelInternalIPMappings = staticlb.LabelInternalIPMappings
+	LabelExternalIPMappings = staticlb.LabelExternalIPMappings
+	LabelIPMappings         = staticlb.LabelIPMappings
+
+	LabelNodeAddressSelection = staticlb.LabelNodeAddressSelection
+
+	LabelEmptyIPsPolicy = "static-lb.bhyoo.com/empty-ips-policy"
+
+	LabelIPAddDelay    = "static-lb.bhyoo.com/ip-add-delay"
+	LabelIPRemoveDelay = "static-lb.bhyoo.com/ip-remove-delay"
+
+	LabelNoNodePortsPolicy = "static-lb.bhyoo.com/no-node-ports-policy"
+	// LabelNodePortConflictPolicy is the PortConflictPolicy for external IPs whose ports are node ports of other
+	// Services. It overrides NodePortsPolicy.RefuseConflicts.
+	LabelNodePortConflictPolicy = "static-lb.bhyoo.com/node-port-conflict-policy"
+
+	LabelPortConflictPolicy = "static-lb.bhyoo.com/port-conflict-policy"
+	// LabelRequestedIPs lists, separated by commas, IPs that the Service is published on, if they are eligible.
+	// It takes precedence over spec.loadBalancerIP.
+	LabelRequestedIPs = staticlb.LabelRequestedIPs
+
+	// LabelSharingKey lets Services of the same value be published on the same IPs and ports without a conflict.
+	LabelSharingKey = "static-lb.bhyoo.com/sharing-key"
+
+	LabelNATRules            = staticlb.LabelNATRules
+	LabelDropUntranslatedIPs = staticlb.LabelDropUntranslatedIPs
+
+	LabelZones         = staticlb.LabelZones
+	LabelRegions       = staticlb.LabelRegions
+	LabelMaxIPsPerZone = staticlb.LabelMaxIPsPerZone
+
+	// LabelFrozen stops static-lb from modifying the Service if it is "true".
+	LabelFrozen = "static-lb.bhyoo.com/frozen"
+
+	// LabelGatewayService names the Service ("name" or "namespace/name") that backs a Gateway. A Service of another
+	// namespace is referred to only if a ReferenceGrant of that namespace allows it.
+	LabelGatewayService = "static-lb.bhyoo.com/gateway-service"
+	// LabelGatewayNodeSelector selects nodes that serve a Gateway when it has no backing Service.
+	LabelGatewayNodeSelector = "static-lb.bhyoo.com/gateway-node-selector"
+
+	// LabelLegacyIngressClass is the deprecated annotation that was used before spec.ingressClassName.
+	LabelLegacyIngressClass = "kubernetes.io/ingress.class"
+)
+
+const (
+	ConditionTypeIPsAssigned = "static-lb.bhyoo.com/IPsAssigned"
+	ConditionTypeConfigValid = "static-lb.bhyoo.com/ConfigValid"
+	// ConditionTypePortsReachable tells whether ports of a Service are reachable through its published IPs.
+	ConditionTypePortsReachable = "static-lb.bhyoo.com/PortsReachable"
+	// ConditionTypeConflictFree tells whether no older Service is published on the same IPs and ports.
+	ConditionTypeConflictFree = "static-lb.bhyoo.com/ConflictFree"
+
+	ConditionReasonAssigned               = "Assigned"
+	ConditionReasonStaleAddresses         = "StaleAddresses"
+	ConditionReasonNoEligibleNodes        = "NoEligibleNodes"
+	ConditionReasonNotMapped              = "NotMapped"
+	ConditionReasonFilteredToEmpty        = "FilteredToEmpty"
+	ConditionReasonPartialFamilyCoverage  = "PartialFamilyCoverage"
+	ConditionReasonValid                  = "Valid"
+	ConditionReasonInvalidAnnotation      = "InvalidAnnotation"
+	ConditionReasonReachable              = "Reachable"
+	ConditionReasonNodePortsNotAllocated  = "NodePortsNotAllocated"
+	ConditionReasonPublishedAsExternalIPs = "PublishedAsExternalIPs"
+	ConditionReasonNodePortConflict       = "NodePortConflict"
+	ConditionReasonNoConflict             = "NoConflict"
+	ConditionReasonRequestedIPUnavailable = "RequestedIPUnavailable"
+	ConditionReasonPortConflict           = "PortConflict"
+)
+
+const (
+	EventReasonStaleAddresses   = "StaleAddresses"
+	EventReasonAddressesCleared = "AddressesCleared"
+	EventReasonDriftCorrected   = "DriftCorrected"
+	EventReasonNodePortConflict = "NodePortConflict"
+	EventReasonPortConflict     = "PortConflict"
+	EventReasonNodeNotFound     = "NodeNotFound"
 )
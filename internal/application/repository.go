@@ -5,11 +5,22 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
 )
 
 type ServiceRepository interface {
-	AssignIPs(ctx context.Context, svc corev1.Service, target IPStatus) error
+	Get(ctx context.Context, key types.NamespacedName) (corev1.Service, error)
+	// List returns every Service of the cluster.
+	List(ctx context.Context) ([]corev1.Service, error)
+	// AssignIPs writes target to svc along with conditions.
+	// Conditions of ServiceConditionTypes that are missing in conditions are removed from svc.
+	AssignIPs(ctx context.Context, svc corev1.Service, target IPStatus, conditions []metav1.Condition) error
 }
 
 type EndpointSliceRepository interface {
@@ -17,6 +28,48 @@ type EndpointSliceRepository interface {
 }
 
 type NodeRepository interface {
-	GetByName(ctx context.Context, name string) (corev1.Node, error)
+	List(ctx context.Context) ([]corev1.Node, error)
+	// ListByNames returns nodes of names in one pass, and names of nodes that do not exist.
+	ListByNames(ctx context.Context, names []string) (nodes []corev1.Node, missing []string, err error)
 	ListReady(ctx context.Context) ([]corev1.Node, error)
+	ListReadyMatching(ctx context.Context, selector labels.Selector) ([]corev1.Node, error)
+}
+
+type PodRepository interface {
+	ListReadyMatching(ctx context.Context, namespace string, selector labels.Selector) ([]corev1.Pod, error)
+}
+
+type IngressRepository interface {
+	AssignIPs(ctx context.Context, ing networkingv1.Ingress, ips []string) error
+}
+
+type GatewayRepository interface {
+	AssignAddresses(ctx context.Context, gw gatewayv1.Gateway, addresses []string, conditions []metav1.Condition) error
+	// ListReferenceGrants returns ReferenceGrants of namespace.
+	ListReferenceGrants(ctx context.Context, namespace string) ([]gatewayv1beta1.ReferenceGrant, error)
+}
+
+// InventoryRepository keeps the aggregated inventory of every managed Service.
+type InventoryRepository interface {
+	// Put adds or replaces the entry of entry.Service.
+	Put(ctx context.Context, entry InventoryEntry) error
+	Delete(ctx context.Context, svcKey types.NamespacedName) error
+	// Retain deletes every entry of a Service for which keep returns false.
+	Retain(ctx context.Context, keep func(svcKey types.NamespacedName) bool) error
+}
+
+// EventRecorder is the subset of client-go's record.EventRecorder that the usecase relies on.
+type EventRecorder interface {
+	Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{})
+}
+
+type MetricsRecorder interface {
+	// DriftCorrected counts a Service whose IPs were changed by someone else and are put back by static-lb.
+	DriftCorrected(svcKey types.NamespacedName)
+}
+
+// AssignmentHook is notified after IPs of a Service are changed by static-lb.
+// A failing hook does not fail the assignment, which is already done.
+type AssignmentHook interface {
+	IPsAssigned(ctx context.Context, change IPChange) error
 }
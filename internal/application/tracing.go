@@ -0,0 +1,51 @@
+package application
+
+import (
+	"github.com/isac322/static-lb/pkg/staticlb"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer records spans to the global TracerProvider, which drops them unless tracing is set up.
+var tracer = otel.Tracer("github.com/isac322/static-lb/internal/application")
+
+const (
+	configSourceAnnotation = "annotation"
+	configSourceDefault    = "default"
+)
+
+// configSourceOf tells whether any of annotationNames overrides the default configuration.
+func configSourceOf(annotations map[string]string, annotationNames ...string) string {
+	for _, name := range annotationNames {
+		if _, exists := annotations[name]; exists {
+			return configSourceAnnotation
+		}
+	}
+	return configSourceDefault
+}
+
+func ipCountAttributes(prefix string, ips IPStatus) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Int(prefix+".ingress_ips", len(ips.IngressIPs)),
+		attribute.Int(prefix+".external_ips", len(ips.ExternalIPs)),
+	}
+}
+
+// addExplanationEvents records steps of explanation as events of span, which tell how IPs are computed.
+func addExplanationEvents(span trace.Span, explanation staticlb.Trace) {
+	for _, step := range explanation {
+		span.AddEvent(step.Message, trace.WithAttributes(attribute.String("static_lb.stage", string(step.Stage))))
+	}
+}
+
+// endSpan ends span, recording err if it is not nil.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
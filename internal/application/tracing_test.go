@@ -0,0 +1,104 @@
+package application
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	spanRecorder    = tracetest.NewSpanRecorder()
+	recordSpansOnce sync.Once
+)
+
+// recordSpans sets the global TracerProvider to record spans to spanRecorder, which the global tracer delegates to
+// only once.
+func recordSpans() {
+	recordSpansOnce.Do(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+	})
+}
+
+// recordedSpansOf returns spans ended in the trace of the AssignIPs span of the Service named name, by their names.
+func recordedSpansOf(t *testing.T, name string) map[string]sdktrace.ReadOnlySpan {
+	t.Helper()
+
+	ended := spanRecorder.Ended()
+	var root sdktrace.ReadOnlySpan
+	for _, span := range ended {
+		for _, attr := range span.Attributes() {
+			if span.Name() == "AssignIPs" && attr.Key == "service.name" && attr.Value.AsString() == name {
+				root = span
+			}
+		}
+	}
+	require.NotNil(t, root, "AssignIPs is traced")
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range ended {
+		if span.SpanContext().TraceID() == root.SpanContext().TraceID() {
+			spans[span.Name()] = span
+		}
+	}
+	return spans
+}
+
+func attributeOf(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, attr := range span.Attributes() {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestUsecase_AssignIPs_spans(t *testing.T) {
+	t.Parallel()
+	recordSpans()
+
+	svc := corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "traced"},
+		Spec: corev1.ServiceSpec{
+			Type:                  corev1.ServiceTypeLoadBalancer,
+			ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyTypeLocal,
+		},
+		Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{
+			Ingress: []corev1.LoadBalancerIngress{{IP: "192.168.0.9"}},
+		}},
+	}
+	u := New(Options{
+		EndpointSlices:     fakeEndpointSliceRepository{},
+		Nodes:              fakeNodeRepository{},
+		Services:           &recordingServiceRepository{},
+		EventRecorder:      &fakeEventRecorder{},
+		InternalIPMappings: []IPMappingTarget{IPMappingTargetIngress},
+		EmptyIPsPolicy:     EmptyIPsPolicy{Mode: EmptyIPsModeKeepLast},
+	})
+
+	_, err := u.AssignIPs(context.Background(), svc)
+	require.NoError(t, err)
+
+	spans := recordedSpansOf(t, "traced")
+	for _, name := range []string{"compute", "filter", "node-ports-policy", "port-conflicts", "stabilize", "write"} {
+		assert.Contains(t, spans, name)
+	}
+	filter := spans["filter"].SpanContext().SpanID()
+	assert.Equal(t, filter, spans["node-ports-policy"].Parent().SpanID())
+	assert.Equal(t, filter, spans["port-conflicts"].Parent().SpanID())
+	assert.True(t, attributeOf(spans["node-ports-policy"], "static_lb.ports_reachable").AsBool())
+
+	// no candidate remains, so the last IPs are kept after filtering
+	assert.Equal(t, int64(0), attributeOf(spans["filter"], "static_lb.filtered.ingress_ips").AsInt64())
+	assert.Equal(t, int64(1), attributeOf(spans["stabilize"], "static_lb.stabilized.ingress_ips").AsInt64())
+	assert.True(t, attributeOf(spans["stabilize"], "static_lb.hold_last_ips").AsBool())
+}
@@ -1,11 +1,88 @@
 package application
 
-type IPStatus struct {
+import (
+	"net"
+	"time"
+
+	"github.com/isac322/static-lb/pkg/staticlb"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Types of the IP pipeline are defined by the public package staticlb, which the usecase wraps.
+type (
+	IPStatus          = staticlb.IPStatus
+	NodeIPs           = staticlb.NodeIPs
+	NodeAddresses     = staticlb.NodeAddresses
+	IPMappingPriority = staticlb.IPMappingPriority
+	NATRule           = staticlb.NATRule
+	Topology          = staticlb.Topology
+	// AddressSource reads addresses of a type from nodes. Custom sources are compiled in by registering them with
+	// staticlb.RegisterAddressSource.
+	AddressSource = staticlb.AddressSource
+	// AddressSources are AddressSources by the NodeAddressType that mappings refer to.
+	AddressSources = staticlb.AddressSources
+)
+
+// IPChange describes IPs of a Service that are changed by static-lb.
+type IPChange struct {
+	Service types.NamespacedName
+	Old     IPStatus
+	New     IPStatus
+	// Reason is the reason of the IPsAssigned condition, or EventReasonDriftCorrected if IPs were modified by
+	// someone else and put back.
+	Reason string
+}
+
+// InventoryEntry is what a managed Service is published on.
+type InventoryEntry struct {
+	Service     types.NamespacedName
 	IngressIPs  []string
 	ExternalIPs []string
+	Ports       []InventoryPort
+	// Nodes are names of nodes whose addresses are the candidates of the IPs.
+	Nodes []string
 }
 
-type NodeIPs struct {
-	InternalIPs []string
-	ExternalIPs []string
+type InventoryPort struct {
+	Name     string
+	Protocol string
+	Port     int32
+	NodePort int32
+}
+
+type EmptyIPsPolicy struct {
+	Mode    EmptyIPsMode
+	HoldFor time.Duration
+}
+
+// NodePortsPolicy decides how IPs are published when ports of a Service are not reachable through node IPs.
+type NodePortsPolicy struct {
+	// NoNodePorts applies to LoadBalancer Services whose node ports are not allocated.
+	NoNodePorts NoNodePortsMode
+	// RefuseConflicts drops external IPs of nodes whose ports are node ports of other Services, instead of only
+	// warning, unless LabelNodePortConflictPolicy overrides it.
+	RefuseConflicts bool
+}
+
+// ClusterNetworks excludes networks internal to the cluster from IPs, which some CNIs report as addresses of nodes.
+type ClusterNetworks struct {
+	Exclude bool
+	// ServiceCIDRs are excluded as a whole. Only ClusterIPs of the kubernetes Service are excluded if empty, since
+	// the prefix length of the Service CIDR is not discoverable.
+	ServiceCIDRs []*net.IPNet
+}
+
+type AssignResult struct {
+	// RequeueAfter is non-zero when the Service has to be reconciled again after the duration
+	// even if nothing changes in the cluster.
+	RequeueAfter time.Duration
+}
+
+// IngressControllerPods selects Pods of an ingress controller that runs on host network.
+type IngressControllerPods struct {
+	// Namespace of the Pods. Every namespace is searched if empty.
+	Namespace string
+	Selector  labels.Selector
 }
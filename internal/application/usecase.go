@@ -3,76 +3,416 @@ package application
 import (
 	"context"
 	"net"
+	"time"
 
 	"github.com/isac322/static-lb/internal/pkg/slices"
 
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
 )
 
 type Usecase interface {
-	AssignIPs(ctx context.Context, svc corev1.Service) error
+	AssignIPs(ctx context.Context, svc corev1.Service) (AssignResult, error)
+	// ResolveIPs computes IPs of svc like AssignIPs, without writing them.
+	// Delays of IP changes and the empty IPs policy are not applied, since they depend on what is assigned.
+	ResolveIPs(ctx context.Context, svc corev1.Service) (IPStatus, error)
+	AssignGatewayAddresses(ctx context.Context, gw gatewayv1.Gateway) error
+	AssignIngressIPs(ctx context.Context, ing networkingv1.Ingress) error
+	// Forget drops every state kept in memory for the Service, e.g. when it is moved to another shard.
+	Forget(svcKey types.NamespacedName)
+	// Remove forgets the Service and deletes it from the inventory. It is called once the Service is deleted.
+	Remove(ctx context.Context, svcKey types.NamespacedName) error
+	// PruneInventory deletes entries of Services that are gone from the inventory. It is called once at startup.
+	PruneInventory(ctx context.Context) error
+	// ObserveService indexes addresses that svc is published on, to detect conflicts of ports with it. It is called
+	// for every change of every Service, including ones that are not assigned by this shard.
+	ObserveService(svc corev1.Service)
+	// ObserveServiceDeletion drops addresses of the deleted Service from the index of ObserveService.
+	ObserveServiceDeletion(svcKey types.NamespacedName)
+	// InvalidateReservedIPNets drops cached networks internal to the cluster, e.g. pod CIDRs of nodes, so that they are
+	// read again. It is called whenever nodes are added or removed, or their pod CIDRs change.
+	InvalidateReservedIPNets()
 }
 
 type usecase struct {
 	endpointSliceRepo               EndpointSliceRepository
 	nodeRepo                        NodeRepository
 	serviceRepo                     ServiceRepository
+	gatewayRepo                     GatewayRepository
+	podRepo                         PodRepository
+	ingressRepo                     IngressRepository
+	inventoryRepo                   InventoryRepository
+	eventRecorder                   EventRecorder
+	metrics                         MetricsRecorder
+	assignmentHooks                 []AssignmentHook
 	defaultInternalIPMappings       []IPMappingTarget
 	defaultExternalIPMappings       []IPMappingTarget
+	defaultIPMappingPriorities      []IPMappingPriority
+	defaultNodeAddressSelection     NodeAddressSelection
 	defaultIncludeIngressIPNetwork  []*net.IPNet
 	defaultIncludeExternalIPNetwork []*net.IPNet
 	defaultExcludeIngressIPNetwork  []*net.IPNet
 	defaultExcludeExternalIPNetwork []*net.IPNet
+	defaultNATRules                 []NATRule
+	defaultDropUntranslatedIPs      bool
+	clusterNetworks                 ClusterNetworks
+	defaultTopology                 Topology
+	defaultEmptyIPsPolicy           EmptyIPsPolicy
+	defaultIPAddDelay               time.Duration
+	defaultIPRemoveDelay            time.Duration
+	defaultNodePortsPolicy          NodePortsPolicy
+	defaultPortConflictPolicy       PortConflictPolicy
+	ingressControllerPods           IngressControllerPods
+	addressSources                  AddressSources
+	portClaimsChanged               func(svcKey types.NamespacedName)
+	instanceName                    string
+	emptySince                      *emptySinceTracker
+	reserved                        *reservedIPNetCache
+	pendingIPs                      *pendingIPTracker
+	lastAssigned                    *lastAssignmentTracker
+	inventory                       *inventoryTracker
+	ports                           *portIndex
+	nodePorts                       *nodePortIndex
+}
+
+// Options configures the usecase. Repositories that a usecase does not use may be nil, e.g. the cloud provider
+// only resolves IPs of Services from nodes and EndpointSlices. Defaults apply to objects that do not override them
+// with annotations.
+type Options struct {
+	EndpointSlices  EndpointSliceRepository
+	Nodes           NodeRepository
+	Services        ServiceRepository
+	Gateways        GatewayRepository
+	Pods            PodRepository
+	Ingresses       IngressRepository
+	Inventory       InventoryRepository
+	EventRecorder   EventRecorder
+	Metrics         MetricsRecorder
+	AssignmentHooks []AssignmentHook
+
+	InternalIPMappings    []IPMappingTarget
+	ExternalIPMappings    []IPMappingTarget
+	IPMappingPriorities   []IPMappingPriority
+	NodeAddressSelection  NodeAddressSelection
+	IncludeIngressIPNets  []*net.IPNet
+	IncludeExternalIPNets []*net.IPNet
+	ExcludeIngressIPNets  []*net.IPNet
+	ExcludeExternalIPNets []*net.IPNet
+	NATRules              []NATRule
+	DropUntranslatedIPs   bool
+	ClusterNetworks       ClusterNetworks
+	Topology              Topology
+	EmptyIPsPolicy        EmptyIPsPolicy
+	IPAddDelay            time.Duration
+	IPRemoveDelay         time.Duration
+	NodePortsPolicy       NodePortsPolicy
+	PortConflictPolicy    PortConflictPolicy
+
+	// IngressControllerPods selects Pods whose nodes serve Ingresses.
+	IngressControllerPods IngressControllerPods
+	// AddressSources read addresses of nodes. Every registered AddressSource is read if it is nil.
+	AddressSources AddressSources
+	// PortClaimsChanged, if set, is called with Services whose conflicts of ports may have changed, which are to be
+	// assigned again.
+	PortClaimsChanged func(svcKey types.NamespacedName)
+	// InstanceName is the name of this instance, which reads only objects bound to it. Empty for the default one.
+	InstanceName string
 }
 
-func New(
-	esr EndpointSliceRepository,
-	nr NodeRepository,
-	sr ServiceRepository,
-	defaultInternalIPMappings []IPMappingTarget,
-	defaultExternalIPMappings []IPMappingTarget,
-	defaultIncludeIngressIPNetwork []*net.IPNet,
-	defaultIncludeExternalIPNetwork []*net.IPNet,
-	defaultExcludeIngressIPNetwork []*net.IPNet,
-	defaultExcludeExternalIPNetwork []*net.IPNet,
-) Usecase {
+func New(opts Options) Usecase {
 	return usecase{
-		endpointSliceRepo:               esr,
-		nodeRepo:                        nr,
-		serviceRepo:                     sr,
-		defaultInternalIPMappings:       defaultInternalIPMappings,
-		defaultExternalIPMappings:       defaultExternalIPMappings,
-		defaultIncludeIngressIPNetwork:  defaultIncludeIngressIPNetwork,
-		defaultIncludeExternalIPNetwork: defaultIncludeExternalIPNetwork,
-		defaultExcludeIngressIPNetwork:  defaultExcludeIngressIPNetwork,
-		defaultExcludeExternalIPNetwork: defaultExcludeExternalIPNetwork,
+		endpointSliceRepo:               opts.EndpointSlices,
+		nodeRepo:                        opts.Nodes,
+		serviceRepo:                     opts.Services,
+		gatewayRepo:                     opts.Gateways,
+		podRepo:                         opts.Pods,
+		ingressRepo:                     opts.Ingresses,
+		inventoryRepo:                   opts.Inventory,
+		eventRecorder:                   opts.EventRecorder,
+		metrics:                         opts.Metrics,
+		assignmentHooks:                 opts.AssignmentHooks,
+		defaultInternalIPMappings:       opts.InternalIPMappings,
+		defaultExternalIPMappings:       opts.ExternalIPMappings,
+		defaultIPMappingPriorities:      opts.IPMappingPriorities,
+		defaultNodeAddressSelection:     opts.NodeAddressSelection,
+		defaultIncludeIngressIPNetwork:  opts.IncludeIngressIPNets,
+		defaultIncludeExternalIPNetwork: opts.IncludeExternalIPNets,
+		defaultExcludeIngressIPNetwork:  opts.ExcludeIngressIPNets,
+		defaultExcludeExternalIPNetwork: opts.ExcludeExternalIPNets,
+		defaultNATRules:                 opts.NATRules,
+		defaultDropUntranslatedIPs:      opts.DropUntranslatedIPs,
+		clusterNetworks:                 opts.ClusterNetworks,
+		defaultTopology:                 opts.Topology,
+		defaultEmptyIPsPolicy:           opts.EmptyIPsPolicy,
+		defaultIPAddDelay:               opts.IPAddDelay,
+		defaultIPRemoveDelay:            opts.IPRemoveDelay,
+		defaultNodePortsPolicy:          opts.NodePortsPolicy,
+		defaultPortConflictPolicy:       opts.PortConflictPolicy,
+		ingressControllerPods:           opts.IngressControllerPods,
+		addressSources:                  opts.AddressSources,
+		portClaimsChanged:               opts.PortClaimsChanged,
+		instanceName:                    opts.InstanceName,
+		emptySince:                      newEmptySinceTracker(),
+		reserved:                        newReservedIPNetCache(),
+		pendingIPs:                      newPendingIPTracker(),
+		lastAssigned:                    newLastAssignmentTracker(),
+		inventory:                       newInventoryTracker(),
+		ports:                           newPortIndex(),
+		nodePorts:                       newNodePortIndex(),
 	}
 }
 
-func (u usecase) AssignIPs(ctx context.Context, svc corev1.Service) (err error) {
-	nodeIPs, err := u.collectNodeIPs(ctx, svc)
+func (u usecase) AssignIPs(ctx context.Context, svc corev1.Service) (result AssignResult, err error) {
+	ctx, span := tracer.Start(ctx, "AssignIPs", trace.WithAttributes(
+		attribute.String("service.namespace", svc.Namespace),
+		attribute.String("service.name", svc.Name),
+		attribute.String("service.type", string(svc.Spec.Type)),
+		attribute.String("service.external_traffic_policy", string(svc.Spec.ExternalTrafficPolicy)),
+	))
+	defer func() { endSpan(span, err) }()
+
+	svcKey := types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name}
+	if !isBoundTo(svc.Annotations, u.instanceName) {
+		u.forgetAssignment(svcKey)
+		u.releasePorts(svcKey)
+		return AssignResult{}, u.deleteFromInventory(ctx, svcKey)
+	}
+
+	// scoped is only read; svc is written back as is
+	scoped := svc
+	scoped.Annotations = scopeAnnotations(svc.Annotations, u.instanceName)
+	if isFrozen(scoped) {
+		span.SetAttributes(attribute.Bool("static_lb.frozen", true))
+		u.forgetAssignment(svcKey)
+		return AssignResult{}, u.deleteFromInventory(ctx, svcKey)
+	}
+
+	computeCtx, computeSpan := tracer.Start(ctx, "compute")
+	computed, err := u.computeIPs(computeCtx, scoped)
+	if err == nil {
+		addExplanationEvents(computeSpan, computed.Trace)
+		computeSpan.SetAttributes(
+			attribute.Int("static_lb.nodes", len(computed.NodeIPs.Nodes)),
+			attribute.Int("static_lb.internal_ips", len(computed.NodeIPs.IPsOf(NodeAddressTypeInternal))),
+			attribute.Int("static_lb.external_ips", len(computed.NodeIPs.IPsOf(NodeAddressTypeExternal))),
+			attribute.String("static_lb.config_source", configSourceOf(
+				scoped.Annotations,
+				LabelIPMappings,
+				LabelInternalIPMappings,
+				LabelExternalIPMappings,
+				LabelNodeAddressSelection,
+				LabelNATRules,
+				LabelDropUntranslatedIPs,
+				LabelIncludeIngressIPNets,
+				LabelIncludeExternalIPNets,
+				LabelExcludeIngressIPNets,
+				LabelExcludeExternalIPNets,
+				LabelRequestedIPs,
+				LabelZones,
+				LabelRegions,
+				LabelMaxIPsPerZone,
+			)),
+		)
+		computeSpan.SetAttributes(ipCountAttributes("static_lb.candidate", computed.Candidates)...)
+		computeSpan.SetAttributes(ipCountAttributes("static_lb.computed", computed.IPs)...)
+	}
+	endSpan(computeSpan, err)
 	if err != nil {
-		return err
+		return AssignResult{}, err
 	}
-	if nodeIPs.IsNone() {
-		return nil
+	if computed.Skipped {
+		return AssignResult{}, nil
 	}
+	nodeIPs, mappedIPs, targetIPs := computed.NodeIPs, computed.Candidates, computed.IPs
 
-	targetIPs := u.mapIPs(nodeIPs.Unwrap(), svc)
-	targetIPs = u.filterTargetIPs(targetIPs, svc)
+	filterCtx, filterSpan := tracer.Start(ctx, "filter")
+	_, nodePortsSpan := tracer.Start(filterCtx, "node-ports-policy")
+	targetIPs, portsReachable := u.applyNodePortsPolicy(scoped, nodeIPs, targetIPs)
+	nodePortsSpan.SetAttributes(
+		attribute.Bool("static_lb.ports_reachable", portsReachable.Status == metav1.ConditionTrue),
+		attribute.String("static_lb.config_source", configSourceOf(
+			scoped.Annotations,
+			LabelNoNodePortsPolicy,
+			LabelNodePortConflictPolicy,
+		)),
+	)
+	nodePortsSpan.End()
+	_, conflictSpan := tracer.Start(filterCtx, "port-conflicts")
+	targetIPs, conflictFree := u.detectPortConflicts(scoped, targetIPs)
+	conflictSpan.SetAttributes(
+		attribute.Bool("static_lb.conflict_free", conflictFree.Status == metav1.ConditionTrue),
+		attribute.String("static_lb.config_source", configSourceOf(scoped.Annotations, LabelPortConflictPolicy)),
+	)
+	conflictSpan.End()
+	filterSpan.SetAttributes(ipCountAttributes("static_lb.filtered", targetIPs)...)
+	filterSpan.End()
 
-	if u.isSynced(svc, targetIPs) {
-		return nil
+	// debouncing and holding may put back IPs which are filtered out, so they are traced apart from filtering
+	_, stabilizeSpan := tracer.Start(ctx, "stabilize")
+	targetIPs, result.RequeueAfter = u.debounceIPs(scoped, targetIPs)
+	held := u.holdLastIPs(scoped, nodeIPs, mappedIPs, targetIPs)
+	if held.hold {
+		targetIPs = assignedIPs(svc)
+		result.RequeueAfter = minPositiveDuration(result.RequeueAfter, held.recheckAfter)
 	}
+	stabilizeSpan.SetAttributes(ipCountAttributes("static_lb.stabilized", targetIPs)...)
+	stabilizeSpan.SetAttributes(
+		attribute.String("static_lb.config_source", configSourceOf(
+			scoped.Annotations,
+			LabelIPAddDelay,
+			LabelIPRemoveDelay,
+			LabelEmptyIPsPolicy,
+		)),
+		attribute.Bool("static_lb.hold_last_ips", held.hold),
+	)
+	stabilizeSpan.End()
 
-	return u.serviceRepo.AssignIPs(ctx, svc, targetIPs)
+	_, syncSpan := tracer.Start(ctx, "sync-check")
+	conditions := serviceConditions(scoped, nodeIPs, mappedIPs, targetIPs, held.since)
+	if len(conditions) != 0 {
+		portsReachable.ObservedGeneration = svc.Generation
+		conflictFree.ObservedGeneration = svc.Generation
+		conditions = append(conditions, portsReachable, conflictFree)
+	}
+	synced := u.isSynced(svc, targetIPs)
+	conditionsSynced := isConditionsSynced(svc.Status.Conditions, conditions, ServiceConditionTypes)
+	syncSpan.SetAttributes(
+		attribute.Bool("static_lb.ips_synced", synced),
+		attribute.Bool("static_lb.conditions_synced", conditionsSynced),
+	)
+	syncSpan.End()
+	if synced && conditionsSynced {
+		u.lastAssigned.record(svcKey, targetIPs, targetIPs)
+		u.claimPorts(scoped, targetIPs)
+		return result, u.updateInventory(ctx, scoped, nodeIPs, targetIPs)
+	}
+
+	drifted := !synced && u.lastAssigned.drifted(svc)
+
+	writeCtx, writeSpan := tracer.Start(ctx, "write", trace.WithAttributes(attribute.Bool("static_lb.drifted", drifted)))
+	err = u.serviceRepo.AssignIPs(writeCtx, svc, targetIPs, conditions)
+	endSpan(writeSpan, err)
+	if err != nil {
+		// the spec may be written without the status, which is not what static-lb assigned nor what it replaced
+		u.lastAssigned.forget(svcKey)
+		return result, err
+	}
+	u.lastAssigned.record(svcKey, targetIPs, assignedIPs(svc))
+	u.claimPorts(scoped, targetIPs)
+	if held.expiredAfter > 0 {
+		u.recordAddressesCleared(svc, held.expiredAfter)
+	}
+	if err = u.updateInventory(ctx, scoped, nodeIPs, targetIPs); err != nil {
+		return result, err
+	}
+
+	if !synced {
+		u.notifyAssignment(ctx, svc, targetIPs, conditions, drifted)
+	}
+	if drifted {
+		u.recordEvent(
+			&svc,
+			corev1.EventTypeWarning,
+			EventReasonDriftCorrected,
+			"IPs were modified outside of static-lb, put them back",
+		)
+		if u.metrics != nil {
+			u.metrics.DriftCorrected(svcKey)
+		}
+	}
+	return result, nil
 }
 
+func (u usecase) ResolveIPs(ctx context.Context, svc corev1.Service) (IPStatus, error) {
+	if !isBoundTo(svc.Annotations, u.instanceName) {
+		return IPStatus{}, nil
+	}
+
+	// scoped is only read
+	scoped := svc
+	scoped.Annotations = scopeAnnotations(svc.Annotations, u.instanceName)
+	computed, err := u.computeIPs(ctx, scoped)
+	if err != nil || computed.Skipped {
+		return IPStatus{}, err
+	}
+	return computed.IPs, nil
+}
+
+// notifyAssignment runs every AssignmentHook for the change of IPs of svc to targetIPs.
+func (u usecase) notifyAssignment(
+	ctx context.Context,
+	svc corev1.Service,
+	targetIPs IPStatus,
+	conditions []metav1.Condition,
+	drifted bool,
+) {
+	if len(u.assignmentHooks) == 0 {
+		return
+	}
+
+	change := IPChange{
+		Service: types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name},
+		Old:     assignedIPs(svc),
+		New:     targetIPs,
+	}
+	if condition := meta.FindStatusCondition(conditions, ConditionTypeIPsAssigned); condition != nil {
+		change.Reason = condition.Reason
+	}
+	if drifted {
+		change.Reason = EventReasonDriftCorrected
+	}
+
+	for _, hook := range u.assignmentHooks {
+		if err := hook.IPsAssigned(ctx, change); err != nil {
+			logr.FromContextOrDiscard(ctx).Error(err, "unable to run post-assignment hook", "service", change.Service)
+		}
+	}
+}
+
+func (u usecase) Forget(svcKey types.NamespacedName) {
+	u.forgetAssignment(svcKey)
+	u.inventory.forget(svcKey)
+}
+
+// forgetAssignment drops states of assigning IPs to the Service, but keeps what is written to the inventory for it,
+// so that deleting it from the inventory again is skipped. Addresses that the Service is published on are kept,
+// since they are still published, e.g. by another shard.
+func (u usecase) forgetAssignment(svcKey types.NamespacedName) {
+	u.emptySince.forget(svcKey)
+	u.pendingIPs.forget(svcKey)
+	u.lastAssigned.forget(svcKey)
+	u.ports.forgetWanted(svcKey)
+}
+
+func (u usecase) Remove(ctx context.Context, svcKey types.NamespacedName) error {
+	u.releasePorts(svcKey)
+	// the inventory is checked before it is forgotten, to skip deleting Services that are not in it
+	if err := u.deleteFromInventory(ctx, svcKey); err != nil {
+		u.forgetAssignment(svcKey)
+		return err
+	}
+	u.Forget(svcKey)
+	return nil
+}
+
+// isSynced reports whether svc is assigned targetIPs in the same order, since IPs are ordered zone by zone.
 func (u usecase) isSynced(svc corev1.Service, targetIPs IPStatus) bool {
-	origIngressIPs := make([]string, len(svc.Status.LoadBalancer.Ingress))
-	for i, ingress := range svc.Status.LoadBalancer.Ingress {
-		origIngressIPs[i] = ingress.IP
+	origIPs := assignedIPs(svc)
+	return slices.Equal(targetIPs.ExternalIPs, origIPs.ExternalIPs) &&
+		slices.Equal(targetIPs.IngressIPs, origIPs.IngressIPs)
+}
+
+func minPositiveDuration(a, b time.Duration) time.Duration {
+	if a <= 0 || (b > 0 && b < a) {
+		return b
 	}
-	return slices.Match(targetIPs.ExternalIPs, svc.Spec.ExternalIPs) &&
-		slices.Match(targetIPs.IngressIPs, origIngressIPs)
+	return a
 }
@@ -2,108 +2,127 @@ package application
 
 import (
 	"context"
+	"sort"
+	"strings"
 
 	"github.com/isac322/static-lb/internal/pkg/optional"
+	"github.com/isac322/static-lb/pkg/staticlb"
 
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/api/discovery/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 )
 
-func (u usecase) collectNodeIPs(ctx context.Context, svc corev1.Service) (optional.Option[NodeIPs], error) {
-	switch {
-	case svc.Spec.Type != corev1.ServiceTypeLoadBalancer:
-		// reset ips
-		return optional.Some(NodeIPs{}), nil
-
-	case svc.Spec.ExternalTrafficPolicy == corev1.ServiceExternalTrafficPolicyTypeLocal:
-		nodeIPs, err := u.getIPsFromEndpointSlice(ctx, svc)
-		if err != nil {
-			return optional.None[NodeIPs](), err
-		}
-		return optional.Some(nodeIPs), nil
+// computeIPs runs the pipeline of staticlb for svc, with its EndpointSlices, nodes that may receive its traffic and
+// networks reserved in the cluster.
+func (u usecase) computeIPs(ctx context.Context, svc corev1.Service) (staticlb.Result, error) {
+	cfg := u.pipelineConfig()
+	if svc.Spec.Type != corev1.ServiceTypeLoadBalancer {
+		return staticlb.Compute(svc, nil, nil, cfg), nil
+	}
 
-	case svc.Spec.ExternalTrafficPolicy == corev1.ServiceExternalTrafficPolicyTypeCluster:
-		endpoints, err := u.getServingEndpointsFromSlice(ctx, svc)
-		if err != nil {
-			return optional.None[NodeIPs](), err
-		}
-		if len(endpoints) == 0 {
-			return optional.Some(NodeIPs{}), nil
-		}
+	endpointSlices, nodes, err := u.listServingNodes(ctx, svc)
+	if err != nil {
+		return staticlb.Result{}, err
+	}
+	if cfg.ReservedIPNets, err = u.reservedIPNets(ctx); err != nil {
+		return staticlb.Result{}, err
+	}
+	return staticlb.Compute(svc, endpointSlices, nodes, cfg), nil
+}
 
-		nodeIPs, err := u.getIPsFromAllNodes(ctx)
-		if err != nil {
-			return optional.None[NodeIPs](), err
-		}
-		return optional.Some(nodeIPs), nil
+// collectNodeIPsByTrafficPolicy collects IPs of nodes that can receive external traffic of svc,
+// regardless of the type of svc.
+func (u usecase) collectNodeIPsByTrafficPolicy(
+	ctx context.Context,
+	svc corev1.Service,
+) (optional.Option[NodeIPs], error) {
+	endpointSlices, nodes, err := u.listServingNodes(ctx, svc)
+	if err != nil {
+		return optional.None[NodeIPs](), err
+	}
 
-	default:
-		// Ignore others
+	nodeIPs, ok, _ := u.sources().CollectNodeIPs(svc, endpointSlices, nodes)
+	if !ok {
 		return optional.None[NodeIPs](), nil
 	}
+	return optional.Some(nodeIPs), nil
 }
 
-func (u usecase) getIPsFromEndpointSlice(ctx context.Context, svc corev1.Service) (NodeIPs, error) {
-	endpoints, err := u.getServingEndpointsFromSlice(ctx, svc)
+// listServingNodes lists EndpointSlices of svc and nodes that may receive its traffic by its external traffic
+// policy: nodes of serving endpoints for Local, and every ready node for Cluster unless no endpoint is serving.
+func (u usecase) listServingNodes(
+	ctx context.Context,
+	svc corev1.Service,
+) ([]discoveryv1.EndpointSlice, []corev1.Node, error) {
+	endpointSlices, err := u.listEndpointSlices(ctx, svc)
 	if err != nil {
-		return NodeIPs{}, err
+		return nil, nil, err
 	}
 
-	nodes := make([]corev1.Node, 0, len(endpoints))
-	for _, endpoint := range endpoints {
-		node, err := u.nodeRepo.GetByName(ctx, *endpoint.NodeName)
-		if err != nil {
-			return NodeIPs{}, err
+	var nodes []corev1.Node
+	switch servingNodes := staticlb.ServingNodeNames(endpointSlices); svc.Spec.ExternalTrafficPolicy {
+	case corev1.ServiceExternalTrafficPolicyTypeLocal:
+		nodes, err = u.getNodesByNames(ctx, &svc, servingNodes)
+	case corev1.ServiceExternalTrafficPolicyTypeCluster:
+		if len(servingNodes) != 0 {
+			nodes, err = u.nodeRepo.ListReady(ctx)
 		}
-		nodes = append(nodes, node)
 	}
-
-	return u.extractIPsFrom(nodes), err
+	if err != nil {
+		return nil, nil, err
+	}
+	return endpointSlices, nodes, nil
 }
 
-func (u usecase) getServingEndpointsFromSlice(ctx context.Context, svc corev1.Service) ([]v1.Endpoint, error) {
-	endpointSliceList, err := u.endpointSliceRepo.ListLinkedTo(ctx, types.NamespacedName{
-		Namespace: svc.Namespace,
-		Name:      svc.Name,
-	})
+// getNodesByNames fetches nodes of names at once, skipping duplicated names. Nodes that do not exist are skipped with
+// a warning event on owner.
+func (u usecase) getNodesByNames(ctx context.Context, owner runtime.Object, names []string) ([]corev1.Node, error) {
+	uniqueNames := make([]string, 0, len(names))
+	visited := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		if _, exists := visited[name]; exists || name == "" {
+			continue
+		}
+		visited[name] = struct{}{}
+		uniqueNames = append(uniqueNames, name)
+	}
+
+	nodes, missing, err := u.nodeRepo.ListByNames(ctx, uniqueNames)
 	if err != nil {
 		return nil, err
 	}
-
-	var endpoints []v1.Endpoint
-	for _, endpointSlice := range endpointSliceList.Items {
-		for _, endpoint := range endpointSlice.Endpoints {
-			if endpoint.Conditions.Serving == nil || !*endpoint.Conditions.Serving || endpoint.NodeName == nil {
-				continue
-			}
-
-			endpoints = append(endpoints, endpoint)
-		}
+	if len(missing) != 0 {
+		sort.Strings(missing)
+		u.recordEvent(
+			owner,
+			corev1.EventTypeWarning,
+			EventReasonNodeNotFound,
+			"skipping nodes that are not found: %s",
+			strings.Join(missing, ", "),
+		)
 	}
 
-	return endpoints, nil
+	return nodes, nil
 }
 
-func (u usecase) getIPsFromAllNodes(ctx context.Context) (NodeIPs, error) {
-	nodes, err := u.nodeRepo.ListReady(ctx)
+func (u usecase) listEndpointSlices(ctx context.Context, svc corev1.Service) ([]discoveryv1.EndpointSlice, error) {
+	endpointSliceList, err := u.endpointSliceRepo.ListLinkedTo(ctx, types.NamespacedName{
+		Namespace: svc.Namespace,
+		Name:      svc.Name,
+	})
 	if err != nil {
-		return NodeIPs{}, err
+		return nil, err
 	}
 
-	return u.extractIPsFrom(nodes), nil
+	return endpointSliceList.Items, nil
 }
 
-func (u usecase) extractIPsFrom(nodes []corev1.Node) (result NodeIPs) {
-	for _, node := range nodes {
-		for _, address := range node.Status.Addresses {
-			switch address.Type {
-			case corev1.NodeInternalIP:
-				result.InternalIPs = append(result.InternalIPs, address.Address)
-			case corev1.NodeExternalIP:
-				result.ExternalIPs = append(result.ExternalIPs, address.Address)
-			}
-		}
+// sources returns AddressSources of the usecase, or every registered AddressSource if it has none.
+func (u usecase) sources() AddressSources {
+	if u.addressSources == nil {
+		return staticlb.RegisteredAddressSources()
 	}
-	return result
+	return u.addressSources
 }
@@ -0,0 +1,218 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeEndpointSliceRepository struct {
+	slices discoveryv1.EndpointSliceList
+}
+
+func (f fakeEndpointSliceRepository) ListLinkedTo(
+	context.Context,
+	types.NamespacedName,
+) (discoveryv1.EndpointSliceList, error) {
+	return f.slices, nil
+}
+
+type fakeNodeRepository struct {
+	nodes map[string]corev1.Node
+}
+
+func (f fakeNodeRepository) List(context.Context) ([]corev1.Node, error) {
+	nodes := make([]corev1.Node, 0, len(f.nodes))
+	for _, node := range f.nodes {
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+func (f fakeNodeRepository) ListByNames(
+	_ context.Context,
+	names []string,
+) (nodes []corev1.Node, missing []string, err error) {
+	for _, name := range names {
+		node, exists := f.nodes[name]
+		if !exists {
+			missing = append(missing, name)
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, missing, nil
+}
+
+func (f fakeNodeRepository) ListReady(ctx context.Context) ([]corev1.Node, error) {
+	return f.ListReadyMatching(ctx, labels.Everything())
+}
+
+func (f fakeNodeRepository) ListReadyMatching(context.Context, labels.Selector) ([]corev1.Node, error) {
+	nodes := make([]corev1.Node, 0, len(f.nodes))
+	for _, node := range f.nodes {
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+func newFakeNode(name, internalIP string) corev1.Node {
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Addresses:  []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: internalIP}},
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+		},
+	}
+}
+
+func newFakeEndpointSlice(nodeNames ...string) discoveryv1.EndpointSlice {
+	serving := true
+	endpoints := make([]discoveryv1.Endpoint, 0, len(nodeNames))
+	for _, nodeName := range nodeNames {
+		nodeName := nodeName
+		endpoints = append(endpoints, discoveryv1.Endpoint{
+			Conditions: discoveryv1.EndpointConditions{Serving: &serving},
+			NodeName:   &nodeName,
+		})
+	}
+	return discoveryv1.EndpointSlice{Endpoints: endpoints}
+}
+
+func TestUsecase_listServingNodes(t *testing.T) {
+	t.Parallel()
+
+	nodeRepo := fakeNodeRepository{nodes: map[string]corev1.Node{
+		"node-a": newFakeNode("node-a", "192.168.0.1"),
+		"node-b": newFakeNode("node-b", "192.168.0.2"),
+	}}
+
+	tests := []struct {
+		name           string
+		policy         corev1.ServiceExternalTrafficPolicyType
+		nodeNames      []string
+		expected       []string
+		expectedEvents []string
+	}{
+		{
+			name:      "Local: duplicated nodes",
+			policy:    corev1.ServiceExternalTrafficPolicyTypeLocal,
+			nodeNames: []string{"node-a", "node-b", "node-a", "node-b"},
+			expected:  []string{"node-a", "node-b"},
+		},
+		{
+			name:           "Local: missing node is skipped",
+			policy:         corev1.ServiceExternalTrafficPolicyTypeLocal,
+			nodeNames:      []string{"node-a", "node-gone"},
+			expected:       []string{"node-a"},
+			expectedEvents: []string{EventReasonNodeNotFound},
+		},
+		{
+			name:           "Local: every node is missing",
+			policy:         corev1.ServiceExternalTrafficPolicyTypeLocal,
+			nodeNames:      []string{"node-gone"},
+			expected:       nil,
+			expectedEvents: []string{EventReasonNodeNotFound},
+		},
+		{
+			name:      "Cluster: every ready node",
+			policy:    corev1.ServiceExternalTrafficPolicyTypeCluster,
+			nodeNames: []string{"node-a"},
+			expected:  []string{"node-a", "node-b"},
+		},
+		{
+			name:     "Cluster: no serving endpoints",
+			policy:   corev1.ServiceExternalTrafficPolicyTypeCluster,
+			expected: nil,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := &fakeEventRecorder{}
+			u := usecase{
+				endpointSliceRepo: fakeEndpointSliceRepository{slices: discoveryv1.EndpointSliceList{
+					Items: []discoveryv1.EndpointSlice{newFakeEndpointSlice(tc.nodeNames...)},
+				}},
+				nodeRepo:      nodeRepo,
+				eventRecorder: recorder,
+			}
+
+			svc := corev1.Service{Spec: corev1.ServiceSpec{ExternalTrafficPolicy: tc.policy}}
+			_, nodes, err := u.listServingNodes(context.Background(), svc)
+			require.NoError(t, err)
+			var actual []string
+			for _, node := range nodes {
+				actual = append(actual, node.Name)
+			}
+			sort.Strings(actual)
+			assert.Equal(t, tc.expected, actual)
+			assert.Equal(t, tc.expectedEvents, recorder.events)
+		})
+	}
+}
+
+func BenchmarkUsecase_listServingNodes(b *testing.B) {
+	const (
+		nodeCount     = 100
+		endpointCount = 1000
+		// maxEndpointsPerSlice is the default of the EndpointSlice controller.
+		maxEndpointsPerSlice = 100
+	)
+
+	nodes := make(map[string]corev1.Node, nodeCount)
+	for i := 0; i < nodeCount; i++ {
+		name := fmt.Sprintf("node-%d", i)
+		nodes[name] = newFakeNode(name, fmt.Sprintf("192.168.0.%d", i))
+	}
+
+	// endpoints of the Service spread over every node, in slices as the EndpointSlice controller splits them
+	var slices discoveryv1.EndpointSliceList
+	for i := 0; i < endpointCount; i += maxEndpointsPerSlice {
+		nodeNames := make([]string, 0, maxEndpointsPerSlice)
+		for j := i; j < i+maxEndpointsPerSlice; j++ {
+			nodeNames = append(nodeNames, fmt.Sprintf("node-%d", j%nodeCount))
+		}
+		slices.Items = append(slices.Items, newFakeEndpointSlice(nodeNames...))
+	}
+
+	u := usecase{
+		endpointSliceRepo: fakeEndpointSliceRepository{slices: slices},
+		nodeRepo:          fakeNodeRepository{nodes: nodes},
+		eventRecorder:     &fakeEventRecorder{},
+	}
+	for _, policy := range []corev1.ServiceExternalTrafficPolicyType{
+		corev1.ServiceExternalTrafficPolicyTypeLocal,
+		corev1.ServiceExternalTrafficPolicyTypeCluster,
+	} {
+		svc := corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "svc"},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer, ExternalTrafficPolicy: policy},
+		}
+		b.Run(string(policy), func(b *testing.B) {
+			ctx := context.Background()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, servingNodes, err := u.listServingNodes(ctx, svc)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(servingNodes) != nodeCount {
+					b.Fatalf("expected %d nodes, got %d", nodeCount, len(servingNodes))
+				}
+			}
+		})
+	}
+}
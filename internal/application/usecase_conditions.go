@@ -0,0 +1,248 @@
+package application
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/isac322/static-lb/pkg/staticlb"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ServiceConditionTypes lists every condition type that static-lb manages in status.conditions of Services.
+var ServiceConditionTypes = []string{
+	ConditionTypeIPsAssigned,
+	ConditionTypeConfigValid,
+	ConditionTypePortsReachable,
+	ConditionTypeConflictFree,
+}
+
+// serviceConditions describes how static-lb sees svc.
+// nodeIPs are collected candidates, mappedIPs are ones after mapping, and targetIPs are ones about to be assigned.
+// staleSince is when the last IP candidate vanished if the last known IPs are kept, or zero otherwise.
+func serviceConditions(
+	svc corev1.Service,
+	nodeIPs NodeIPs,
+	mappedIPs IPStatus,
+	targetIPs IPStatus,
+	staleSince time.Time,
+) []metav1.Condition {
+	if svc.Spec.Type != corev1.ServiceTypeLoadBalancer {
+		return nil
+	}
+
+	configValid := metav1.Condition{
+		Type:    ConditionTypeConfigValid,
+		Status:  metav1.ConditionTrue,
+		Reason:  ConditionReasonValid,
+		Message: "Every annotation of static-lb is valid",
+	}
+	if problems := validateAnnotations(svc.Annotations); len(problems) != 0 {
+		configValid.Status = metav1.ConditionFalse
+		configValid.Reason = ConditionReasonInvalidAnnotation
+		configValid.Message = strings.Join(problems, "; ")
+	}
+
+	unavailable := unavailableRequestedIPs(svc, targetIPs)
+	ipsAssigned := metav1.Condition{
+		Type:    ConditionTypeIPsAssigned,
+		Status:  metav1.ConditionTrue,
+		Reason:  ConditionReasonAssigned,
+		Message: "IPs are assigned",
+	}
+	switch {
+	case !staleSince.IsZero():
+		ipsAssigned.Status = metav1.ConditionFalse
+		ipsAssigned.Reason = ConditionReasonStaleAddresses
+		ipsAssigned.Message = staleAddressesMessage(staleSince)
+	case nodeIPs.IsEmpty():
+		ipsAssigned.Status = metav1.ConditionFalse
+		ipsAssigned.Reason = ConditionReasonNoEligibleNodes
+		ipsAssigned.Message = "No node is eligible to receive traffic"
+	case mappedIPs.IsEmpty():
+		ipsAssigned.Status = metav1.ConditionFalse
+		ipsAssigned.Reason = ConditionReasonNotMapped
+		ipsAssigned.Message = "No node IP is mapped to ingress or external IPs"
+	case targetIPs.IsEmpty() && len(unavailable) != 0:
+		ipsAssigned.Status = metav1.ConditionFalse
+		ipsAssigned.Reason = ConditionReasonRequestedIPUnavailable
+		ipsAssigned.Message = fmt.Sprintf("No requested IP is an eligible node IP: %s", strings.Join(unavailable, ","))
+	case targetIPs.IsEmpty():
+		ipsAssigned.Status = metav1.ConditionFalse
+		ipsAssigned.Reason = ConditionReasonFilteredToEmpty
+		ipsAssigned.Message = "Every node IP is filtered out"
+	default:
+		if missing := missingIPFamilies(svc.Spec.IPFamilies, targetIPs); len(missing) != 0 {
+			ipsAssigned.Reason = ConditionReasonPartialFamilyCoverage
+			ipsAssigned.Message = fmt.Sprintf("No IP is assigned for %s", strings.Join(missing, ","))
+		}
+		if len(unavailable) != 0 {
+			ipsAssigned.Message = fmt.Sprintf("%s, requested IPs are not eligible node IPs: %s",
+				ipsAssigned.Message, strings.Join(unavailable, ","))
+		}
+	}
+
+	conditions := []metav1.Condition{ipsAssigned, configValid}
+	for i := range conditions {
+		conditions[i].ObservedGeneration = svc.Generation
+	}
+	return conditions
+}
+
+func missingIPFamilies(families []corev1.IPFamily, ips IPStatus) (missing []string) {
+	covered := map[corev1.IPFamily]bool{}
+	for _, ip := range staticlb.ParseIPs(append(append([]string{}, ips.IngressIPs...), ips.ExternalIPs...)) {
+		if ip.To4() != nil {
+			covered[corev1.IPv4Protocol] = true
+		} else {
+			covered[corev1.IPv6Protocol] = true
+		}
+	}
+
+	for _, family := range families {
+		if !covered[family] {
+			missing = append(missing, string(family))
+		}
+	}
+	return missing
+}
+
+// validateAnnotations reports every malformed value of static-lb annotations, which are silently ignored otherwise.
+func validateAnnotations(annotations map[string]string) (problems []string) {
+	for _, name := range []string{
+		LabelIncludeIngressIPNets,
+		LabelIncludeExternalIPNets,
+		LabelExcludeIngressIPNets,
+		LabelExcludeExternalIPNets,
+	} {
+		val, exists := annotations[name]
+		if !exists || strings.TrimSpace(val) == "" {
+			continue
+		}
+		entries := strings.Split(strings.TrimSpace(val), ",")
+		valid := true
+		for _, s := range entries {
+			if _, err := staticlb.ParseIPNets([]string{s}); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: invalid IP network %q", name, s))
+				valid = false
+			}
+		}
+		// every entry is valid, so the list fails only if it expands to no network
+		if _, err := staticlb.ParseIPNets(entries); valid && err != nil {
+			problems = append(problems, fmt.Sprintf("%s: negated entries exclude every IP network", name))
+		}
+	}
+
+	if val, exists := annotations[LabelIPMappings]; exists && val != "" {
+		for _, s := range strings.Split(val, ",") {
+			if _, err := staticlb.ParseIPMappingPriority(s); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %s", LabelIPMappings, err))
+			}
+		}
+	}
+
+	if val, exists := annotations[LabelNodeAddressSelection]; exists {
+		if _, err := staticlb.ParseNodeAddressSelection(val); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", LabelNodeAddressSelection, err))
+		}
+	}
+
+	for _, name := range []string{LabelInternalIPMappings, LabelExternalIPMappings} {
+		val, exists := annotations[name]
+		if !exists || val == "" {
+			continue
+		}
+		for _, s := range strings.Split(val, ",") {
+			if t := IPMappingTarget(s); t != IPMappingTargetIngress && t != IPMappingTargetExternal {
+				problems = append(problems, fmt.Sprintf("%s: invalid mapping target %q", name, s))
+			}
+		}
+	}
+
+	if val, exists := annotations[LabelNATRules]; exists && strings.TrimSpace(val) != "" {
+		for _, s := range strings.Split(strings.TrimSpace(val), ",") {
+			if _, err := staticlb.ParseNATRule(s); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %s", LabelNATRules, err))
+			}
+		}
+	}
+
+	if val, exists := annotations[LabelDropUntranslatedIPs]; exists && val != "true" && val != "false" {
+		problems = append(problems, fmt.Sprintf("%s: must be true or false", LabelDropUntranslatedIPs))
+	}
+
+	if val, exists := annotations[LabelMaxIPsPerZone]; exists {
+		if i, err := strconv.Atoi(val); err != nil || i < 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be a non-negative integer", LabelMaxIPsPerZone))
+		}
+	}
+
+	if val, exists := annotations[LabelNoNodePortsPolicy]; exists {
+		if _, err := ParseNoNodePortsMode(val); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", LabelNoNodePortsPolicy, err))
+		}
+	}
+
+	if val, exists := annotations[LabelRequestedIPs]; exists {
+		for _, s := range strings.Split(val, ",") {
+			if net.ParseIP(strings.TrimSpace(s)) == nil {
+				problems = append(problems, fmt.Sprintf("%s: invalid IP address %q", LabelRequestedIPs, s))
+			}
+		}
+	}
+
+	if val, exists := annotations[LabelNodePortConflictPolicy]; exists {
+		if _, err := ParsePortConflictPolicy(val); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", LabelNodePortConflictPolicy, err))
+		}
+	}
+	if val, exists := annotations[LabelPortConflictPolicy]; exists {
+		if _, err := ParsePortConflictPolicy(val); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", LabelPortConflictPolicy, err))
+		}
+	}
+
+	if val, exists := annotations[LabelEmptyIPsPolicy]; exists {
+		if _, err := ParseEmptyIPsPolicy(val); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", LabelEmptyIPsPolicy, err))
+		}
+	}
+
+	for _, name := range []string{LabelIPAddDelay, LabelIPRemoveDelay} {
+		val, exists := annotations[name]
+		if !exists {
+			continue
+		}
+		if d, err := time.ParseDuration(val); err != nil || d < 0 {
+			problems = append(problems, fmt.Sprintf("%s: invalid duration %q", name, val))
+		}
+	}
+
+	return problems
+}
+
+// isConditionsSynced reports whether orig already has desired conditions of managedTypes.
+// A type of managedTypes that is missing in desired must be missing in orig as well.
+func isConditionsSynced(orig []metav1.Condition, desired []metav1.Condition, managedTypes []string) bool {
+	for _, conditionType := range managedTypes {
+		o := meta.FindStatusCondition(orig, conditionType)
+		d := meta.FindStatusCondition(desired, conditionType)
+		switch {
+		case o == nil && d == nil:
+			continue
+		case o == nil || d == nil:
+			return false
+		case o.Status != d.Status ||
+			o.Reason != d.Reason ||
+			o.Message != d.Message ||
+			o.ObservedGeneration != d.ObservedGeneration:
+			return false
+		}
+	}
+	return true
+}
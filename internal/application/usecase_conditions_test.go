@@ -0,0 +1,181 @@
+package application
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceConditions(t *testing.T) {
+	t.Parallel()
+
+	lbSvc := func(annotations map[string]string, families ...corev1.IPFamily) corev1.Service {
+		return corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Annotations: annotations},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer, IPFamilies: families},
+		}
+	}
+	nodeIPsOf := func(addressType NodeAddressType, ips ...string) NodeIPs {
+		return NodeIPs{Nodes: []NodeAddresses{{Addresses: map[NodeAddressType][]string{addressType: ips}}}}
+	}
+
+	tests := []struct {
+		name                string
+		svc                 corev1.Service
+		nodeIPs             NodeIPs
+		mappedIPs           IPStatus
+		targetIPs           IPStatus
+		staleSince          time.Time
+		expectedIPsAssigned string
+		expectedConfigValid string
+	}{
+		{
+			name:                "assigned",
+			svc:                 lbSvc(nil, corev1.IPv4Protocol),
+			nodeIPs:             nodeIPsOf(NodeAddressTypeExternal, "10.222.0.1"),
+			mappedIPs:           IPStatus{IngressIPs: []string{"10.222.0.1"}},
+			targetIPs:           IPStatus{IngressIPs: []string{"10.222.0.1"}},
+			expectedIPsAssigned: ConditionReasonAssigned,
+			expectedConfigValid: ConditionReasonValid,
+		},
+		{
+			name:                "no eligible nodes",
+			svc:                 lbSvc(nil),
+			expectedIPsAssigned: ConditionReasonNoEligibleNodes,
+			expectedConfigValid: ConditionReasonValid,
+		},
+		{
+			name:                "not mapped",
+			svc:                 lbSvc(nil),
+			nodeIPs:             nodeIPsOf(NodeAddressTypeInternal, "10.222.0.1"),
+			expectedIPsAssigned: ConditionReasonNotMapped,
+			expectedConfigValid: ConditionReasonValid,
+		},
+		{
+			name:                "filtered to empty",
+			svc:                 lbSvc(map[string]string{LabelExcludeIngressIPNets: "0.0.0.0/0"}),
+			nodeIPs:             nodeIPsOf(NodeAddressTypeExternal, "10.222.0.1"),
+			mappedIPs:           IPStatus{IngressIPs: []string{"10.222.0.1"}},
+			expectedIPsAssigned: ConditionReasonFilteredToEmpty,
+			expectedConfigValid: ConditionReasonValid,
+		},
+		{
+			name:                "requested IP unavailable",
+			svc:                 lbSvc(map[string]string{LabelRequestedIPs: "10.222.0.9"}),
+			nodeIPs:             nodeIPsOf(NodeAddressTypeExternal, "10.222.0.1"),
+			mappedIPs:           IPStatus{IngressIPs: []string{"10.222.0.1"}},
+			expectedIPsAssigned: ConditionReasonRequestedIPUnavailable,
+			expectedConfigValid: ConditionReasonValid,
+		},
+		{
+			name:                "partial family coverage",
+			svc:                 lbSvc(nil, corev1.IPv4Protocol, corev1.IPv6Protocol),
+			nodeIPs:             nodeIPsOf(NodeAddressTypeExternal, "10.222.0.1"),
+			mappedIPs:           IPStatus{IngressIPs: []string{"10.222.0.1"}},
+			targetIPs:           IPStatus{IngressIPs: []string{"10.222.0.1"}},
+			expectedIPsAssigned: ConditionReasonPartialFamilyCoverage,
+			expectedConfigValid: ConditionReasonValid,
+		},
+		{
+			name:                "stale",
+			svc:                 lbSvc(nil),
+			staleSince:          time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+			expectedIPsAssigned: ConditionReasonStaleAddresses,
+			expectedConfigValid: ConditionReasonValid,
+		},
+		{
+			name: "invalid annotation",
+			svc: lbSvc(map[string]string{
+				LabelIncludeIngressIPNets: "10.0.0.0/8,10.0.0.1",
+				LabelIPAddDelay:           "-1s",
+			}),
+			expectedIPsAssigned: ConditionReasonNoEligibleNodes,
+			expectedConfigValid: ConditionReasonInvalidAnnotation,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			actual := serviceConditions(tc.svc, tc.nodeIPs, tc.mappedIPs, tc.targetIPs, tc.staleSince)
+			assert.Len(t, actual, 2)
+			assert.Equal(t, ConditionTypeIPsAssigned, actual[0].Type)
+			assert.Equal(t, tc.expectedIPsAssigned, actual[0].Reason)
+			assert.Equal(t, ConditionTypeConfigValid, actual[1].Type)
+			assert.Equal(t, tc.expectedConfigValid, actual[1].Reason)
+		})
+	}
+}
+
+func TestServiceConditions_notLoadBalancer(t *testing.T) {
+	t.Parallel()
+
+	actual := serviceConditions(corev1.Service{}, NodeIPs{}, IPStatus{}, IPStatus{}, time.Time{})
+	assert.Empty(t, actual)
+}
+
+func TestValidateAnnotations(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		annotations   map[string]string
+		expectedCount int
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "valid",
+			annotations: map[string]string{
+				LabelIncludeIngressIPNets:   "10.0.0.0/8,2603:c022:8005:302::/64",
+				LabelExcludeIngressIPNets:   "",
+				LabelInternalIPMappings:     "ingress,external",
+				LabelEmptyIPsPolicy:         "hold-for=10s",
+				LabelIPRemoveDelay:          "30s",
+				LabelMaxIPsPerZone:          "2",
+				LabelNodePortConflictPolicy: "exclude",
+			},
+		},
+		{
+			name: "valid classes and negation",
+			annotations: map[string]string{
+				LabelIncludeExternalIPNets: "public, !203.0.113.0/24",
+				LabelExcludeIngressIPNets:  "!private",
+			},
+		},
+		{
+			name: "invalid classes and negation",
+			annotations: map[string]string{
+				LabelIncludeExternalIPNets: "global,!",
+				LabelIncludeIngressIPNets:  "ula,!ipv6",
+			},
+			expectedCount: 3,
+		},
+		{
+			name: "invalid",
+			annotations: map[string]string{
+				LabelIncludeIngressIPNets:   "10.0.0.0/8,10.0.0.1",
+				LabelExternalIPMappings:     "internal",
+				LabelEmptyIPsPolicy:         "hold-for",
+				LabelIPAddDelay:             "soon",
+				LabelMaxIPsPerZone:          "-1",
+				LabelNodePortConflictPolicy: "refuse",
+			},
+			expectedCount: 6,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Len(t, validateAnnotations(tc.annotations), tc.expectedCount)
+		})
+	}
+}
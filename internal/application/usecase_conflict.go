@@ -0,0 +1,395 @@
+package application
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func ParsePortConflictPolicy(s string) (PortConflictPolicy, error) {
+	switch p := PortConflictPolicy(strings.TrimSpace(s)); p {
+	case PortConflictPolicyWarn, PortConflictPolicyExclude:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid port conflict policy: %s", s)
+	}
+}
+
+func getPortConflictPolicy(
+	annotations map[string]string,
+	annotationName string,
+	defaultVal PortConflictPolicy,
+) PortConflictPolicy {
+	annotation, exists := annotations[annotationName]
+	if !exists {
+		return defaultVal
+	}
+
+	policy, err := ParsePortConflictPolicy(annotation)
+	if err != nil {
+		return defaultVal
+	}
+	return policy
+}
+
+// portKey is an address that kube-proxy routes to a single Service.
+type portKey struct {
+	ip       string
+	port     int32
+	protocol corev1.Protocol
+}
+
+func (k portKey) String() string {
+	return fmt.Sprintf("%s:%d/%s", k.ip, k.port, k.protocol)
+}
+
+// portKeysOf returns every address that svc is published on with ips.
+func portKeysOf(svc corev1.Service, ips IPStatus) []portKey {
+	var keys []portKey
+	for _, ip := range uniqueStrings(append(append([]string{}, ips.IngressIPs...), ips.ExternalIPs...)) {
+		for _, port := range svc.Spec.Ports {
+			keys = append(keys, portKey{ip: ip, port: port.Port, protocol: protocolOf(port)})
+		}
+	}
+	return keys
+}
+
+// portClaim is a Service that publishes a portKey.
+type portClaim struct {
+	svc        types.NamespacedName
+	created    time.Time
+	sharingKey string
+}
+
+// precedes reports whether c keeps its addresses over other, which is the older Service.
+func (c portClaim) precedes(other portClaim) bool {
+	if !c.created.Equal(other.created) {
+		return c.created.Before(other.created)
+	}
+	return c.svc.String() < other.svc.String()
+}
+
+// sharesWith reports whether both Services opted in to share addresses with the same sharing key.
+func (c portClaim) sharesWith(other portClaim) bool {
+	return c.sharingKey != "" && c.sharingKey == other.sharingKey
+}
+
+func portClaimOf(svc corev1.Service) portClaim {
+	return portClaim{
+		svc:        types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name},
+		created:    svc.CreationTimestamp.Time,
+		sharingKey: svc.Annotations[LabelSharingKey],
+	}
+}
+
+// portIndex maps every address that static-lb publishes to Services that publish it. Every Service bound to this
+// instance is indexed by addresses it is published on, including ones that other shards assign.
+type portIndex struct {
+	mu     sync.Mutex
+	claims map[portKey]map[types.NamespacedName]portClaim
+	keys   map[types.NamespacedName][]portKey
+	// wanted are addresses that Services are to be published on before conflicting ones are excluded, so that
+	// Services are told when a conflict on their excluded addresses is resolved.
+	wanted     map[portKey]map[types.NamespacedName]struct{}
+	wantedKeys map[types.NamespacedName][]portKey
+}
+
+func newPortIndex() *portIndex {
+	return &portIndex{
+		claims:     map[portKey]map[types.NamespacedName]portClaim{},
+		keys:       map[types.NamespacedName][]portKey{},
+		wanted:     map[portKey]map[types.NamespacedName]struct{}{},
+		wantedKeys: map[types.NamespacedName][]portKey{},
+	}
+}
+
+// claim replaces addresses of claim with keys. It returns other Services that want or claim addresses whose claims
+// changed, since their conflicts may have changed.
+func (i *portIndex) claim(claim portClaim, keys []portKey) []types.NamespacedName {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	oldKeys := i.keys[claim.svc]
+	var old portClaim
+	for _, key := range oldKeys {
+		old = i.claims[key][claim.svc]
+		break
+	}
+
+	var changed []portKey
+	if old.created.Equal(claim.created) && old.sharingKey == claim.sharingKey {
+		changed = symmetricDifference(oldKeys, keys)
+	} else {
+		changed = append(append([]portKey{}, oldKeys...), keys...)
+	}
+
+	i.release(claim.svc)
+	for _, key := range keys {
+		if i.claims[key] == nil {
+			i.claims[key] = map[types.NamespacedName]portClaim{}
+		}
+		i.claims[key][claim.svc] = claim
+	}
+	if len(keys) != 0 {
+		i.keys[claim.svc] = keys
+	}
+	return i.affected(claim.svc, changed)
+}
+
+// want replaces addresses that the Service of svcKey is to be published on with keys.
+func (i *portIndex) want(svcKey types.NamespacedName, keys []portKey) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.unwant(svcKey)
+	for _, key := range keys {
+		if i.wanted[key] == nil {
+			i.wanted[key] = map[types.NamespacedName]struct{}{}
+		}
+		i.wanted[key][svcKey] = struct{}{}
+	}
+	if len(keys) != 0 {
+		i.wantedKeys[svcKey] = keys
+	}
+}
+
+// forgetWanted drops addresses that the Service of svcKey is to be published on, but keeps its claims.
+func (i *portIndex) forgetWanted(svcKey types.NamespacedName) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.unwant(svcKey)
+}
+
+// forget drops everything of the Service of svcKey, and returns other Services whose conflicts may have changed.
+func (i *portIndex) forget(svcKey types.NamespacedName) []types.NamespacedName {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	released := i.keys[svcKey]
+	i.release(svcKey)
+	i.unwant(svcKey)
+	return i.affected(svcKey, released)
+}
+
+func (i *portIndex) release(svcKey types.NamespacedName) {
+	for _, key := range i.keys[svcKey] {
+		delete(i.claims[key], svcKey)
+		if len(i.claims[key]) == 0 {
+			delete(i.claims, key)
+		}
+	}
+	delete(i.keys, svcKey)
+}
+
+func (i *portIndex) unwant(svcKey types.NamespacedName) {
+	for _, key := range i.wantedKeys[svcKey] {
+		delete(i.wanted[key], svcKey)
+		if len(i.wanted[key]) == 0 {
+			delete(i.wanted, key)
+		}
+	}
+	delete(i.wantedKeys, svcKey)
+}
+
+// affected returns Services other than svcKey that want or claim any of keys, in order.
+func (i *portIndex) affected(svcKey types.NamespacedName, keys []portKey) []types.NamespacedName {
+	visited := map[types.NamespacedName]struct{}{svcKey: {}}
+	var result []types.NamespacedName
+	add := func(other types.NamespacedName) {
+		if _, exists := visited[other]; !exists {
+			visited[other] = struct{}{}
+			result = append(result, other)
+		}
+	}
+	for _, key := range keys {
+		for other := range i.claims[key] {
+			add(other)
+		}
+		for other := range i.wanted[key] {
+			add(other)
+		}
+	}
+	sort.Slice(result, func(a, b int) bool { return result[a].String() < result[b].String() })
+	return result
+}
+
+// conflicts returns, for each of keys, Services that precede claim on it without sharing it.
+func (i *portIndex) conflicts(claim portClaim, keys []portKey) map[portKey][]types.NamespacedName {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	result := make(map[portKey][]types.NamespacedName)
+	for _, key := range keys {
+		for svcKey, other := range i.claims[key] {
+			if svcKey == claim.svc || !other.precedes(claim) || other.sharesWith(claim) {
+				continue
+			}
+			result[key] = append(result[key], svcKey)
+		}
+	}
+	return result
+}
+
+func symmetricDifference[K comparable](a, b []K) []K {
+	inA := make(map[K]struct{}, len(a))
+	for _, key := range a {
+		inA[key] = struct{}{}
+	}
+	inB := make(map[K]struct{}, len(b))
+	for _, key := range b {
+		inB[key] = struct{}{}
+	}
+
+	var result []K
+	for _, key := range a {
+		if _, exists := inB[key]; !exists {
+			result = append(result, key)
+		}
+	}
+	for _, key := range b {
+		if _, exists := inA[key]; !exists {
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
+// detectPortConflicts finds addresses of targetIPs of svc that older Services already publish on the same ports,
+// and returns the condition that explains them. Those addresses are dropped if the policy says so. Services that
+// share addresses on purpose set the same LabelSharingKey.
+func (u usecase) detectPortConflicts(svc corev1.Service, targetIPs IPStatus) (IPStatus, metav1.Condition) {
+	condition := metav1.Condition{
+		Type:    ConditionTypeConflictFree,
+		Status:  metav1.ConditionTrue,
+		Reason:  ConditionReasonNoConflict,
+		Message: "No other Service is published on the same IPs and ports",
+	}
+	if svc.Spec.Type != corev1.ServiceTypeLoadBalancer || u.ports == nil {
+		return targetIPs, condition
+	}
+
+	keys := portKeysOf(svc, targetIPs)
+	u.ports.want(types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name}, keys)
+	conflicts := u.ports.conflicts(portClaimOf(svc), keys)
+	if len(conflicts) == 0 {
+		return targetIPs, condition
+	}
+
+	descriptions := make([]string, 0, len(conflicts))
+	conflictingIPs := make(map[string]struct{})
+	for key, owners := range conflicts {
+		names := make([]string, 0, len(owners))
+		for _, owner := range owners {
+			names = append(names, owner.String())
+		}
+		sort.Strings(names)
+		descriptions = append(descriptions, fmt.Sprintf("%s (%s)", key, strings.Join(names, ",")))
+		conflictingIPs[key.ip] = struct{}{}
+	}
+	sort.Strings(descriptions)
+
+	condition.Status = metav1.ConditionFalse
+	condition.Reason = ConditionReasonPortConflict
+	condition.Message = fmt.Sprintf("Addresses are already published by other Services: %s",
+		strings.Join(descriptions, ", "))
+	if getPortConflictPolicy(svc.Annotations, LabelPortConflictPolicy, u.defaultPortConflictPolicy) ==
+		PortConflictPolicyExclude {
+		targetIPs = IPStatus{
+			IngressIPs:  withoutIPs(targetIPs.IngressIPs, conflictingIPs),
+			ExternalIPs: withoutIPs(targetIPs.ExternalIPs, conflictingIPs),
+		}
+		condition.Message += ", conflicting IPs are excluded"
+	}
+
+	// the event is recorded once until the conflict is resolved, not on every reconciliation
+	if last := meta.FindStatusCondition(svc.Status.Conditions, ConditionTypeConflictFree); last == nil ||
+		last.Reason != ConditionReasonPortConflict {
+		u.recordEvent(&svc, corev1.EventTypeWarning, EventReasonPortConflict, "%s", condition.Message)
+	}
+	return targetIPs, condition
+}
+
+// claimPorts records targetIPs as addresses that svc is published on.
+func (u usecase) claimPorts(svc corev1.Service, targetIPs IPStatus) {
+	if u.ports == nil {
+		return
+	}
+	if svc.Spec.Type != corev1.ServiceTypeLoadBalancer {
+		u.releasePorts(types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name})
+		return
+	}
+	u.notifyPortClaims(u.ports.claim(portClaimOf(svc), portKeysOf(svc, targetIPs)))
+}
+
+// releasePorts drops every address of the Service of svcKey from the index, and ports that it publishes on external
+// IPs from the index of node ports.
+func (u usecase) releasePorts(svcKey types.NamespacedName) {
+	if u.nodePorts != nil {
+		u.nodePorts.use(svcKey, nil)
+	}
+	if u.ports == nil {
+		return
+	}
+	u.notifyPortClaims(u.ports.forget(svcKey))
+}
+
+// notifyPortClaims tells that conflicts of svcKeys may have changed, so that they are assigned again.
+func (u usecase) notifyPortClaims(svcKeys []types.NamespacedName) {
+	if u.portClaimsChanged == nil {
+		return
+	}
+	for _, svcKey := range svcKeys {
+		u.portClaimsChanged(svcKey)
+	}
+}
+
+// ObserveService indexes addresses that svc is published on, and its node ports. It is called for every Service,
+// including ones of other shards, so that the initial list seeds the indexes with every Service and conflicts do not
+// depend on which Service is assigned first.
+func (u usecase) ObserveService(svc corev1.Service) {
+	svcKey := types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name}
+	if svcKey == kubernetesServiceKey {
+		// its ClusterIPs are reserved
+		u.InvalidateReservedIPNets()
+	}
+	// node ports are opened on every node, whichever instance svc is bound to
+	if u.nodePorts != nil {
+		u.notifyPortClaims(u.nodePorts.allocate(svcKey, nodePortsOf(svc)))
+	}
+	if !isBoundTo(svc.Annotations, u.instanceName) {
+		u.releasePorts(svcKey)
+		return
+	}
+
+	scoped := svc
+	scoped.Annotations = scopeAnnotations(svc.Annotations, u.instanceName)
+	u.claimPorts(scoped, assignedIPs(svc))
+}
+
+func (u usecase) ObserveServiceDeletion(svcKey types.NamespacedName) {
+	if svcKey == kubernetesServiceKey {
+		u.InvalidateReservedIPNets()
+	}
+	if u.nodePorts != nil {
+		u.notifyPortClaims(u.nodePorts.forget(svcKey))
+	}
+	u.releasePorts(svcKey)
+}
+
+func withoutIPs(ips []string, excluded map[string]struct{}) []string {
+	var result []string
+	for _, ip := range ips {
+		if _, exists := excluded[ip]; !exists {
+			result = append(result, ip)
+		}
+	}
+	return result
+}
@@ -0,0 +1,173 @@
+package application
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsecase_detectPortConflicts(t *testing.T) {
+	t.Parallel()
+
+	epoch := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	newSvc := func(name string, created time.Time, annotations map[string]string) corev1.Service {
+		return corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "default",
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(created),
+				Annotations:       annotations,
+			},
+			Spec: corev1.ServiceSpec{
+				Type:  corev1.ServiceTypeLoadBalancer,
+				Ports: []corev1.ServicePort{{Port: 443}},
+			},
+		}
+	}
+	older := newSvc("older", epoch, map[string]string{LabelSharingKey: "shared"})
+	olderIPs := IPStatus{ExternalIPs: []string{"10.0.0.1"}}
+	targetIPs := IPStatus{IngressIPs: []string{"10.0.0.2"}, ExternalIPs: []string{"10.0.0.1"}}
+
+	tests := []struct {
+		name             string
+		svc              corev1.Service
+		policy           PortConflictPolicy
+		expected         IPStatus
+		expectedReason   string
+		expectedEventNum int
+	}{
+		{
+			name:             "later Service warns",
+			svc:              newSvc("later", epoch.Add(time.Hour), nil),
+			policy:           PortConflictPolicyWarn,
+			expected:         targetIPs,
+			expectedReason:   ConditionReasonPortConflict,
+			expectedEventNum: 1,
+		},
+		{
+			name:             "later Service excludes by annotation",
+			svc:              newSvc("later", epoch.Add(time.Hour), map[string]string{LabelPortConflictPolicy: "exclude"}),
+			policy:           PortConflictPolicyWarn,
+			expected:         IPStatus{IngressIPs: []string{"10.0.0.2"}},
+			expectedReason:   ConditionReasonPortConflict,
+			expectedEventNum: 1,
+		},
+		{
+			name:           "earlier Service keeps its IPs",
+			svc:            newSvc("earlier", epoch.Add(-time.Hour), nil),
+			policy:         PortConflictPolicyExclude,
+			expected:       targetIPs,
+			expectedReason: ConditionReasonNoConflict,
+		},
+		{
+			name:           "same sharing key",
+			svc:            newSvc("later", epoch.Add(time.Hour), map[string]string{LabelSharingKey: "shared"}),
+			policy:         PortConflictPolicyExclude,
+			expected:       targetIPs,
+			expectedReason: ConditionReasonNoConflict,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := &fakeEventRecorder{}
+			u := usecase{eventRecorder: recorder, defaultPortConflictPolicy: tc.policy, ports: newPortIndex()}
+			u.claimPorts(older, olderIPs)
+
+			actual, condition := u.detectPortConflicts(tc.svc, targetIPs)
+			assert.Equal(t, tc.expected, actual)
+			assert.Equal(t, ConditionTypeConflictFree, condition.Type)
+			assert.Equal(t, tc.expectedReason, condition.Reason)
+			assert.Len(t, recorder.events, tc.expectedEventNum)
+		})
+	}
+}
+
+func TestPortIndex(t *testing.T) {
+	t.Parallel()
+
+	epoch := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := portClaim{svc: types.NamespacedName{Namespace: "default", Name: "a"}, created: epoch}
+	b := portClaim{svc: types.NamespacedName{Namespace: "default", Name: "b"}, created: epoch}
+	c := portClaim{svc: types.NamespacedName{Namespace: "default", Name: "c"}, created: epoch}
+	https := portKey{ip: "10.0.0.1", port: 443, protocol: corev1.ProtocolTCP}
+	dns := portKey{ip: "10.0.0.1", port: 53, protocol: corev1.ProtocolUDP}
+
+	index := newPortIndex()
+	index.want(b.svc, []portKey{https})
+	assert.Equal(t, []types.NamespacedName{b.svc}, index.claim(a, []portKey{https, dns}), "b wants a claimed port")
+	assert.Equal(t, map[portKey][]types.NamespacedName{https: {a.svc}}, index.conflicts(b, []portKey{https}))
+	assert.Empty(t, index.conflicts(a, []portKey{https}), "b does not precede a")
+	assert.Empty(t, index.claim(a, []portKey{https, dns}), "nothing changed")
+
+	assert.Equal(t, []types.NamespacedName{a.svc}, index.claim(c, []portKey{dns}), "a claims the port of c")
+	assert.Equal(t, []types.NamespacedName{b.svc}, index.claim(a, []portKey{dns}))
+	assert.Empty(t, index.conflicts(b, []portKey{https}), "a released the port")
+
+	index.forgetWanted(b.svc)
+	assert.Equal(t, []types.NamespacedName{c.svc}, index.forget(a.svc))
+	assert.Empty(t, index.forget(c.svc))
+	assert.Empty(t, index.conflicts(b, []portKey{dns}))
+	assert.Empty(t, index.claims)
+	assert.Empty(t, index.keys)
+	assert.Empty(t, index.wanted)
+	assert.Empty(t, index.wantedKeys)
+}
+
+func TestUsecase_ObserveService(t *testing.T) {
+	t.Parallel()
+
+	epoch := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	newSvc := func(name string, created time.Time, ips ...string) corev1.Service {
+		return corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "default",
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(created),
+			},
+			Spec: corev1.ServiceSpec{
+				Type:        corev1.ServiceTypeLoadBalancer,
+				Ports:       []corev1.ServicePort{{Port: 443}},
+				ExternalIPs: ips,
+			},
+		}
+	}
+	older := newSvc("older", epoch, "10.0.0.1")
+	later := newSvc("later", epoch.Add(time.Hour))
+	laterKey := types.NamespacedName{Namespace: "default", Name: "later"}
+	targetIPs := IPStatus{ExternalIPs: []string{"10.0.0.1"}}
+
+	var changed []types.NamespacedName
+	u := usecase{
+		eventRecorder:             &fakeEventRecorder{},
+		defaultPortConflictPolicy: PortConflictPolicyExclude,
+		ports:                     newPortIndex(),
+		portClaimsChanged:         func(svcKey types.NamespacedName) { changed = append(changed, svcKey) },
+	}
+
+	// the later Service is assigned before the older one, e.g. of another shard, is observed
+	actual, _ := u.detectPortConflicts(later, targetIPs)
+	assert.Equal(t, targetIPs, actual)
+	u.ObserveService(older)
+	assert.Equal(t, []types.NamespacedName{laterKey}, changed, "the later Service is assigned again")
+
+	actual, condition := u.detectPortConflicts(later, targetIPs)
+	assert.Equal(t, IPStatus{}, actual)
+	assert.Equal(t, ConditionReasonPortConflict, condition.Reason)
+
+	changed = nil
+	u.ObserveService(older)
+	assert.Empty(t, changed, "nothing changed")
+
+	u.ObserveServiceDeletion(types.NamespacedName{Namespace: "default", Name: "older"})
+	assert.Equal(t, []types.NamespacedName{laterKey}, changed, "the excluded IP is restored")
+	actual, _ = u.detectPortConflicts(later, targetIPs)
+	assert.Equal(t, targetIPs, actual)
+}
@@ -0,0 +1,142 @@
+package application
+
+import (
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func getDuration(svc corev1.Service, annotationName string, defaultVal time.Duration) time.Duration {
+	annotation, exists := svc.Annotations[annotationName]
+	if !exists {
+		return defaultVal
+	}
+
+	d, err := time.ParseDuration(annotation)
+	if err != nil || d < 0 {
+		return defaultVal
+	}
+	return d
+}
+
+// debounceIPs delays both addition and removal of IPs so that a short-lived change of candidates during rollouts
+// does not rewrite the Service.
+// It returns IPs that are settled and the duration after which one of pending IPs can be settled.
+func (u usecase) debounceIPs(svc corev1.Service, targetIPs IPStatus) (IPStatus, time.Duration) {
+	key := types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name}
+
+	addDelay := getDuration(svc, LabelIPAddDelay, u.defaultIPAddDelay)
+	removeDelay := getDuration(svc, LabelIPRemoveDelay, u.defaultIPRemoveDelay)
+	if svc.Spec.Type != corev1.ServiceTypeLoadBalancer || (addDelay == 0 && removeDelay == 0) {
+		u.pendingIPs.forget(key)
+		return targetIPs, 0
+	}
+
+	return u.pendingIPs.settle(key, time.Now(), assignedIPs(svc), targetIPs, addDelay, removeDelay)
+}
+
+func assignedIPs(svc corev1.Service) IPStatus {
+	ingressIPs := make([]string, len(svc.Status.LoadBalancer.Ingress))
+	for i, ingress := range svc.Status.LoadBalancer.Ingress {
+		ingressIPs[i] = ingress.IP
+	}
+	return IPStatus{IngressIPs: ingressIPs, ExternalIPs: svc.Spec.ExternalIPs}
+}
+
+type pendingIP struct {
+	target IPMappingTarget
+	ip     string
+	adding bool
+}
+
+// pendingIPTracker remembers since when each IP of each Service has been continuously (in)eligible.
+type pendingIPTracker struct {
+	mu    sync.Mutex
+	since map[types.NamespacedName]map[pendingIP]time.Time
+}
+
+func newPendingIPTracker() *pendingIPTracker {
+	return &pendingIPTracker{since: map[types.NamespacedName]map[pendingIP]time.Time{}}
+}
+
+func (t *pendingIPTracker) forget(key types.NamespacedName) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.since, key)
+}
+
+func (t *pendingIPTracker) settle(
+	key types.NamespacedName,
+	now time.Time,
+	current IPStatus,
+	target IPStatus,
+	addDelay time.Duration,
+	removeDelay time.Duration,
+) (IPStatus, time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	prev := t.since[key]
+	next := map[pendingIP]time.Time{}
+	var wait time.Duration
+
+	settleList := func(mappingTarget IPMappingTarget, current, target []string) []string {
+		currentSet := make(map[string]struct{}, len(current))
+		for _, ip := range current {
+			currentSet[ip] = struct{}{}
+		}
+		targetSet := make(map[string]struct{}, len(target))
+		for _, ip := range target {
+			targetSet[ip] = struct{}{}
+		}
+
+		// ready reports whether p has been pending at least for delay, and records it as pending otherwise.
+		ready := func(p pendingIP, delay time.Duration) bool {
+			since, exists := prev[p]
+			if !exists {
+				since = now
+			}
+			if elapsed := now.Sub(since); elapsed < delay {
+				next[p] = since
+				if remaining := delay - elapsed; wait == 0 || remaining < wait {
+					wait = remaining
+				}
+				return false
+			}
+			return true
+		}
+
+		var result []string
+		for _, ip := range target {
+			if _, assigned := currentSet[ip]; assigned ||
+				ready(pendingIP{target: mappingTarget, ip: ip, adding: true}, addDelay) {
+				result = append(result, ip)
+			}
+		}
+		for _, ip := range current {
+			if _, eligible := targetSet[ip]; eligible {
+				continue
+			}
+			if !ready(pendingIP{target: mappingTarget, ip: ip, adding: false}, removeDelay) {
+				result = append(result, ip)
+			}
+		}
+		return result
+	}
+
+	settled := IPStatus{
+		IngressIPs:  settleList(IPMappingTargetIngress, current.IngressIPs, target.IngressIPs),
+		ExternalIPs: settleList(IPMappingTargetExternal, current.ExternalIPs, target.ExternalIPs),
+	}
+
+	if len(next) == 0 {
+		delete(t.since, key)
+	} else {
+		t.since[key] = next
+	}
+
+	return settled, wait
+}
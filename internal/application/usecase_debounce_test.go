@@ -0,0 +1,140 @@
+package application
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPendingIPTracker_settle(t *testing.T) {
+	t.Parallel()
+
+	key := types.NamespacedName{Namespace: "default", Name: "svc"}
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	type step struct {
+		elapsed      time.Duration
+		current      IPStatus
+		target       IPStatus
+		expected     IPStatus
+		expectedWait time.Duration
+	}
+
+	tests := []struct {
+		name        string
+		addDelay    time.Duration
+		removeDelay time.Duration
+		steps       []step
+	}{
+		{
+			name:     "add after delay",
+			addDelay: 10 * time.Second,
+			steps: []step{
+				{
+					target:       IPStatus{IngressIPs: []string{"10.222.0.1"}},
+					expected:     IPStatus{},
+					expectedWait: 10 * time.Second,
+				},
+				{
+					elapsed:      4 * time.Second,
+					target:       IPStatus{IngressIPs: []string{"10.222.0.1"}},
+					expected:     IPStatus{},
+					expectedWait: 6 * time.Second,
+				},
+				{
+					elapsed:  10 * time.Second,
+					target:   IPStatus{IngressIPs: []string{"10.222.0.1"}},
+					expected: IPStatus{IngressIPs: []string{"10.222.0.1"}},
+				},
+			},
+		},
+		{
+			name:     "add timer resets when ineligible in between",
+			addDelay: 10 * time.Second,
+			steps: []step{
+				{
+					target:       IPStatus{IngressIPs: []string{"10.222.0.1"}},
+					expected:     IPStatus{},
+					expectedWait: 10 * time.Second,
+				},
+				{
+					elapsed:  5 * time.Second,
+					target:   IPStatus{},
+					expected: IPStatus{},
+				},
+				{
+					elapsed:      10 * time.Second,
+					target:       IPStatus{IngressIPs: []string{"10.222.0.1"}},
+					expected:     IPStatus{},
+					expectedWait: 10 * time.Second,
+				},
+			},
+		},
+		{
+			name:        "remove after delay",
+			removeDelay: 30 * time.Second,
+			steps: []step{
+				{
+					current:      IPStatus{ExternalIPs: []string{"10.222.0.1", "10.222.0.2"}},
+					target:       IPStatus{ExternalIPs: []string{"10.222.0.1"}},
+					expected:     IPStatus{ExternalIPs: []string{"10.222.0.1", "10.222.0.2"}},
+					expectedWait: 30 * time.Second,
+				},
+				{
+					elapsed:  30 * time.Second,
+					current:  IPStatus{ExternalIPs: []string{"10.222.0.1", "10.222.0.2"}},
+					target:   IPStatus{ExternalIPs: []string{"10.222.0.1"}},
+					expected: IPStatus{ExternalIPs: []string{"10.222.0.1"}},
+				},
+			},
+		},
+		{
+			name:        "removal cancelled when eligible again",
+			removeDelay: 30 * time.Second,
+			steps: []step{
+				{
+					current:      IPStatus{IngressIPs: []string{"10.222.0.1"}},
+					target:       IPStatus{},
+					expected:     IPStatus{IngressIPs: []string{"10.222.0.1"}},
+					expectedWait: 30 * time.Second,
+				},
+				{
+					elapsed:  10 * time.Second,
+					current:  IPStatus{IngressIPs: []string{"10.222.0.1"}},
+					target:   IPStatus{IngressIPs: []string{"10.222.0.1"}},
+					expected: IPStatus{IngressIPs: []string{"10.222.0.1"}},
+				},
+				{
+					elapsed:      40 * time.Second,
+					current:      IPStatus{IngressIPs: []string{"10.222.0.1"}},
+					target:       IPStatus{},
+					expected:     IPStatus{IngressIPs: []string{"10.222.0.1"}},
+					expectedWait: 30 * time.Second,
+				},
+			},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tracker := newPendingIPTracker()
+			for _, s := range tc.steps {
+				actual, wait := tracker.settle(
+					key,
+					start.Add(s.elapsed),
+					s.current,
+					s.target,
+					tc.addDelay,
+					tc.removeDelay,
+				)
+				assert.Equal(t, s.expected, actual)
+				assert.Equal(t, s.expectedWait, wait)
+			}
+		})
+	}
+}
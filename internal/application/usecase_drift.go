@@ -0,0 +1,66 @@
+package application
+
+import (
+	"sync"
+
+	"github.com/isac322/static-lb/internal/pkg/slices"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func isFrozen(svc corev1.Service) bool {
+	return svc.Annotations[LabelFrozen] == "true"
+}
+
+type lastAssignment struct {
+	ips IPStatus
+	// previous are IPs of the Service before the assignment, which a stale cache may still show.
+	previous IPStatus
+}
+
+// lastAssignmentTracker remembers what static-lb wrote to each Service last time.
+type lastAssignmentTracker struct {
+	mu   sync.Mutex
+	last map[types.NamespacedName]lastAssignment
+}
+
+func newLastAssignmentTracker() *lastAssignmentTracker {
+	return &lastAssignmentTracker{last: map[types.NamespacedName]lastAssignment{}}
+}
+
+// record remembers ips as written to the Service of key, over previous. It is called only after both the spec and the
+// status are written.
+func (t *lastAssignmentTracker) record(key types.NamespacedName, ips IPStatus, previous IPStatus) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.last[key] = lastAssignment{ips: ips, previous: previous}
+}
+
+func (t *lastAssignmentTracker) forget(key types.NamespacedName) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.last, key)
+}
+
+// drifted reports whether IPs of svc were changed by someone else since static-lb wrote them. Each of ingress and
+// external IPs is compared by content: matching either the assignment or what it replaced is not a drift, since the
+// cache may deliver svc before the assignment, or between the write of the spec and the write of the status.
+func (t *lastAssignmentTracker) drifted(svc corev1.Service) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	last, exists := t.last[types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name}]
+	if !exists {
+		return false
+	}
+
+	live := assignedIPs(svc)
+	ingressKnown := slices.Match(last.ips.IngressIPs, live.IngressIPs) ||
+		slices.Match(last.previous.IngressIPs, live.IngressIPs)
+	externalKnown := slices.Match(last.ips.ExternalIPs, live.ExternalIPs) ||
+		slices.Match(last.previous.ExternalIPs, live.ExternalIPs)
+	return !ingressKnown || !externalKnown
+}
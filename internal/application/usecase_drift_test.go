@@ -0,0 +1,80 @@
+package application
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLastAssignmentTracker_drifted(t *testing.T) {
+	t.Parallel()
+
+	key := types.NamespacedName{Namespace: "default", Name: "svc"}
+	svc := func(ingressIP string, externalIPs ...string) corev1.Service {
+		svc := corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+			Spec:       corev1.ServiceSpec{ExternalIPs: externalIPs},
+		}
+		if ingressIP != "" {
+			svc.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: ingressIP}}
+		}
+		return svc
+	}
+
+	tests := []struct {
+		name     string
+		record   bool
+		svc      corev1.Service
+		expected bool
+	}{
+		{
+			name:     "never assigned",
+			svc:      svc("10.222.0.2", "10.222.0.2"),
+			expected: false,
+		},
+		{
+			name:     "unchanged",
+			record:   true,
+			svc:      svc("10.222.0.1", "10.222.0.1"),
+			expected: false,
+		},
+		{
+			name:     "stale cache",
+			record:   true,
+			svc:      svc("10.222.0.3", "10.222.0.3"),
+			expected: false,
+		},
+		{
+			name:     "spec is written but status is not yet",
+			record:   true,
+			svc:      svc("10.222.0.3", "10.222.0.1"),
+			expected: false,
+		},
+		{
+			name:     "modified by someone else",
+			record:   true,
+			svc:      svc("10.222.0.1", "10.222.0.4"),
+			expected: true,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tracker := newLastAssignmentTracker()
+			if tc.record {
+				tracker.record(
+					key,
+					IPStatus{IngressIPs: []string{"10.222.0.1"}, ExternalIPs: []string{"10.222.0.1"}},
+					IPStatus{IngressIPs: []string{"10.222.0.3"}, ExternalIPs: []string{"10.222.0.3"}},
+				)
+			}
+			assert.Equal(t, tc.expected, tracker.drifted(tc.svc))
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package application
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func ParseEmptyIPsPolicy(s string) (EmptyIPsPolicy, error) {
+	mode, arg, hasArg := strings.Cut(strings.TrimSpace(s), "=")
+
+	switch m := EmptyIPsMode(mode); m {
+	case EmptyIPsModeClear, EmptyIPsModeKeepLast:
+		if hasArg {
+			return EmptyIPsPolicy{}, fmt.Errorf("%s does not take an argument: %s", m, s)
+		}
+		return EmptyIPsPolicy{Mode: m}, nil
+
+	case EmptyIPsModeHoldFor:
+		holdFor, err := time.ParseDuration(arg)
+		if err != nil {
+			return EmptyIPsPolicy{}, fmt.Errorf("invalid duration of %s: %w", m, err)
+		}
+		if holdFor <= 0 {
+			return EmptyIPsPolicy{}, fmt.Errorf("duration of %s must be positive: %s", m, s)
+		}
+		return EmptyIPsPolicy{Mode: m, HoldFor: holdFor}, nil
+
+	default:
+		return EmptyIPsPolicy{}, fmt.Errorf("invalid empty IPs policy: %s", s)
+	}
+}
+
+func (p EmptyIPsPolicy) String() string {
+	if p.Mode == EmptyIPsModeHoldFor {
+		return fmt.Sprintf("%s=%s", p.Mode, p.HoldFor)
+	}
+	return string(p.Mode)
+}
+
+func getEmptyIPsPolicy(svc corev1.Service, annotationName string, defaultVal EmptyIPsPolicy) EmptyIPsPolicy {
+	annotation, exists := svc.Annotations[annotationName]
+	if !exists {
+		return defaultVal
+	}
+
+	policy, err := ParseEmptyIPsPolicy(annotation)
+	if err != nil {
+		return defaultVal
+	}
+	return policy
+}
+
+// emptySinceTracker remembers when each Service lost its last IP candidate.
+type emptySinceTracker struct {
+	mu    sync.Mutex
+	since map[types.NamespacedName]time.Time
+}
+
+func newEmptySinceTracker() *emptySinceTracker {
+	return &emptySinceTracker{since: map[types.NamespacedName]time.Time{}}
+}
+
+// mark records now as the moment key became empty unless it is already recorded, and returns the recorded time.
+func (t *emptySinceTracker) mark(key types.NamespacedName, now time.Time) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	since, exists := t.since[key]
+	if !exists {
+		t.since[key] = now
+		return now
+	}
+	return since
+}
+
+func (t *emptySinceTracker) forget(key types.NamespacedName) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.since, key)
+}
+
+// staleAddressesPrefix starts the message of the StaleAddresses condition, which is followed by when the last IP
+// candidate vanished, so that holding survives a restart of the controller.
+const staleAddressesPrefix = "No IP candidate remains since "
+
+func staleAddressesMessage(since time.Time) string {
+	return staleAddressesPrefix + since.UTC().Format(time.RFC3339) + ", the last known IPs are kept"
+}
+
+// staleSinceOf returns when svc started holding its last IPs, read from its StaleAddresses condition.
+func staleSinceOf(svc corev1.Service) (time.Time, bool) {
+	condition := meta.FindStatusCondition(svc.Status.Conditions, ConditionTypeIPsAssigned)
+	if condition == nil || condition.Reason != ConditionReasonStaleAddresses {
+		return time.Time{}, false
+	}
+	rest, found := strings.CutPrefix(condition.Message, staleAddressesPrefix)
+	if !found {
+		return time.Time{}, false
+	}
+	timestamp, _, _ := strings.Cut(rest, ",")
+	since, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return since, true
+}
+
+// holdDecision tells whether the previously assigned IPs of a Service are kept instead of being cleared.
+type holdDecision struct {
+	hold bool
+	// since is when the last IP candidate vanished, set if hold.
+	since time.Time
+	// recheckAfter is the duration after which the decision has to be made again, or zero when there is nothing to
+	// wait.
+	recheckAfter time.Duration
+	// expiredAfter is the grace period of hold-for that has just expired, which is reported once IPs are cleared.
+	expiredAfter time.Duration
+}
+
+// holdLastIPs decides whether the previously assigned IPs of svc should be kept instead of being cleared.
+// They are kept only if no IP candidate remains, i.e. nodeIPs or mappedIPs are empty. IPs that are dropped on purpose,
+// e.g. by requested IPs, filters or policies of ports, are not held.
+// The event is recorded when svc starts holding, not on every reconciliation. Holding started before a restart of
+// the controller is taken from the message of the StaleAddresses condition.
+func (u usecase) holdLastIPs(svc corev1.Service, nodeIPs NodeIPs, mappedIPs IPStatus, targetIPs IPStatus) holdDecision {
+	key := types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name}
+
+	candidatesVanished := nodeIPs.IsEmpty() || mappedIPs.IsEmpty()
+	if !targetIPs.IsEmpty() || !candidatesVanished ||
+		svc.Spec.Type != corev1.ServiceTypeLoadBalancer || !hasAssignedIPs(svc) {
+		u.emptySince.forget(key)
+		return holdDecision{}
+	}
+
+	policy := getEmptyIPsPolicy(svc, LabelEmptyIPsPolicy, u.defaultEmptyIPsPolicy)
+	if policy.Mode != EmptyIPsModeKeepLast && policy.Mode != EmptyIPsModeHoldFor {
+		return holdDecision{}
+	}
+
+	now := time.Now()
+	start, holding := staleSinceOf(svc)
+	if !holding {
+		start = now
+	}
+	since := u.emptySince.mark(key, start)
+
+	switch policy.Mode {
+	case EmptyIPsModeKeepLast:
+		if !holding {
+			u.recordEvent(
+				&svc,
+				corev1.EventTypeWarning,
+				EventReasonStaleAddresses,
+				"No IP candidate remains, keeping the last known IPs",
+			)
+		}
+		return holdDecision{hold: true, since: since}
+
+	default:
+		elapsed := now.Sub(since)
+		if elapsed < policy.HoldFor {
+			if !holding {
+				u.recordEvent(
+					&svc,
+					corev1.EventTypeWarning,
+					EventReasonStaleAddresses,
+					"No IP candidate remains, keeping the last known IPs for %s",
+					policy.HoldFor,
+				)
+			}
+			return holdDecision{hold: true, since: since, recheckAfter: policy.HoldFor - elapsed}
+		}
+
+		u.emptySince.forget(key)
+		return holdDecision{expiredAfter: policy.HoldFor}
+	}
+}
+
+// recordAddressesCleared tells that IPs of svc are cleared since no IP candidate appeared within holdFor. It is called
+// once the IPs are written, so that failed writes do not repeat it.
+func (u usecase) recordAddressesCleared(svc corev1.Service, holdFor time.Duration) {
+	u.recordEvent(
+		&svc,
+		corev1.EventTypeNormal,
+		EventReasonAddressesCleared,
+		"No IP candidate appeared within %s, clearing IPs",
+		holdFor,
+	)
+}
+
+func hasAssignedIPs(svc corev1.Service) bool {
+	return len(svc.Spec.ExternalIPs) != 0 || len(svc.Status.LoadBalancer.Ingress) != 0
+}
+
+func (u usecase) recordEvent(object runtime.Object, eventType, reason, messageFmt string, args ...interface{}) {
+	if u.eventRecorder == nil {
+		return
+	}
+	u.eventRecorder.Eventf(object, eventType, reason, messageFmt, args...)
+}
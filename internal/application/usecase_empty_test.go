@@ -0,0 +1,266 @@
+package application
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEmptyIPsPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		value    string
+		expected EmptyIPsPolicy
+		hasError bool
+	}{
+		{
+			name:     "clear",
+			value:    "clear",
+			expected: EmptyIPsPolicy{Mode: EmptyIPsModeClear},
+		},
+		{
+			name:     "keep-last",
+			value:    "keep-last",
+			expected: EmptyIPsPolicy{Mode: EmptyIPsModeKeepLast},
+		},
+		{
+			name:     "hold-for",
+			value:    "hold-for=1m30s",
+			expected: EmptyIPsPolicy{Mode: EmptyIPsModeHoldFor, HoldFor: 90 * time.Second},
+		},
+		{
+			name:     "hold-for without duration",
+			value:    "hold-for",
+			hasError: true,
+		},
+		{
+			name:     "hold-for with negative duration",
+			value:    "hold-for=-1s",
+			hasError: true,
+		},
+		{
+			name:     "keep-last with argument",
+			value:    "keep-last=10s",
+			hasError: true,
+		},
+		{
+			name:     "unknown",
+			value:    "drop",
+			hasError: true,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := ParseEmptyIPsPolicy(tc.value)
+			if tc.hasError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestUsecase_holdLastIPs(t *testing.T) {
+	t.Parallel()
+
+	assignedSvc := func(annotations map[string]string) corev1.Service {
+		return corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "svc", Annotations: annotations},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+			Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{{IP: "10.222.0.1"}},
+			}},
+		}
+	}
+
+	// IPsAssigned has been False for a day, so that its LastTransitionTime tells nothing about holding
+	falseSince := metav1.NewTime(time.Now().Add(-24 * time.Hour))
+	staleSvc := func(since time.Time) corev1.Service {
+		svc := assignedSvc(nil)
+		svc.Status.Conditions = []metav1.Condition{{
+			Type:               ConditionTypeIPsAssigned,
+			Status:             metav1.ConditionFalse,
+			Reason:             ConditionReasonStaleAddresses,
+			Message:            staleAddressesMessage(since),
+			LastTransitionTime: falseSince,
+		}}
+		return svc
+	}
+	notEligibleSvc := assignedSvc(nil)
+	notEligibleSvc.Status.Conditions = []metav1.Condition{{
+		Type:               ConditionTypeIPsAssigned,
+		Status:             metav1.ConditionFalse,
+		Reason:             ConditionReasonNoEligibleNodes,
+		LastTransitionTime: falseSince,
+	}}
+
+	tests := []struct {
+		name             string
+		svc              corev1.Service
+		nodeIPs          NodeIPs
+		mappedIPs        IPStatus
+		targetIPs        IPStatus
+		defaultPolicy    EmptyIPsPolicy
+		expectedHold     bool
+		holdsFor         bool
+		expectedExpired  bool
+		expectedEventNum int
+	}{
+		{
+			name:          "clear",
+			svc:           assignedSvc(nil),
+			defaultPolicy: EmptyIPsPolicy{Mode: EmptyIPsModeClear},
+		},
+		{
+			name:             "keep-last",
+			svc:              assignedSvc(nil),
+			defaultPolicy:    EmptyIPsPolicy{Mode: EmptyIPsModeKeepLast},
+			expectedHold:     true,
+			expectedEventNum: 1,
+		},
+		{
+			name:          "keep-last, already holding",
+			svc:           staleSvc(time.Now().Add(-time.Hour)),
+			defaultPolicy: EmptyIPsPolicy{Mode: EmptyIPsModeKeepLast},
+			expectedHold:  true,
+		},
+		{
+			name:             "hold-for",
+			svc:              assignedSvc(nil),
+			defaultPolicy:    EmptyIPsPolicy{Mode: EmptyIPsModeHoldFor, HoldFor: time.Hour},
+			expectedHold:     true,
+			holdsFor:         true,
+			expectedEventNum: 1,
+		},
+		{
+			name:          "hold-for, already holding",
+			svc:           staleSvc(time.Now().Add(-time.Minute)),
+			defaultPolicy: EmptyIPsPolicy{Mode: EmptyIPsModeHoldFor, HoldFor: time.Hour},
+			expectedHold:  true,
+			holdsFor:      true,
+		},
+		{
+			name:            "hold-for, expired before restart",
+			svc:             staleSvc(time.Now().Add(-2 * time.Hour)),
+			defaultPolicy:   EmptyIPsPolicy{Mode: EmptyIPsModeHoldFor, HoldFor: time.Hour},
+			expectedExpired: true,
+		},
+		{
+			name:             "hold-for, IPsAssigned was False for another reason",
+			svc:              notEligibleSvc,
+			defaultPolicy:    EmptyIPsPolicy{Mode: EmptyIPsModeHoldFor, HoldFor: time.Hour},
+			expectedHold:     true,
+			holdsFor:         true,
+			expectedEventNum: 1,
+		},
+		{
+			name:          "annotation overrides default",
+			svc:           assignedSvc(map[string]string{LabelEmptyIPsPolicy: "clear"}),
+			defaultPolicy: EmptyIPsPolicy{Mode: EmptyIPsModeKeepLast},
+		},
+		{
+			name:          "not empty",
+			svc:           assignedSvc(nil),
+			targetIPs:     IPStatus{IngressIPs: []string{"10.222.0.2"}},
+			defaultPolicy: EmptyIPsPolicy{Mode: EmptyIPsModeKeepLast},
+		},
+		{
+			name: "candidates remain",
+			svc:  assignedSvc(nil),
+			nodeIPs: NodeIPs{Nodes: []NodeAddresses{{
+				Addresses: map[NodeAddressType][]string{NodeAddressTypeInternal: {"10.222.0.2"}},
+			}}},
+			mappedIPs:     IPStatus{IngressIPs: []string{"10.222.0.2"}},
+			defaultPolicy: EmptyIPsPolicy{Mode: EmptyIPsModeKeepLast},
+		},
+		{
+			name:          "nothing to keep",
+			svc:           corev1.Service{Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer}},
+			defaultPolicy: EmptyIPsPolicy{Mode: EmptyIPsModeKeepLast},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := &fakeEventRecorder{}
+			u := usecase{
+				eventRecorder:         recorder,
+				defaultEmptyIPsPolicy: tc.defaultPolicy,
+				emptySince:            newEmptySinceTracker(),
+			}
+			held := u.holdLastIPs(tc.svc, tc.nodeIPs, tc.mappedIPs, tc.targetIPs)
+			assert.Equal(t, tc.expectedHold, held.hold)
+			assert.Equal(t, tc.expectedHold, !held.since.IsZero())
+			assert.Equal(t, tc.expectedExpired, held.expiredAfter > 0)
+			assert.Len(t, recorder.events, tc.expectedEventNum)
+			if tc.holdsFor {
+				assert.Greater(t, held.recheckAfter, time.Duration(0))
+				assert.LessOrEqual(t, held.recheckAfter, time.Hour)
+			} else {
+				assert.Zero(t, held.recheckAfter)
+			}
+		})
+	}
+}
+
+func TestStaleSinceOf(t *testing.T) {
+	t.Parallel()
+
+	since := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	newSvc := func(reason, message string) corev1.Service {
+		return corev1.Service{Status: corev1.ServiceStatus{Conditions: []metav1.Condition{{
+			Type:    ConditionTypeIPsAssigned,
+			Status:  metav1.ConditionFalse,
+			Reason:  reason,
+			Message: message,
+		}}}}
+	}
+
+	tests := []struct {
+		name          string
+		svc           corev1.Service
+		expected      time.Time
+		expectedFound bool
+	}{
+		{
+			name:          "stale",
+			svc:           newSvc(ConditionReasonStaleAddresses, staleAddressesMessage(since)),
+			expected:      since,
+			expectedFound: true,
+		},
+		{
+			name: "another reason",
+			svc:  newSvc(ConditionReasonNoEligibleNodes, staleAddressesMessage(since)),
+		},
+		{
+			name: "message of an older version",
+			svc:  newSvc(ConditionReasonStaleAddresses, "No IP candidate remains, the last known IPs are kept"),
+		},
+		{
+			name: "no condition",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, found := staleSinceOf(tc.svc)
+			assert.Equal(t, tc.expectedFound, found)
+			assert.True(t, tc.expected.Equal(actual))
+		})
+	}
+}
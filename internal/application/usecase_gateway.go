@@ -0,0 +1,303 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/isac322/static-lb/internal/pkg/slices"
+	"github.com/isac322/static-lb/pkg/staticlb"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+// GatewayServiceKey returns the key of the Service that backs gw, if gw is bound to the instance named instanceName
+// and refers to one.
+func GatewayServiceKey(gw gatewayv1.Gateway, instanceName string) (types.NamespacedName, bool) {
+	if !isBoundTo(gw.Annotations, instanceName) {
+		return types.NamespacedName{}, false
+	}
+	return gatewayServiceKey(gw.Namespace, scopeAnnotations(gw.Annotations, instanceName))
+}
+
+// gatewayServiceKey returns the key of the Service that scoped annotations of a Gateway in namespace refer to.
+func gatewayServiceKey(namespace string, annotations map[string]string) (types.NamespacedName, bool) {
+	annotation, exists := annotations[LabelGatewayService]
+	if !exists || annotation == "" {
+		return types.NamespacedName{}, false
+	}
+
+	if svcNamespace, name, found := strings.Cut(annotation, "/"); found {
+		return types.NamespacedName{Namespace: svcNamespace, Name: name}, true
+	}
+	return types.NamespacedName{Namespace: namespace, Name: annotation}, true
+}
+
+// gatewayConfigError describes a misconfiguration of a Gateway that can not be fixed by retrying.
+type gatewayConfigError struct {
+	reason  gatewayv1.GatewayConditionReason
+	message string
+}
+
+func (e gatewayConfigError) Error() string {
+	return e.message
+}
+
+func (u usecase) AssignGatewayAddresses(ctx context.Context, gw gatewayv1.Gateway) error {
+	if !isBoundTo(gw.Annotations, u.instanceName) {
+		return nil
+	}
+
+	// scoped is only read; gw is written back as is
+	scoped := gw
+	scoped.Annotations = scopeAnnotations(gw.Annotations, u.instanceName)
+
+	requestedIPs, unsupported := requestedGatewayIPs(gw)
+	if len(unsupported) != 0 {
+		return u.assignGatewayConfigError(ctx, gw, gatewayConfigError{
+			reason:  gatewayv1.GatewayReasonUnsupportedAddress,
+			message: fmt.Sprintf("only IPAddress type is supported: %s", strings.Join(unsupported, ",")),
+		})
+	}
+
+	nodeIPs, err := u.collectGatewayNodeIPs(ctx, scoped)
+	var configErr gatewayConfigError
+	switch {
+	case errors.As(err, &configErr):
+		return u.assignGatewayConfigError(ctx, gw, configErr)
+	case err != nil:
+		return err
+	}
+
+	reserved, err := u.reservedIPNets(ctx)
+	if err != nil {
+		return err
+	}
+
+	targetIPs := u.mapIPs(nodeIPs, scoped.Annotations)
+	targetIPs = u.translateIPs(targetIPs, scoped.Annotations)
+	targetIPs = u.filterTargetIPs(targetIPs, scoped.Annotations, reserved)
+	targetIPs = u.arrangeByTopology(targetIPs, nodeIPs, scoped.Annotations)
+	addresses := targetIPs.IngressIPs
+
+	programmed := metav1.Condition{
+		Type:    string(gatewayv1.GatewayConditionProgrammed),
+		Status:  metav1.ConditionTrue,
+		Reason:  string(gatewayv1.GatewayReasonProgrammed),
+		Message: "Addresses are assigned",
+	}
+	if len(requestedIPs) != 0 {
+		var unavailable []string
+		addresses, unavailable = intersectIPs(requestedIPs, addresses)
+		if len(unavailable) != 0 {
+			programmed.Status = metav1.ConditionFalse
+			programmed.Reason = string(gatewayv1.GatewayReasonAddressNotAssigned)
+			programmed.Message = fmt.Sprintf(
+				"requested addresses are not eligible node IPs: %s",
+				strings.Join(unavailable, ","),
+			)
+		}
+	}
+	if len(addresses) == 0 && programmed.Status == metav1.ConditionTrue {
+		programmed.Status = metav1.ConditionFalse
+		programmed.Reason = string(gatewayv1.GatewayReasonAddressNotAssigned)
+		programmed.Message = "No eligible node IP"
+	}
+
+	conditions := []metav1.Condition{
+		{
+			Type:    string(gatewayv1.GatewayConditionAccepted),
+			Status:  metav1.ConditionTrue,
+			Reason:  string(gatewayv1.GatewayReasonAccepted),
+			Message: "Gateway is accepted by static-lb",
+		},
+		programmed,
+	}
+
+	return u.assignGatewayStatus(ctx, gw, addresses, conditions)
+}
+
+func (u usecase) assignGatewayConfigError(
+	ctx context.Context,
+	gw gatewayv1.Gateway,
+	configErr gatewayConfigError,
+) error {
+	return u.assignGatewayStatus(ctx, gw, nil, []metav1.Condition{
+		{
+			Type:    string(gatewayv1.GatewayConditionAccepted),
+			Status:  metav1.ConditionFalse,
+			Reason:  string(configErr.reason),
+			Message: configErr.message,
+		},
+		{
+			Type:    string(gatewayv1.GatewayConditionProgrammed),
+			Status:  metav1.ConditionFalse,
+			Reason:  string(gatewayv1.GatewayReasonInvalid),
+			Message: "Gateway is not accepted",
+		},
+	})
+}
+
+func (u usecase) assignGatewayStatus(
+	ctx context.Context,
+	gw gatewayv1.Gateway,
+	addresses []string,
+	conditions []metav1.Condition,
+) error {
+	for i := range conditions {
+		conditions[i].ObservedGeneration = gw.Generation
+	}
+
+	if isGatewaySynced(gw, addresses, conditions) {
+		return nil
+	}
+
+	return u.gatewayRepo.AssignAddresses(ctx, gw, addresses, conditions)
+}
+
+func (u usecase) collectGatewayNodeIPs(ctx context.Context, gw gatewayv1.Gateway) (NodeIPs, error) {
+	if svcKey, exists := gatewayServiceKey(gw.Namespace, gw.Annotations); exists {
+		if svcKey.Namespace != gw.Namespace {
+			grants, err := u.gatewayRepo.ListReferenceGrants(ctx, svcKey.Namespace)
+			if err != nil {
+				return NodeIPs{}, err
+			}
+			if !isServiceReferenceGranted(grants, gw, svcKey) {
+				return NodeIPs{}, gatewayConfigError{
+					reason: gatewayv1.GatewayReasonInvalid,
+					message: fmt.Sprintf(
+						"backing Service %s is in another namespace and no ReferenceGrant allows the reference",
+						svcKey,
+					),
+				}
+			}
+		}
+
+		svc, err := u.serviceRepo.Get(ctx, svcKey)
+		switch {
+		case apierrors.IsNotFound(err):
+			return NodeIPs{}, gatewayConfigError{
+				reason:  gatewayv1.GatewayReasonInvalid,
+				message: fmt.Sprintf("backing Service %s is not found", svcKey),
+			}
+		case err != nil:
+			return NodeIPs{}, err
+		}
+
+		nodeIPs, err := u.collectNodeIPsByTrafficPolicy(ctx, svc)
+		if err != nil {
+			return NodeIPs{}, err
+		}
+		if nodeIPs.IsNone() {
+			return NodeIPs{}, gatewayConfigError{
+				reason:  gatewayv1.GatewayReasonInvalid,
+				message: fmt.Sprintf("backing Service %s has no external traffic policy", svcKey),
+			}
+		}
+		return nodeIPs.Unwrap(), nil
+	}
+
+	selector, err := labels.Parse(gw.Annotations[LabelGatewayNodeSelector])
+	if err != nil {
+		return NodeIPs{}, gatewayConfigError{
+			reason:  gatewayv1.GatewayReasonInvalid,
+			message: fmt.Sprintf("invalid node selector: %s", err),
+		}
+	}
+
+	nodes, err := u.nodeRepo.ListReadyMatching(ctx, selector)
+	if err != nil {
+		return NodeIPs{}, err
+	}
+	return u.sources().NodeIPsOf(nodes), nil
+}
+
+// isServiceReferenceGranted reports whether a ReferenceGrant of grants, which are in the namespace of svcKey, allows
+// Gateways of the namespace of gw to refer to the Service of svcKey.
+func isServiceReferenceGranted(
+	grants []gatewayv1beta1.ReferenceGrant,
+	gw gatewayv1.Gateway,
+	svcKey types.NamespacedName,
+) bool {
+	for _, grant := range grants {
+		if grant.Namespace != svcKey.Namespace {
+			continue
+		}
+
+		fromGateway := false
+		for _, from := range grant.Spec.From {
+			if from.Group == gatewayv1.GroupName && from.Kind == "Gateway" && string(from.Namespace) == gw.Namespace {
+				fromGateway = true
+				break
+			}
+		}
+		if !fromGateway {
+			continue
+		}
+
+		for _, to := range grant.Spec.To {
+			if to.Group == corev1.GroupName && to.Kind == "Service" && (to.Name == nil || string(*to.Name) == svcKey.Name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// requestedGatewayIPs returns IPs requested in spec.addresses of gw, and values of unsupported address types.
+func requestedGatewayIPs(gw gatewayv1.Gateway) (ips []string, unsupported []string) {
+	for _, address := range gw.Spec.Addresses {
+		if address.Type != nil && *address.Type != gatewayv1.IPAddressType {
+			unsupported = append(unsupported, address.Value)
+			continue
+		}
+		ips = append(ips, address.Value)
+	}
+	return ips, unsupported
+}
+
+// intersectIPs returns IPs of requested that are also in eligible, and the rest of requested.
+func intersectIPs(requested []string, eligible []string) (available []string, unavailable []string) {
+	eligibleSet := make(map[string]struct{}, len(eligible))
+	for _, ip := range staticlb.ParseIPs(eligible) {
+		eligibleSet[ip.String()] = struct{}{}
+	}
+
+	for _, ip := range requested {
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			unavailable = append(unavailable, ip)
+			continue
+		}
+		if _, exists := eligibleSet[parsed.String()]; exists {
+			available = append(available, parsed.String())
+		} else {
+			unavailable = append(unavailable, ip)
+		}
+	}
+	return available, unavailable
+}
+
+func isGatewaySynced(gw gatewayv1.Gateway, addresses []string, conditions []metav1.Condition) bool {
+	origAddresses := make([]string, len(gw.Status.Addresses))
+	for i, address := range gw.Status.Addresses {
+		origAddresses[i] = address.Value
+	}
+	if !slices.Equal(addresses, origAddresses) {
+		return false
+	}
+
+	managedTypes := make([]string, len(conditions))
+	for i, condition := range conditions {
+		managedTypes[i] = condition.Type
+	}
+	return isConditionsSynced(gw.Status.Conditions, conditions, managedTypes)
+}
@@ -0,0 +1,271 @@
+package application
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeGatewayRepository struct {
+	assigned map[string][]string
+}
+
+func (f *fakeGatewayRepository) AssignAddresses(
+	_ context.Context,
+	gw gatewayv1.Gateway,
+	addresses []string,
+	_ []metav1.Condition,
+) error {
+	if f.assigned == nil {
+		f.assigned = make(map[string][]string)
+	}
+	f.assigned[gw.Name] = addresses
+	return nil
+}
+
+func (f *fakeGatewayRepository) ListReferenceGrants(context.Context, string) ([]gatewayv1beta1.ReferenceGrant, error) {
+	return nil, nil
+}
+
+func TestGatewayServiceKey(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		instanceName string
+		expected     types.NamespacedName
+		exists       bool
+	}{
+		{
+			name: "no annotation",
+		},
+		{
+			name:        "same namespace",
+			annotations: map[string]string{LabelGatewayService: "envoy"},
+			expected:    types.NamespacedName{Namespace: "gateway", Name: "envoy"},
+			exists:      true,
+		},
+		{
+			name:        "other namespace",
+			annotations: map[string]string{LabelGatewayService: "envoy-system/envoy"},
+			expected:    types.NamespacedName{Namespace: "envoy-system", Name: "envoy"},
+			exists:      true,
+		},
+		{
+			name: "named instance",
+			annotations: map[string]string{
+				LabelInstance:       "public",
+				LabelGatewayService: "envoy",
+				"public.static-lb.bhyoo.com/gateway-service": "public-envoy",
+			},
+			instanceName: "public",
+			expected:     types.NamespacedName{Namespace: "gateway", Name: "public-envoy"},
+			exists:       true,
+		},
+		{
+			name: "bound to another instance",
+			annotations: map[string]string{
+				LabelInstance:       "private",
+				LabelGatewayService: "envoy",
+			},
+			instanceName: "public",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			gw := gatewayv1.Gateway{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "gateway", Name: "gw", Annotations: tc.annotations},
+			}
+			actual, exists := GatewayServiceKey(gw, tc.instanceName)
+			assert.Equal(t, tc.exists, exists)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestUsecase_AssignGatewayAddresses(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		instanceName string
+		expected     map[string][]string
+	}{
+		{
+			name:     "default instance",
+			expected: map[string][]string{"gw": {"10.0.0.1", "10.0.0.2"}},
+		},
+		{
+			name:         "bound to another instance",
+			annotations:  map[string]string{LabelInstance: "private"},
+			instanceName: "public",
+			expected:     nil,
+		},
+		{
+			name: "annotations of the named instance",
+			annotations: map[string]string{
+				LabelInstance:             "public",
+				LabelIncludeIngressIPNets: "10.0.0.2/32",
+				"public.static-lb.bhyoo.com/include-ingress-ip-nets": "10.0.0.1/32",
+			},
+			instanceName: "public",
+			expected:     map[string][]string{"gw": {"10.0.0.1"}},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			gatewayRepo := &fakeGatewayRepository{}
+			u := usecase{
+				nodeRepo: fakeNodeRepository{nodes: map[string]corev1.Node{
+					"node-a": {
+						ObjectMeta: metav1.ObjectMeta{Name: "node-a"},
+						Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+							{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+							{Type: corev1.NodeInternalIP, Address: "10.0.0.2"},
+						}},
+					},
+				}},
+				gatewayRepo:               gatewayRepo,
+				defaultInternalIPMappings: []IPMappingTarget{IPMappingTargetIngress},
+				instanceName:              tc.instanceName,
+			}
+
+			gw := gatewayv1.Gateway{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "gateway", Name: "gw", Annotations: tc.annotations},
+			}
+			require.NoError(t, u.AssignGatewayAddresses(context.Background(), gw))
+			assert.Equal(t, tc.expected, gatewayRepo.assigned)
+		})
+	}
+}
+
+func TestIntersectIPs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                string
+		requested           []string
+		eligible            []string
+		expectedAvailable   []string
+		expectedUnavailable []string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:              "all available",
+			requested:         []string{"10.222.0.1", "fcad:31:ca:0::312"},
+			eligible:          []string{"10.222.0.2", "10.222.0.1", "fcad:31:ca::312"},
+			expectedAvailable: []string{"10.222.0.1", "fcad:31:ca::312"},
+		},
+		{
+			name:                "partially available",
+			requested:           []string{"10.222.0.1", "10.222.0.3", "invalid"},
+			eligible:            []string{"10.222.0.1", "10.222.0.2"},
+			expectedAvailable:   []string{"10.222.0.1"},
+			expectedUnavailable: []string{"10.222.0.3", "invalid"},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			available, unavailable := intersectIPs(tc.requested, tc.eligible)
+			assert.Equal(t, tc.expectedAvailable, available)
+			assert.Equal(t, tc.expectedUnavailable, unavailable)
+		})
+	}
+}
+
+func TestIsServiceReferenceGranted(t *testing.T) {
+	t.Parallel()
+
+	gw := gatewayv1.Gateway{ObjectMeta: metav1.ObjectMeta{Namespace: "gateway", Name: "gw"}}
+	svcKey := types.NamespacedName{Namespace: "envoy-system", Name: "envoy"}
+	newGrant := func(
+		namespace string,
+		fromKind gatewayv1.Kind,
+		fromNamespace string,
+		toName *string,
+	) gatewayv1beta1.ReferenceGrant {
+		grant := gatewayv1beta1.ReferenceGrant{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "grant"},
+			Spec: gatewayv1beta1.ReferenceGrantSpec{
+				From: []gatewayv1beta1.ReferenceGrantFrom{{
+					Group:     gatewayv1.GroupName,
+					Kind:      fromKind,
+					Namespace: gatewayv1.Namespace(fromNamespace),
+				}},
+				To: []gatewayv1beta1.ReferenceGrantTo{{Kind: "Service"}},
+			},
+		}
+		if toName != nil {
+			name := gatewayv1.ObjectName(*toName)
+			grant.Spec.To[0].Name = &name
+		}
+		return grant
+	}
+	envoy := "envoy"
+	other := "other"
+
+	tests := []struct {
+		name     string
+		grants   []gatewayv1beta1.ReferenceGrant
+		expected bool
+	}{
+		{
+			name: "no grant",
+		},
+		{
+			name:     "every Service of the namespace",
+			grants:   []gatewayv1beta1.ReferenceGrant{newGrant("envoy-system", "Gateway", "gateway", nil)},
+			expected: true,
+		},
+		{
+			name:     "the Service by name",
+			grants:   []gatewayv1beta1.ReferenceGrant{newGrant("envoy-system", "Gateway", "gateway", &envoy)},
+			expected: true,
+		},
+		{
+			name:   "another Service by name",
+			grants: []gatewayv1beta1.ReferenceGrant{newGrant("envoy-system", "Gateway", "gateway", &other)},
+		},
+		{
+			name:   "Gateways of another namespace",
+			grants: []gatewayv1beta1.ReferenceGrant{newGrant("envoy-system", "Gateway", "other", nil)},
+		},
+		{
+			name:   "HTTPRoutes",
+			grants: []gatewayv1beta1.ReferenceGrant{newGrant("envoy-system", "HTTPRoute", "gateway", nil)},
+		},
+		{
+			name:   "grant of another namespace",
+			grants: []gatewayv1beta1.ReferenceGrant{newGrant("gateway", "Gateway", "gateway", nil)},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, isServiceReferenceGranted(tc.grants, gw, svcKey))
+		})
+	}
+}
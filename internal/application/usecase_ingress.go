@@ -0,0 +1,72 @@
+package application
+
+import (
+	"context"
+
+	"github.com/isac322/static-lb/internal/pkg/slices"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// IngressClassOf returns the class of ing, falling back to the legacy annotation.
+func IngressClassOf(ing networkingv1.Ingress) string {
+	if ing.Spec.IngressClassName != nil {
+		return *ing.Spec.IngressClassName
+	}
+	return ing.Annotations[LabelLegacyIngressClass]
+}
+
+func (u usecase) AssignIngressIPs(ctx context.Context, ing networkingv1.Ingress) error {
+	if !isBoundTo(ing.Annotations, u.instanceName) {
+		return nil
+	}
+	annotations := scopeAnnotations(ing.Annotations, u.instanceName)
+
+	nodeIPs, err := u.getIPsFromIngressControllerPods(ctx, &ing)
+	if err != nil {
+		return err
+	}
+
+	reserved, err := u.reservedIPNets(ctx)
+	if err != nil {
+		return err
+	}
+
+	targetIPs := u.mapIPs(nodeIPs, annotations)
+	targetIPs = u.translateIPs(targetIPs, annotations)
+	targetIPs = u.filterTargetIPs(targetIPs, annotations, reserved)
+	targetIPs = u.arrangeByTopology(targetIPs, nodeIPs, annotations)
+
+	origIPs := make([]string, len(ing.Status.LoadBalancer.Ingress))
+	for i, ingress := range ing.Status.LoadBalancer.Ingress {
+		origIPs[i] = ingress.IP
+	}
+	if slices.Equal(targetIPs.IngressIPs, origIPs) {
+		return nil
+	}
+
+	return u.ingressRepo.AssignIPs(ctx, ing, targetIPs.IngressIPs)
+}
+
+func (u usecase) getIPsFromIngressControllerPods(ctx context.Context, ing *networkingv1.Ingress) (NodeIPs, error) {
+	pods, err := u.podRepo.ListReadyMatching(
+		ctx,
+		u.ingressControllerPods.Namespace,
+		u.ingressControllerPods.Selector,
+	)
+	if err != nil {
+		return NodeIPs{}, err
+	}
+
+	nodeNames := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		nodeNames = append(nodeNames, pod.Spec.NodeName)
+	}
+
+	nodes, err := u.getNodesByNames(ctx, ing, nodeNames)
+	if err != nil {
+		return NodeIPs{}, err
+	}
+
+	return u.sources().NodeIPsOf(nodes), nil
+}
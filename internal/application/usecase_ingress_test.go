@@ -0,0 +1,93 @@
+package application
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePodRepository struct {
+	pods []corev1.Pod
+}
+
+func (f fakePodRepository) ListReadyMatching(context.Context, string, labels.Selector) ([]corev1.Pod, error) {
+	return f.pods, nil
+}
+
+type fakeIngressRepository struct {
+	assigned map[string][]string
+}
+
+func (f *fakeIngressRepository) AssignIPs(_ context.Context, ing networkingv1.Ingress, ips []string) error {
+	if f.assigned == nil {
+		f.assigned = make(map[string][]string)
+	}
+	f.assigned[ing.Name] = ips
+	return nil
+}
+
+func TestUsecase_AssignIngressIPs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		instanceName string
+		expected     map[string][]string
+	}{
+		{
+			name:     "default instance",
+			expected: map[string][]string{"web": {"10.0.0.1", "10.0.0.2"}},
+		},
+		{
+			name:         "bound to another instance",
+			annotations:  map[string]string{LabelInstance: "private"},
+			instanceName: "public",
+			expected:     nil,
+		},
+		{
+			name: "annotations of the named instance",
+			annotations: map[string]string{
+				LabelInstance:             "public",
+				LabelIncludeIngressIPNets: "10.0.0.2/32",
+				"public.static-lb.bhyoo.com/include-ingress-ip-nets": "10.0.0.1/32",
+			},
+			instanceName: "public",
+			expected:     map[string][]string{"web": {"10.0.0.1"}},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ingressRepo := &fakeIngressRepository{}
+			u := usecase{
+				nodeRepo: fakeNodeRepository{nodes: map[string]corev1.Node{
+					"node-a": newFakeNode("node-a", "10.0.0.1"),
+					"node-b": newFakeNode("node-b", "10.0.0.2"),
+				}},
+				podRepo: fakePodRepository{pods: []corev1.Pod{
+					{Spec: corev1.PodSpec{NodeName: "node-a"}},
+					{Spec: corev1.PodSpec{NodeName: "node-b"}},
+				}},
+				ingressRepo:               ingressRepo,
+				defaultInternalIPMappings: []IPMappingTarget{IPMappingTargetIngress},
+				instanceName:              tc.instanceName,
+			}
+
+			ing := networkingv1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", Annotations: tc.annotations},
+			}
+			require.NoError(t, u.AssignIngressIPs(context.Background(), ing))
+			assert.Equal(t, tc.expected, ingressRepo.assigned)
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package application
+
+import (
+	"strings"
+)
+
+// isBoundTo reports whether the object of annotations is bound to the instance named instanceName.
+func isBoundTo(annotations map[string]string, instanceName string) bool {
+	return annotations[LabelInstance] == instanceName
+}
+
+// scopeAnnotations returns annotations as the instance named instanceName sees them:
+// "<instanceName>.static-lb.bhyoo.com/..." are renamed to "static-lb.bhyoo.com/...", which belong to the instance
+// without a name, and are dropped. Other annotations are kept. annotations is not modified.
+func scopeAnnotations(annotations map[string]string, instanceName string) map[string]string {
+	if instanceName == "" {
+		return annotations
+	}
+
+	ownPrefix := instanceName + "." + annotationDomain + "/"
+	defaultPrefix := annotationDomain + "/"
+
+	scoped := make(map[string]string, len(annotations))
+	for key, val := range annotations {
+		switch {
+		case strings.HasPrefix(key, ownPrefix):
+			scoped[defaultPrefix+strings.TrimPrefix(key, ownPrefix)] = val
+		case strings.HasPrefix(key, defaultPrefix) && key != LabelInstance:
+			continue
+		default:
+			scoped[key] = val
+		}
+	}
+	return scoped
+}
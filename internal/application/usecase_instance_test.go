@@ -0,0 +1,98 @@
+package application
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsBoundTo(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		instanceName string
+		expected     bool
+	}{
+		{
+			name:         "no annotation to default instance",
+			instanceName: "",
+			expected:     true,
+		},
+		{
+			name:         "no annotation to named instance",
+			instanceName: "public",
+			expected:     false,
+		},
+		{
+			name:         "bound to the instance",
+			annotations:  map[string]string{LabelInstance: "public"},
+			instanceName: "public",
+			expected:     true,
+		},
+		{
+			name:         "bound to another instance",
+			annotations:  map[string]string{LabelInstance: "private"},
+			instanceName: "public",
+			expected:     false,
+		},
+		{
+			name:         "bound to named instance from default instance",
+			annotations:  map[string]string{LabelInstance: "private"},
+			instanceName: "",
+			expected:     false,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, isBoundTo(tc.annotations, tc.instanceName))
+		})
+	}
+}
+
+func TestScopeAnnotations(t *testing.T) {
+	t.Parallel()
+
+	annotations := map[string]string{
+		LabelInstance:             "public",
+		LabelIncludeIngressIPNets: "10.0.0.0/8",
+		LabelFrozen:               "true",
+		"public.static-lb.bhyoo.com/include-ingress-ip-nets": "203.0.113.0/24",
+		"private.static-lb.bhyoo.com/ip-mappings":            "ingress=internal",
+		"example.com/owner":                                  "team-a",
+	}
+
+	tests := []struct {
+		name         string
+		instanceName string
+		expected     map[string]string
+	}{
+		{
+			name:         "default instance",
+			instanceName: "",
+			expected:     annotations,
+		},
+		{
+			name:         "named instance",
+			instanceName: "public",
+			expected: map[string]string{
+				LabelInstance:                             "public",
+				LabelIncludeIngressIPNets:                 "203.0.113.0/24",
+				"example.com/owner":                       "team-a",
+				"private.static-lb.bhyoo.com/ip-mappings": "ingress=internal",
+			},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, scopeAnnotations(annotations, tc.instanceName))
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package application
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// inventoryEntryOf builds the inventory entry of svc that is published on targetIPs. Every list of the entry is
+// sorted, so that the entry does not change with the order in which IPs, ports and nodes are listed.
+func inventoryEntryOf(svc corev1.Service, nodeIPs NodeIPs, targetIPs IPStatus) InventoryEntry {
+	entry := InventoryEntry{
+		Service:     types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name},
+		IngressIPs:  sortedCopy(targetIPs.IngressIPs),
+		ExternalIPs: sortedCopy(targetIPs.ExternalIPs),
+	}
+	for _, port := range svc.Spec.Ports {
+		entry.Ports = append(entry.Ports, InventoryPort{
+			Name:     port.Name,
+			Protocol: string(port.Protocol),
+			Port:     port.Port,
+			NodePort: port.NodePort,
+		})
+	}
+	sort.Slice(entry.Ports, func(i, j int) bool {
+		a, b := entry.Ports[i], entry.Ports[j]
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		return a.Name < b.Name
+	})
+	for _, node := range nodeIPs.Nodes {
+		entry.Nodes = append(entry.Nodes, node.Name)
+	}
+	sort.Strings(entry.Nodes)
+	return entry
+}
+
+func sortedCopy(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	result := make([]string, len(s))
+	copy(result, s)
+	sort.Strings(result)
+	return result
+}
+
+// updateInventory puts the entry of svc to the inventory if it is changed since the last time,
+// or deletes it if svc is not published on any IP.
+func (u usecase) updateInventory(ctx context.Context, svc corev1.Service, nodeIPs NodeIPs, targetIPs IPStatus) error {
+	if u.inventoryRepo == nil {
+		return nil
+	}
+
+	svcKey := types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name}
+	if svc.Spec.Type != corev1.ServiceTypeLoadBalancer || targetIPs.IsEmpty() {
+		return u.deleteFromInventory(ctx, svcKey)
+	}
+
+	entry := inventoryEntryOf(svc, nodeIPs, targetIPs)
+	if !u.inventory.changed(svcKey, &entry) {
+		return nil
+	}
+	if err := u.inventoryRepo.Put(ctx, entry); err != nil {
+		return err
+	}
+	u.inventory.record(svcKey, &entry)
+	return nil
+}
+
+// PruneInventory deletes entries of Services that no longer exist or are no longer LoadBalancers, e.g. ones that were
+// deleted while static-lb was down and whose deletion is never reconciled.
+func (u usecase) PruneInventory(ctx context.Context) error {
+	if u.inventoryRepo == nil {
+		return nil
+	}
+
+	services, err := u.serviceRepo.List(ctx)
+	if err != nil {
+		return err
+	}
+	loadBalancers := make(map[types.NamespacedName]struct{}, len(services))
+	for _, svc := range services {
+		if svc.Spec.Type == corev1.ServiceTypeLoadBalancer {
+			loadBalancers[types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name}] = struct{}{}
+		}
+	}
+
+	return u.inventoryRepo.Retain(ctx, func(svcKey types.NamespacedName) bool {
+		_, exists := loadBalancers[svcKey]
+		return exists
+	})
+}
+
+func (u usecase) deleteFromInventory(ctx context.Context, svcKey types.NamespacedName) error {
+	if u.inventoryRepo == nil || !u.inventory.changed(svcKey, nil) {
+		return nil
+	}
+	if err := u.inventoryRepo.Delete(ctx, svcKey); err != nil {
+		return err
+	}
+	u.inventory.record(svcKey, nil)
+	return nil
+}
+
+// inventoryTracker remembers what is written to the inventory for each Service, to skip unchanged writes.
+// A nil entry means that the Service is deleted from the inventory.
+type inventoryTracker struct {
+	mu      sync.Mutex
+	entries map[types.NamespacedName]*InventoryEntry
+}
+
+func newInventoryTracker() *inventoryTracker {
+	return &inventoryTracker{entries: map[types.NamespacedName]*InventoryEntry{}}
+}
+
+func (t *inventoryTracker) changed(key types.NamespacedName, entry *InventoryEntry) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	last, exists := t.entries[key]
+	return !exists || !reflect.DeepEqual(last, entry)
+}
+
+func (t *inventoryTracker) record(key types.NamespacedName, entry *InventoryEntry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.entries[key] = entry
+}
+
+func (t *inventoryTracker) forget(key types.NamespacedName) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.entries, key)
+}
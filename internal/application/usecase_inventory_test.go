@@ -0,0 +1,195 @@
+package application
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeInventoryRepository struct {
+	// entries are keys of Services in the inventory before the test
+	entries []types.NamespacedName
+	puts    []InventoryEntry
+	deletes []types.NamespacedName
+}
+
+func (f *fakeInventoryRepository) Put(_ context.Context, entry InventoryEntry) error {
+	f.puts = append(f.puts, entry)
+	return nil
+}
+
+func (f *fakeInventoryRepository) Delete(_ context.Context, svcKey types.NamespacedName) error {
+	f.deletes = append(f.deletes, svcKey)
+	return nil
+}
+
+func (f *fakeInventoryRepository) Retain(_ context.Context, keep func(svcKey types.NamespacedName) bool) error {
+	for _, svcKey := range f.entries {
+		if !keep(svcKey) {
+			f.deletes = append(f.deletes, svcKey)
+		}
+	}
+	return nil
+}
+
+func TestUsecase_updateInventory(t *testing.T) {
+	t.Parallel()
+
+	svcKey := types.NamespacedName{Namespace: "default", Name: "svc"}
+	lbService := corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: svcKey.Namespace, Name: svcKey.Name},
+		Spec: corev1.ServiceSpec{
+			Type: corev1.ServiceTypeLoadBalancer,
+			Ports: []corev1.ServicePort{
+				{Name: "http", Protocol: corev1.ProtocolTCP, Port: 80, NodePort: 30080},
+				{Name: "https", Protocol: corev1.ProtocolTCP, Port: 443, NodePort: 30443},
+			},
+		},
+	}
+	nodeIPs := NodeIPs{Nodes: []NodeAddresses{
+		{Name: "node-b", Addresses: map[NodeAddressType][]string{NodeAddressTypeInternal: {"192.168.0.2"}}},
+		{Name: "node-a", Addresses: map[NodeAddressType][]string{NodeAddressTypeInternal: {"192.168.0.1"}}},
+	}}
+	targetIPs := IPStatus{IngressIPs: []string{"192.168.0.1", "192.168.0.2"}}
+	expectedEntry := InventoryEntry{
+		Service:    svcKey,
+		IngressIPs: []string{"192.168.0.1", "192.168.0.2"},
+		Ports: []InventoryPort{
+			{Name: "http", Protocol: "TCP", Port: 80, NodePort: 30080},
+			{Name: "https", Protocol: "TCP", Port: 443, NodePort: 30443},
+		},
+		Nodes: []string{"node-a", "node-b"},
+	}
+	reorderedService := *lbService.DeepCopy()
+	reorderedService.Spec.Ports = []corev1.ServicePort{lbService.Spec.Ports[1], lbService.Spec.Ports[0]}
+
+	type step struct {
+		svc       corev1.Service
+		targetIPs IPStatus
+	}
+	tests := []struct {
+		name            string
+		steps           []step
+		expectedPuts    []InventoryEntry
+		expectedDeletes []types.NamespacedName
+	}{
+		{
+			name:         "unchanged entry is put once",
+			steps:        []step{{lbService, targetIPs}, {lbService, targetIPs}},
+			expectedPuts: []InventoryEntry{expectedEntry},
+		},
+		{
+			name: "reordered IPs, ports and nodes are unchanged",
+			steps: []step{
+				{lbService, targetIPs},
+				{reorderedService, IPStatus{IngressIPs: []string{"192.168.0.2", "192.168.0.1"}}},
+			},
+			expectedPuts: []InventoryEntry{expectedEntry},
+		},
+		{
+			name:            "deleted once IPs are gone",
+			steps:           []step{{lbService, targetIPs}, {lbService, IPStatus{}}, {lbService, IPStatus{}}},
+			expectedPuts:    []InventoryEntry{expectedEntry},
+			expectedDeletes: []types.NamespacedName{svcKey},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			repo := &fakeInventoryRepository{}
+			u := usecase{inventoryRepo: repo, inventory: newInventoryTracker()}
+			for _, s := range tc.steps {
+				require.NoError(t, u.updateInventory(context.Background(), s.svc, nodeIPs, s.targetIPs))
+			}
+
+			assert.Equal(t, tc.expectedPuts, repo.puts)
+			assert.Equal(t, tc.expectedDeletes, repo.deletes)
+		})
+	}
+}
+
+func TestUsecase_Remove(t *testing.T) {
+	t.Parallel()
+
+	svcKey := types.NamespacedName{Namespace: "default", Name: "svc"}
+
+	tests := []struct {
+		name            string
+		recorded        bool
+		entry           *InventoryEntry
+		expectedDeletes []types.NamespacedName
+	}{
+		{
+			name:            "unknown Service",
+			expectedDeletes: []types.NamespacedName{svcKey},
+		},
+		{
+			name:            "Service in the inventory",
+			recorded:        true,
+			entry:           &InventoryEntry{Service: svcKey},
+			expectedDeletes: []types.NamespacedName{svcKey},
+		},
+		{
+			name:     "Service already deleted from the inventory",
+			recorded: true,
+			entry:    nil,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			repo := &fakeInventoryRepository{}
+			u := usecase{
+				inventoryRepo: repo,
+				inventory:     newInventoryTracker(),
+				emptySince:    newEmptySinceTracker(),
+				pendingIPs:    newPendingIPTracker(),
+				lastAssigned:  newLastAssignmentTracker(),
+				ports:         newPortIndex(),
+			}
+			if tc.recorded {
+				u.inventory.record(svcKey, tc.entry)
+			}
+
+			require.NoError(t, u.Remove(context.Background(), svcKey))
+			assert.Equal(t, tc.expectedDeletes, repo.deletes)
+			assert.True(t, u.inventory.changed(svcKey, nil), "the Service is forgotten")
+		})
+	}
+}
+
+func TestUsecase_PruneInventory(t *testing.T) {
+	t.Parallel()
+
+	lb := types.NamespacedName{Namespace: "default", Name: "lb"}
+	clusterIP := types.NamespacedName{Namespace: "default", Name: "cluster-ip"}
+	deleted := types.NamespacedName{Namespace: "default", Name: "deleted"}
+
+	repo := &fakeInventoryRepository{entries: []types.NamespacedName{lb, clusterIP, deleted}}
+	u := usecase{
+		inventoryRepo: repo,
+		serviceRepo: fakeServiceRepository{services: []corev1.Service{
+			{
+				ObjectMeta: metav1.ObjectMeta{Namespace: lb.Namespace, Name: lb.Name},
+				Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Namespace: clusterIP.Namespace, Name: clusterIP.Name},
+				Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP},
+			},
+		}},
+	}
+
+	require.NoError(t, u.PruneInventory(context.Background()))
+	assert.Equal(t, []types.NamespacedName{clusterIP, deleted}, repo.deletes)
+}
@@ -1,60 +1,28 @@
 package application
 
 import (
-	"strings"
-
-	corev1 "k8s.io/api/core/v1"
+	"github.com/isac322/static-lb/pkg/staticlb"
 )
 
-func (u usecase) mapIPs(nodeIPs NodeIPs, svc corev1.Service) IPStatus {
-	var targetIPs IPStatus
-
-	for _, mapping := range getMappings(svc, LabelInternalIPMappings, u.defaultInternalIPMappings) {
-		switch mapping {
-		case IPMappingTargetExternal:
-			targetIPs.ExternalIPs = append(targetIPs.ExternalIPs, nodeIPs.InternalIPs...)
-		case IPMappingTargetIngress:
-			targetIPs.IngressIPs = append(targetIPs.IngressIPs, nodeIPs.InternalIPs...)
-		default:
-			break
-		}
-	}
-
-	for _, mapping := range getMappings(svc, LabelExternalIPMappings, u.defaultExternalIPMappings) {
-		switch mapping {
-		case IPMappingTargetExternal:
-			targetIPs.ExternalIPs = append(targetIPs.ExternalIPs, nodeIPs.ExternalIPs...)
-		case IPMappingTargetIngress:
-			targetIPs.IngressIPs = append(targetIPs.IngressIPs, nodeIPs.ExternalIPs...)
-		default:
-			break
-		}
+// pipelineConfig is the defaults of the usecase as the configuration of staticlb.
+func (u usecase) pipelineConfig() staticlb.Config {
+	return staticlb.Config{
+		InternalIPMappings:    u.defaultInternalIPMappings,
+		ExternalIPMappings:    u.defaultExternalIPMappings,
+		IPMappingPriorities:   u.defaultIPMappingPriorities,
+		NodeAddressSelection:  u.defaultNodeAddressSelection,
+		IncludeIngressIPNets:  u.defaultIncludeIngressIPNetwork,
+		IncludeExternalIPNets: u.defaultIncludeExternalIPNetwork,
+		ExcludeIngressIPNets:  u.defaultExcludeIngressIPNetwork,
+		ExcludeExternalIPNets: u.defaultExcludeExternalIPNetwork,
+		NATRules:              u.defaultNATRules,
+		DropUntranslatedIPs:   u.defaultDropUntranslatedIPs,
+		Topology:              u.defaultTopology,
+		AddressSources:        u.sources(),
 	}
-
-	return targetIPs
 }
 
-func getMappings(svc corev1.Service, annotationName string, defaultVal []IPMappingTarget) []IPMappingTarget {
-	annotation, exists := svc.Annotations[annotationName]
-	if !exists {
-		return defaultVal
-	}
-	if annotation == "" {
-		return nil
-	}
-
-	splitted := strings.Split(annotation, ",")
-	result := make([]IPMappingTarget, 0, len(splitted))
-	for _, s := range splitted {
-		switch IPMappingTarget(s) {
-		case IPMappingTargetExternal:
-			result = append(result, IPMappingTargetExternal)
-		case IPMappingTargetIngress:
-			result = append(result, IPMappingTargetIngress)
-		default:
-			continue
-		}
-	}
-
-	return result
+func (u usecase) mapIPs(nodeIPs NodeIPs, annotations map[string]string) IPStatus {
+	targetIPs, _ := staticlb.MapIPs(nodeIPs, annotations, u.pipelineConfig())
+	return targetIPs
 }
@@ -0,0 +1,112 @@
+package application
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsecase_mapIPs(t *testing.T) {
+	t.Parallel()
+
+	nodeIPs := NodeIPs{Nodes: []NodeAddresses{
+		{
+			Name: "cloud",
+			Addresses: map[NodeAddressType][]string{
+				NodeAddressTypeInternal: {"10.0.0.1", "fd00::1"},
+				NodeAddressTypeExternal: {"203.0.113.1", "203.0.113.2", "2001:db8::1"},
+				NodeAddressTypeHostname: {"cloud.example.com"},
+			},
+		},
+		{
+			Name: "on-prem",
+			Addresses: map[NodeAddressType][]string{
+				NodeAddressTypeInternal:   {"192.168.0.1", "192.168.0.2"},
+				NodeAddressTypeAnnotation: {"198.51.100.1"},
+				NodeAddressTypeHostname:   {"192.168.0.10"},
+			},
+		},
+	}}
+
+	tests := []struct {
+		name                        string
+		annotations                 map[string]string
+		defaultInternalIPMappings   []IPMappingTarget
+		defaultExternalIPMappings   []IPMappingTarget
+		defaultIPMappingPriorities  []IPMappingPriority
+		defaultNodeAddressSelection NodeAddressSelection
+		expected                    IPStatus
+	}{
+		{
+			name:                      "global mappings",
+			defaultInternalIPMappings: []IPMappingTarget{IPMappingTargetExternal},
+			defaultExternalIPMappings: []IPMappingTarget{IPMappingTargetIngress},
+			expected: IPStatus{
+				IngressIPs:  []string{"203.0.113.1", "203.0.113.2", "2001:db8::1"},
+				ExternalIPs: []string{"10.0.0.1", "fd00::1", "192.168.0.1", "192.168.0.2"},
+			},
+		},
+		{
+			name: "fallback per node",
+			defaultIPMappingPriorities: []IPMappingPriority{
+				{
+					Target: IPMappingTargetIngress,
+					Types:  []NodeAddressType{NodeAddressTypeExternal, NodeAddressTypeInternal},
+				},
+			},
+			expected: IPStatus{
+				IngressIPs: []string{"203.0.113.1", "203.0.113.2", "2001:db8::1", "192.168.0.1", "192.168.0.2"},
+			},
+		},
+		{
+			name: "first per family from annotations",
+			annotations: map[string]string{
+				LabelIPMappings:           "ingress=external|internal",
+				LabelNodeAddressSelection: "first-per-family",
+			},
+			defaultNodeAddressSelection: NodeAddressSelectionAll,
+			expected: IPStatus{
+				IngressIPs: []string{"203.0.113.1", "2001:db8::1", "192.168.0.1"},
+			},
+		},
+		{
+			name:        "address sources by name",
+			annotations: map[string]string{LabelIPMappings: "ingress=annotation|external"},
+			expected: IPStatus{
+				IngressIPs: []string{"203.0.113.1", "203.0.113.2", "2001:db8::1", "198.51.100.1"},
+			},
+		},
+		{
+			name:        "fallback from addresses that are not IPs",
+			annotations: map[string]string{LabelIPMappings: "ingress=hostname|internal"},
+			expected: IPStatus{
+				IngressIPs: []string{"10.0.0.1", "fd00::1", "192.168.0.10"},
+			},
+		},
+		{
+			name:                      "duplicated mappings",
+			defaultExternalIPMappings: []IPMappingTarget{IPMappingTargetIngress},
+			defaultIPMappingPriorities: []IPMappingPriority{
+				{Target: IPMappingTargetIngress, Types: []NodeAddressType{NodeAddressTypeExternal}},
+			},
+			expected: IPStatus{
+				IngressIPs: []string{"203.0.113.1", "203.0.113.2", "2001:db8::1"},
+			},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			u := usecase{
+				defaultInternalIPMappings:   tc.defaultInternalIPMappings,
+				defaultExternalIPMappings:   tc.defaultExternalIPMappings,
+				defaultIPMappingPriorities:  tc.defaultIPMappingPriorities,
+				defaultNodeAddressSelection: tc.defaultNodeAddressSelection,
+			}
+			actual := u.mapIPs(nodeIPs, tc.annotations)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,322 @@
+package application
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func ParseNoNodePortsMode(s string) (NoNodePortsMode, error) {
+	switch m := NoNodePortsMode(strings.TrimSpace(s)); m {
+	case NoNodePortsModePublish, NoNodePortsModeExternalOnly, NoNodePortsModeSkip:
+		return m, nil
+	default:
+		return "", fmt.Errorf("invalid no node ports policy: %s", s)
+	}
+}
+
+func getNoNodePortsMode(
+	annotations map[string]string,
+	annotationName string,
+	defaultVal NoNodePortsMode,
+) NoNodePortsMode {
+	annotation, exists := annotations[annotationName]
+	if !exists {
+		return defaultVal
+	}
+
+	mode, err := ParseNoNodePortsMode(annotation)
+	if err != nil {
+		return defaultVal
+	}
+	return mode
+}
+
+// hasNodePorts reports whether node ports are allocated to svc, which is the default of LoadBalancer Services.
+func hasNodePorts(svc corev1.Service) bool {
+	return svc.Spec.AllocateLoadBalancerNodePorts == nil || *svc.Spec.AllocateLoadBalancerNodePorts
+}
+
+// applyNodePortsPolicy adjusts targetIPs of svc to whether its ports are reachable through node IPs, and returns the
+// condition that explains it. Traffic to ingress IPs reaches nodes through node ports, which svc may not allocate.
+// External IPs are routed on nodes by kube-proxy regardless, but on IPs of nodes their ports take over node ports of
+// other Services.
+func (u usecase) applyNodePortsPolicy(
+	svc corev1.Service,
+	nodeIPs NodeIPs,
+	targetIPs IPStatus,
+) (IPStatus, metav1.Condition) {
+	condition := metav1.Condition{
+		Type:    ConditionTypePortsReachable,
+		Status:  metav1.ConditionTrue,
+		Reason:  ConditionReasonReachable,
+		Message: "Every port is reachable through published IPs",
+	}
+
+	if svc.Spec.Type != corev1.ServiceTypeLoadBalancer {
+		u.useNodePorts(svc, IPStatus{})
+		return targetIPs, condition
+	}
+
+	if !hasNodePorts(svc) && len(targetIPs.IngressIPs) != 0 {
+		switch getNoNodePortsMode(svc.Annotations, LabelNoNodePortsPolicy, u.defaultNodePortsPolicy.NoNodePorts) {
+		case NoNodePortsModeExternalOnly:
+			targetIPs = IPStatus{ExternalIPs: uniqueStrings(append(targetIPs.ExternalIPs, targetIPs.IngressIPs...))}
+			condition.Reason = ConditionReasonPublishedAsExternalIPs
+			condition.Message = "Node ports are not allocated, ingress IPs are published as external IPs"
+
+		case NoNodePortsModeSkip:
+			targetIPs = IPStatus{ExternalIPs: targetIPs.ExternalIPs}
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = ConditionReasonNodePortsNotAllocated
+			condition.Message = "Node ports are not allocated, ingress IPs are not published"
+
+		default:
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = ConditionReasonNodePortsNotAllocated
+			condition.Message = "Node ports are not allocated, ingress IPs are reachable only if they are routed on nodes"
+		}
+	}
+
+	conflicts := u.useNodePorts(svc, targetIPs)
+	if len(conflicts) == 0 {
+		return targetIPs, condition
+	}
+
+	conflictMessage := "node ports of other Services: " + strings.Join(conflicts, ", ")
+	message := "ports of external IPs are " + conflictMessage
+	policy := u.defaultNodePortsPolicy.conflictPolicy()
+	if getPortConflictPolicy(svc.Annotations, LabelNodePortConflictPolicy, policy) == PortConflictPolicyExclude {
+		// node ports are opened only on IPs of nodes, so other external IPs do not take them over
+		if excluded := nodeIPsIn(nodeIPs, targetIPs.ExternalIPs); len(excluded) != 0 {
+			targetIPs.ExternalIPs = withoutIPs(targetIPs.ExternalIPs, excluded)
+			message += fmt.Sprintf(", external IPs of nodes are not published: %s", strings.Join(sortedKeys(excluded), ", "))
+		}
+	}
+
+	// the reason of node ports that are not allocated is kept, since it tells more about the published IPs
+	condition.Status = metav1.ConditionFalse
+	if condition.Reason == ConditionReasonReachable {
+		condition.Reason = ConditionReasonNodePortConflict
+		condition.Message = strings.ToUpper(message[:1]) + message[1:]
+	} else {
+		condition.Message += "; " + message
+	}
+	// the event is recorded once until the conflicting node ports change, not on every reconciliation
+	if last := meta.FindStatusCondition(svc.Status.Conditions, ConditionTypePortsReachable); last == nil ||
+		!strings.Contains(last.Message, conflictMessage) {
+		u.recordEvent(&svc, corev1.EventTypeWarning, EventReasonNodePortConflict, "%s", condition.Message)
+	}
+	return targetIPs, condition
+}
+
+// conflictPolicy is the PortConflictPolicy of node ports unless a Service overrides it.
+func (p NodePortsPolicy) conflictPolicy() PortConflictPolicy {
+	if p.RefuseConflicts {
+		return PortConflictPolicyExclude
+	}
+	return PortConflictPolicyWarn
+}
+
+// useNodePorts indexes ports of svc if targetIPs has external IPs, so that svc is assigned again when they start or
+// stop being node ports of other Services, and returns the ones that are, as "port/protocol (namespace/name)".
+func (u usecase) useNodePorts(svc corev1.Service, targetIPs IPStatus) []string {
+	if u.nodePorts == nil {
+		return nil
+	}
+
+	svcKey := types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name}
+	var keys []nodePortKey
+	if len(targetIPs.ExternalIPs) != 0 {
+		for _, port := range svc.Spec.Ports {
+			keys = append(keys, nodePortKey{port: port.Port, protocol: protocolOf(port)})
+		}
+	}
+	u.nodePorts.use(svcKey, keys)
+	return u.nodePorts.conflicts(svcKey, keys)
+}
+
+// nodeIPsIn returns ips that are IPs of any node of nodeIPs.
+func nodeIPsIn(nodeIPs NodeIPs, ips []string) map[string]struct{} {
+	all := make(map[string]struct{})
+	for _, node := range nodeIPs.Nodes {
+		for _, addresses := range node.Addresses {
+			for _, ip := range addresses {
+				all[ip] = struct{}{}
+			}
+		}
+	}
+
+	result := make(map[string]struct{})
+	for _, ip := range ips {
+		if _, exists := all[ip]; exists {
+			result[ip] = struct{}{}
+		}
+	}
+	return result
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	result := make([]string, 0, len(m))
+	for key := range m {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// nodePortKey is a node port, which kube-proxy opens on every IP of nodes, or a port of a Service.
+type nodePortKey struct {
+	port     int32
+	protocol corev1.Protocol
+}
+
+func (k nodePortKey) String() string {
+	return fmt.Sprintf("%d/%s", k.port, k.protocol)
+}
+
+// nodePortsOf returns node ports that svc allocates.
+func nodePortsOf(svc corev1.Service) []nodePortKey {
+	var keys []nodePortKey
+	for _, port := range svc.Spec.Ports {
+		if port.NodePort != 0 {
+			keys = append(keys, nodePortKey{port: port.NodePort, protocol: protocolOf(port)})
+		}
+	}
+	return keys
+}
+
+// nodePortIndex maps node ports to Services that allocate them, and ports to Services that publish them on external
+// IPs, so that conflicts are looked up without listing every Service, and Services are told when they change. Node
+// ports of every Service are indexed, whichever instance it is bound to.
+type nodePortIndex struct {
+	mu        sync.Mutex
+	owners    map[nodePortKey]map[types.NamespacedName]struct{}
+	ownedKeys map[types.NamespacedName][]nodePortKey
+	users     map[nodePortKey]map[types.NamespacedName]struct{}
+	usedKeys  map[types.NamespacedName][]nodePortKey
+}
+
+func newNodePortIndex() *nodePortIndex {
+	return &nodePortIndex{
+		owners:    map[nodePortKey]map[types.NamespacedName]struct{}{},
+		ownedKeys: map[types.NamespacedName][]nodePortKey{},
+		users:     map[nodePortKey]map[types.NamespacedName]struct{}{},
+		usedKeys:  map[types.NamespacedName][]nodePortKey{},
+	}
+}
+
+// allocate replaces node ports of the Service of svcKey with keys. It returns other Services that publish changed
+// node ports on external IPs, since their conflicts changed.
+func (i *nodePortIndex) allocate(svcKey types.NamespacedName, keys []nodePortKey) []types.NamespacedName {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	changed := symmetricDifference(i.ownedKeys[svcKey], keys)
+	i.replace(i.owners, i.ownedKeys, svcKey, keys)
+	return i.usersOf(svcKey, changed)
+}
+
+// use replaces ports that the Service of svcKey publishes on external IPs with keys.
+func (i *nodePortIndex) use(svcKey types.NamespacedName, keys []nodePortKey) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.replace(i.users, i.usedKeys, svcKey, keys)
+}
+
+// forget drops everything of the Service of svcKey, and returns other Services whose conflicts changed.
+func (i *nodePortIndex) forget(svcKey types.NamespacedName) []types.NamespacedName {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	released := i.ownedKeys[svcKey]
+	i.replace(i.owners, i.ownedKeys, svcKey, nil)
+	i.replace(i.users, i.usedKeys, svcKey, nil)
+	return i.usersOf(svcKey, released)
+}
+
+// conflicts returns keys that are node ports of Services other than svcKey, as "port/protocol (namespace/name)".
+func (i *nodePortIndex) conflicts(svcKey types.NamespacedName, keys []nodePortKey) []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	var result []string
+	for _, key := range keys {
+		for owner := range i.owners[key] {
+			if owner != svcKey {
+				result = append(result, fmt.Sprintf("%s (%s)", key, owner))
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (i *nodePortIndex) replace(
+	index map[nodePortKey]map[types.NamespacedName]struct{},
+	indexedKeys map[types.NamespacedName][]nodePortKey,
+	svcKey types.NamespacedName,
+	keys []nodePortKey,
+) {
+	for _, key := range indexedKeys[svcKey] {
+		delete(index[key], svcKey)
+		if len(index[key]) == 0 {
+			delete(index, key)
+		}
+	}
+	delete(indexedKeys, svcKey)
+
+	for _, key := range keys {
+		if index[key] == nil {
+			index[key] = map[types.NamespacedName]struct{}{}
+		}
+		index[key][svcKey] = struct{}{}
+	}
+	if len(keys) != 0 {
+		indexedKeys[svcKey] = keys
+	}
+}
+
+// usersOf returns Services other than svcKey that publish any of keys on external IPs, in order.
+func (i *nodePortIndex) usersOf(svcKey types.NamespacedName, keys []nodePortKey) []types.NamespacedName {
+	visited := map[types.NamespacedName]struct{}{svcKey: {}}
+	var result []types.NamespacedName
+	for _, key := range keys {
+		for user := range i.users[key] {
+			if _, exists := visited[user]; !exists {
+				visited[user] = struct{}{}
+				result = append(result, user)
+			}
+		}
+	}
+	sort.Slice(result, func(a, b int) bool { return result[a].String() < result[b].String() })
+	return result
+}
+
+// protocolOf returns the protocol of port, which defaults to TCP.
+func protocolOf(port corev1.ServicePort) corev1.Protocol {
+	if port.Protocol == "" {
+		return corev1.ProtocolTCP
+	}
+	return port.Protocol
+}
+
+func uniqueStrings(ss []string) []string {
+	result := make([]string, 0, len(ss))
+	visited := make(map[string]struct{}, len(ss))
+	for _, s := range ss {
+		if _, exists := visited[s]; exists {
+			continue
+		}
+		visited[s] = struct{}{}
+		result = append(result, s)
+	}
+	return result
+}
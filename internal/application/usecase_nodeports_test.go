@@ -0,0 +1,306 @@
+package application
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeServiceRepository struct {
+	services []corev1.Service
+}
+
+func (f fakeServiceRepository) Get(context.Context, types.NamespacedName) (corev1.Service, error) {
+	return corev1.Service{}, nil
+}
+
+func (f fakeServiceRepository) List(context.Context) ([]corev1.Service, error) {
+	return f.services, nil
+}
+
+func (f fakeServiceRepository) AssignIPs(context.Context, corev1.Service, IPStatus, []metav1.Condition) error {
+	return nil
+}
+
+type fakeEventRecorder struct {
+	events []string
+}
+
+func (f *fakeEventRecorder) Eventf(_ runtime.Object, _, reason, _ string, _ ...interface{}) {
+	f.events = append(f.events, reason)
+}
+
+func TestUsecase_applyNodePortsPolicy(t *testing.T) {
+	t.Parallel()
+
+	noNodePorts := false
+	newSvc := func(annotations map[string]string, allocateNodePorts *bool) corev1.Service {
+		return corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", Annotations: annotations},
+			Spec: corev1.ServiceSpec{
+				Type:                          corev1.ServiceTypeLoadBalancer,
+				AllocateLoadBalancerNodePorts: allocateNodePorts,
+				Ports:                         []corev1.ServicePort{{Port: 30080}, {Port: 53, Protocol: corev1.ProtocolUDP}},
+			},
+		}
+	}
+	other := corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "app"},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{
+			{Port: 80, NodePort: 30080, Protocol: corev1.ProtocolTCP},
+			{Port: 53, NodePort: 30053, Protocol: corev1.ProtocolUDP},
+		}},
+	}
+	self := newSvc(nil, nil)
+	self.Spec.Ports[0].NodePort = 30080
+	nodeIPs := NodeIPs{Nodes: []NodeAddresses{{
+		Name:      "node-a",
+		Addresses: map[NodeAddressType][]string{NodeAddressTypeInternal: {"10.0.0.1", "10.0.0.2"}},
+	}}}
+	// 203.0.113.1 is not an IP of a node, e.g. translated by NAT rules
+	targetIPs := IPStatus{IngressIPs: []string{"10.0.0.1", "10.0.0.2"}, ExternalIPs: []string{"10.0.0.2", "203.0.113.1"}}
+
+	tests := []struct {
+		name             string
+		svc              corev1.Service
+		services         []corev1.Service
+		policy           NodePortsPolicy
+		expected         IPStatus
+		expectedStatus   metav1.ConditionStatus
+		expectedReason   string
+		expectedMessages []string
+		expectedEventNum int
+	}{
+		{
+			name:           "node ports allocated",
+			svc:            newSvc(nil, nil),
+			services:       []corev1.Service{self},
+			policy:         NodePortsPolicy{NoNodePorts: NoNodePortsModeSkip},
+			expected:       targetIPs,
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: ConditionReasonReachable,
+		},
+		{
+			name:           "no node ports, publish",
+			svc:            newSvc(nil, &noNodePorts),
+			policy:         NodePortsPolicy{NoNodePorts: NoNodePortsModePublish},
+			expected:       targetIPs,
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: ConditionReasonNodePortsNotAllocated,
+		},
+		{
+			name:           "no node ports, external only",
+			svc:            newSvc(nil, &noNodePorts),
+			policy:         NodePortsPolicy{NoNodePorts: NoNodePortsModeExternalOnly},
+			expected:       IPStatus{ExternalIPs: []string{"10.0.0.2", "203.0.113.1", "10.0.0.1"}},
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: ConditionReasonPublishedAsExternalIPs,
+		},
+		{
+			name:           "no node ports, skip by annotation",
+			svc:            newSvc(map[string]string{LabelNoNodePortsPolicy: "skip"}, &noNodePorts),
+			policy:         NodePortsPolicy{NoNodePorts: NoNodePortsModePublish},
+			expected:       IPStatus{ExternalIPs: targetIPs.ExternalIPs},
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: ConditionReasonNodePortsNotAllocated,
+		},
+		{
+			name:             "node port conflict warns",
+			svc:              newSvc(nil, nil),
+			services:         []corev1.Service{other},
+			expected:         targetIPs,
+			expectedStatus:   metav1.ConditionFalse,
+			expectedReason:   ConditionReasonNodePortConflict,
+			expectedMessages: []string{"30080/TCP (other/app)"},
+			expectedEventNum: 1,
+		},
+		{
+			name:             "node port conflict refused drops only external IPs of nodes",
+			svc:              newSvc(nil, nil),
+			services:         []corev1.Service{other},
+			policy:           NodePortsPolicy{RefuseConflicts: true},
+			expected:         IPStatus{IngressIPs: targetIPs.IngressIPs, ExternalIPs: []string{"203.0.113.1"}},
+			expectedStatus:   metav1.ConditionFalse,
+			expectedReason:   ConditionReasonNodePortConflict,
+			expectedMessages: []string{"30080/TCP (other/app)", "not published: 10.0.0.2"},
+			expectedEventNum: 1,
+		},
+		{
+			name:             "node port conflict excluded by annotation",
+			svc:              newSvc(map[string]string{LabelNodePortConflictPolicy: "exclude"}, nil),
+			services:         []corev1.Service{other},
+			expected:         IPStatus{IngressIPs: targetIPs.IngressIPs, ExternalIPs: []string{"203.0.113.1"}},
+			expectedStatus:   metav1.ConditionFalse,
+			expectedReason:   ConditionReasonNodePortConflict,
+			expectedEventNum: 1,
+		},
+		{
+			name:             "node port conflict warned by annotation",
+			svc:              newSvc(map[string]string{LabelNodePortConflictPolicy: "warn"}, nil),
+			services:         []corev1.Service{other},
+			policy:           NodePortsPolicy{RefuseConflicts: true},
+			expected:         targetIPs,
+			expectedStatus:   metav1.ConditionFalse,
+			expectedReason:   ConditionReasonNodePortConflict,
+			expectedEventNum: 1,
+		},
+		{
+			name:             "invalid annotation falls back to the flag",
+			svc:              newSvc(map[string]string{LabelNodePortConflictPolicy: "ignore"}, nil),
+			services:         []corev1.Service{other},
+			policy:           NodePortsPolicy{RefuseConflicts: true},
+			expected:         IPStatus{IngressIPs: targetIPs.IngressIPs, ExternalIPs: []string{"203.0.113.1"}},
+			expectedStatus:   metav1.ConditionFalse,
+			expectedReason:   ConditionReasonNodePortConflict,
+			expectedEventNum: 1,
+		},
+		{
+			name:     "node port conflict keeps the reason of external only",
+			svc:      newSvc(nil, &noNodePorts),
+			services: []corev1.Service{other},
+			policy:   NodePortsPolicy{NoNodePorts: NoNodePortsModeExternalOnly, RefuseConflicts: true},
+			expected: IPStatus{ExternalIPs: []string{"203.0.113.1"}},
+			// the ingress IPs that are moved to external IPs are IPs of nodes as well
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: ConditionReasonPublishedAsExternalIPs,
+			expectedMessages: []string{
+				"ingress IPs are published as external IPs; ports of external IPs are node ports",
+				"not published: 10.0.0.1, 10.0.0.2",
+			},
+			expectedEventNum: 1,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := &fakeEventRecorder{}
+			u := usecase{
+				eventRecorder:          recorder,
+				defaultNodePortsPolicy: tc.policy,
+				nodePorts:              newNodePortIndex(),
+			}
+			for _, svc := range tc.services {
+				u.ObserveService(svc)
+			}
+
+			actual, condition := u.applyNodePortsPolicy(tc.svc, nodeIPs, targetIPs)
+			assert.Equal(t, tc.expected, actual)
+			assert.Equal(t, ConditionTypePortsReachable, condition.Type)
+			assert.Equal(t, tc.expectedStatus, condition.Status)
+			assert.Equal(t, tc.expectedReason, condition.Reason)
+			for _, message := range tc.expectedMessages {
+				assert.Contains(t, condition.Message, message)
+			}
+			assert.Len(t, recorder.events, tc.expectedEventNum)
+		})
+	}
+}
+
+func TestUsecase_applyNodePortsPolicy_eventOnce(t *testing.T) {
+	t.Parallel()
+
+	svc := corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec: corev1.ServiceSpec{
+			Type:  corev1.ServiceTypeLoadBalancer,
+			Ports: []corev1.ServicePort{{Port: 30080}, {Port: 30081}},
+		},
+	}
+	targetIPs := IPStatus{ExternalIPs: []string{"10.0.0.1"}}
+	recorder := &fakeEventRecorder{}
+	u := usecase{eventRecorder: recorder, nodePorts: newNodePortIndex()}
+	u.ObserveService(corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "a"},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{NodePort: 30080}}},
+	})
+
+	_, condition := u.applyNodePortsPolicy(svc, NodeIPs{}, targetIPs)
+	svc.Status.Conditions = []metav1.Condition{condition}
+	_, _ = u.applyNodePortsPolicy(svc, NodeIPs{}, targetIPs)
+	assert.Len(t, recorder.events, 1, "the same conflict is recorded once")
+
+	u.ObserveService(corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "b"},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{NodePort: 30081}}},
+	})
+	_, _ = u.applyNodePortsPolicy(svc, NodeIPs{}, targetIPs)
+	assert.Len(t, recorder.events, 2, "a new conflict is recorded")
+}
+
+func TestNodePortIndex(t *testing.T) {
+	t.Parallel()
+
+	web := types.NamespacedName{Namespace: "default", Name: "web"}
+	tcp := types.NamespacedName{Namespace: "b", Name: "tcp"}
+	udp := types.NamespacedName{Namespace: "a", Name: "udp"}
+	ports := []nodePortKey{{port: 30080, protocol: corev1.ProtocolTCP}, {port: 30053, protocol: corev1.ProtocolTCP}}
+
+	i := newNodePortIndex()
+	i.use(web, ports)
+	assert.Equal(
+		t,
+		[]types.NamespacedName{web},
+		i.allocate(tcp, []nodePortKey{{port: 30080, protocol: corev1.ProtocolTCP}}),
+	)
+	assert.Empty(t, i.allocate(udp, []nodePortKey{{port: 30053, protocol: corev1.ProtocolUDP}}), "protocols differ")
+	assert.Equal(t, []string{"30080/TCP (b/tcp)"}, i.conflicts(web, ports))
+	assert.Empty(t, i.conflicts(tcp, []nodePortKey{{port: 30080, protocol: corev1.ProtocolTCP}}), "its own node port")
+
+	assert.Empty(t, i.allocate(tcp, []nodePortKey{{port: 30080, protocol: corev1.ProtocolTCP}}), "nothing changed")
+	assert.Equal(t, []types.NamespacedName{web}, i.forget(tcp))
+	assert.Empty(t, i.conflicts(web, ports))
+
+	i.use(web, nil)
+	assert.Empty(t, i.allocate(tcp, []nodePortKey{{port: 30080, protocol: corev1.ProtocolTCP}}), "no longer used")
+}
+
+func TestUsecase_ObserveService_nodePorts(t *testing.T) {
+	t.Parallel()
+
+	svc := corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec: corev1.ServiceSpec{
+			Type:  corev1.ServiceTypeLoadBalancer,
+			Ports: []corev1.ServicePort{{Port: 30080}},
+		},
+	}
+	svcKey := types.NamespacedName{Namespace: "default", Name: "web"}
+	other := corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "app", Annotations: map[string]string{
+			LabelInstance: "another",
+		}},
+		Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeNodePort, Ports: []corev1.ServicePort{{NodePort: 30081}}},
+	}
+	targetIPs := IPStatus{ExternalIPs: []string{"10.0.0.1"}}
+
+	var changed []types.NamespacedName
+	u := usecase{
+		eventRecorder:     &fakeEventRecorder{},
+		nodePorts:         newNodePortIndex(),
+		portClaimsChanged: func(svcKey types.NamespacedName) { changed = append(changed, svcKey) },
+	}
+	u.ObserveService(other)
+	_, condition := u.applyNodePortsPolicy(svc, NodeIPs{}, targetIPs)
+	assert.Equal(t, ConditionReasonReachable, condition.Reason)
+
+	// a Service of any instance takes over the port by changing its node port
+	other.Spec.Ports[0].NodePort = 30080
+	u.ObserveService(other)
+	assert.Equal(t, []types.NamespacedName{svcKey}, changed, "the conflicting Service is assigned again")
+	_, condition = u.applyNodePortsPolicy(svc, NodeIPs{}, targetIPs)
+	assert.Equal(t, ConditionReasonNodePortConflict, condition.Reason)
+
+	changed = nil
+	u.ObserveServiceDeletion(types.NamespacedName{Namespace: "other", Name: "app"})
+	assert.Equal(t, []types.NamespacedName{svcKey}, changed, "the conflict is resolved")
+	_, condition = u.applyNodePortsPolicy(svc, NodeIPs{}, targetIPs)
+	assert.Equal(t, ConditionReasonReachable, condition.Reason)
+}
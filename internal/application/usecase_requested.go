@@ -0,0 +1,19 @@
+package application
+
+import (
+	"github.com/isac322/static-lb/pkg/staticlb"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// unavailableRequestedIPs returns requested IPs of svc that are not in targetIPs.
+func unavailableRequestedIPs(svc corev1.Service, targetIPs IPStatus) []string {
+	requested := staticlb.RequestedIPsOf(svc)
+	if len(requested) == 0 {
+		return nil
+	}
+
+	published := append(append([]string{}, targetIPs.IngressIPs...), targetIPs.ExternalIPs...)
+	_, unavailable := intersectIPs(requested, published)
+	return unavailable
+}
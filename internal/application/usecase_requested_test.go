@@ -0,0 +1,46 @@
+package application
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnavailableRequestedIPs(t *testing.T) {
+	t.Parallel()
+
+	targetIPs := IPStatus{IngressIPs: []string{"10.0.0.1"}, ExternalIPs: []string{"10.0.0.2"}}
+
+	tests := []struct {
+		name     string
+		svc      corev1.Service
+		expected []string
+	}{
+		{
+			name: "nothing requested",
+		},
+		{
+			name: "loadBalancerIP is published",
+			svc:  corev1.Service{Spec: corev1.ServiceSpec{LoadBalancerIP: "10.0.0.2"}},
+		},
+		{
+			name: "annotation over loadBalancerIP",
+			svc: corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{LabelRequestedIPs: "10.0.0.1, 10.0.0.3"}},
+				Spec:       corev1.ServiceSpec{LoadBalancerIP: "10.0.0.2"},
+			},
+			expected: []string{"10.0.0.3"},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, unavailableRequestedIPs(tc.svc, targetIPs))
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package application
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/isac322/static-lb/pkg/staticlb"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// kubernetesServiceKey is the Service of the API server, whose ClusterIP is the first IP of the Service CIDR.
+var kubernetesServiceKey = types.NamespacedName{Namespace: metav1.NamespaceDefault, Name: "kubernetes"}
+
+// reservedIPNetCache keeps reservedIPNets until nodes or the kubernetes Service change.
+type reservedIPNetCache struct {
+	mu     sync.Mutex
+	ipNets []*net.IPNet
+	valid  bool
+	// generation increases on every invalidation, so that networks read before it are not stored after it.
+	generation uint64
+}
+
+func newReservedIPNetCache() *reservedIPNetCache {
+	return &reservedIPNetCache{}
+}
+
+// get returns the cached networks if they are valid, and the generation to store new ones with.
+func (c *reservedIPNetCache) get() (ipNets []*net.IPNet, valid bool, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ipNets, c.valid, c.generation
+}
+
+// set stores ipNets unless the cache is invalidated since generation.
+func (c *reservedIPNetCache) set(generation uint64, ipNets []*net.IPNet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation != generation {
+		return
+	}
+	c.ipNets = ipNets
+	c.valid = true
+}
+
+func (c *reservedIPNetCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ipNets = nil
+	c.valid = false
+	c.generation++
+}
+
+func (u usecase) InvalidateReservedIPNets() {
+	if u.reserved != nil {
+		u.reserved.invalidate()
+	}
+}
+
+// reservedIPNets returns networks internal to the cluster that are excluded from IPs before any filter, or nil if
+// the exclusion is disabled. They are read again only after InvalidateReservedIPNets.
+func (u usecase) reservedIPNets(ctx context.Context) ([]*net.IPNet, error) {
+	if !u.clusterNetworks.Exclude {
+		return nil, nil
+	}
+	if u.reserved == nil {
+		return u.discoverReservedIPNets(ctx)
+	}
+
+	ipNets, valid, generation := u.reserved.get()
+	if valid {
+		return ipNets, nil
+	}
+	ipNets, err := u.discoverReservedIPNets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	u.reserved.set(generation, ipNets)
+	return ipNets, nil
+}
+
+func (u usecase) discoverReservedIPNets(ctx context.Context) ([]*net.IPNet, error) {
+	nodes, err := u.nodeRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceCIDRs := u.clusterNetworks.ServiceCIDRs
+	if len(serviceCIDRs) == 0 && u.serviceRepo != nil {
+		svc, err := u.serviceRepo.Get(ctx, kubernetesServiceKey)
+		switch {
+		case apierrors.IsNotFound(err):
+		case err != nil:
+			return nil, err
+		default:
+			serviceCIDRs = clusterIPNetsOf(svc)
+		}
+	}
+
+	return staticlb.ClusterIPNets(nodes, serviceCIDRs), nil
+}
+
+// clusterIPNetsOf returns ClusterIPs of svc as networks of single IPs. Only the ClusterIPs of the kubernetes Service
+// are known without --service-cidr, since its prefix length differs between distributions.
+func clusterIPNetsOf(svc corev1.Service) []*net.IPNet {
+	clusterIPs := svc.Spec.ClusterIPs
+	if len(clusterIPs) == 0 && svc.Spec.ClusterIP != "" {
+		clusterIPs = []string{svc.Spec.ClusterIP}
+	}
+
+	var result []*net.IPNet
+	for _, clusterIP := range clusterIPs {
+		ip := net.ParseIP(clusterIP)
+		if ip == nil {
+			continue
+		}
+		mask := net.CIDRMask(8*net.IPv6len, 8*net.IPv6len)
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+			mask = net.CIDRMask(8*net.IPv4len, 8*net.IPv4len)
+		}
+		result = append(result, &net.IPNet{IP: ip, Mask: mask})
+	}
+	return result
+}
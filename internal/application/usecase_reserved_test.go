@@ -0,0 +1,118 @@
+package application
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterIPNetsOf(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		spec     corev1.ServiceSpec
+		expected []string
+	}{
+		{
+			name:     "single stack",
+			spec:     corev1.ServiceSpec{ClusterIP: "10.96.0.1"},
+			expected: []string{"10.96.0.1/32"},
+		},
+		{
+			name:     "dual stack",
+			spec:     corev1.ServiceSpec{ClusterIP: "10.96.0.1", ClusterIPs: []string{"10.96.0.1", "fd00:10:96::1"}},
+			expected: []string{"10.96.0.1/32", "fd00:10:96::1/128"},
+		},
+		{
+			name: "no ClusterIP",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var actual []string
+			for _, ipNet := range clusterIPNetsOf(corev1.Service{Spec: tc.spec}) {
+				actual = append(actual, ipNet.String())
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestUsecase_reservedIPNets(t *testing.T) {
+	t.Parallel()
+
+	_, serviceCIDR, _ := net.ParseCIDR("10.96.0.0/16")
+	nodeRepo := fakeNodeRepository{nodes: map[string]corev1.Node{
+		"a": {
+			ObjectMeta: metav1.ObjectMeta{Name: "a"},
+			Spec:       corev1.NodeSpec{PodCIDRs: []string{"10.244.0.0/24"}},
+		},
+	}}
+
+	u := usecase{nodeRepo: nodeRepo, serviceRepo: fakeServiceRepository{}}
+	reserved, err := u.reservedIPNets(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, reserved, "disabled")
+
+	u.clusterNetworks = ClusterNetworks{Exclude: true, ServiceCIDRs: []*net.IPNet{serviceCIDR}}
+	reserved, err = u.reservedIPNets(context.Background())
+	require.NoError(t, err)
+	actual := u.filterTargetIPs(
+		IPStatus{IngressIPs: []string{"10.244.0.10", "10.96.0.1", "10.0.0.1"}, ExternalIPs: []string{"127.0.0.1"}},
+		nil,
+		reserved,
+	)
+	assert.Equal(t, IPStatus{IngressIPs: []string{"10.0.0.1"}}, actual)
+}
+
+func TestUsecase_reservedIPNets_cache(t *testing.T) {
+	t.Parallel()
+
+	nodeRepo := fakeNodeRepository{nodes: map[string]corev1.Node{
+		"a": {
+			ObjectMeta: metav1.ObjectMeta{Name: "a"},
+			Spec:       corev1.NodeSpec{PodCIDRs: []string{"10.244.0.0/24"}},
+		},
+	}}
+	u := usecase{
+		nodeRepo:        nodeRepo,
+		serviceRepo:     fakeServiceRepository{},
+		clusterNetworks: ClusterNetworks{Exclude: true},
+		reserved:        newReservedIPNetCache(),
+	}
+	contains := func(ipNets []*net.IPNet, ip string) bool {
+		for _, ipNet := range ipNets {
+			if ipNet.Contains(net.ParseIP(ip)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	reserved, err := u.reservedIPNets(context.Background())
+	require.NoError(t, err)
+	assert.True(t, contains(reserved, "10.244.0.10"))
+
+	nodeRepo.nodes["b"] = corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "b"},
+		Spec:       corev1.NodeSpec{PodCIDRs: []string{"10.244.1.0/24"}},
+	}
+	reserved, err = u.reservedIPNets(context.Background())
+	require.NoError(t, err)
+	assert.False(t, contains(reserved, "10.244.1.10"), "nodes are not listed again")
+
+	u.InvalidateReservedIPNets()
+	reserved, err = u.reservedIPNets(context.Background())
+	require.NoError(t, err)
+	assert.True(t, contains(reserved, "10.244.1.10"))
+}
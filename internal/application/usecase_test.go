@@ -0,0 +1,327 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingHook struct {
+	changes []IPChange
+	err     error
+}
+
+func (h *recordingHook) IPsAssigned(_ context.Context, change IPChange) error {
+	h.changes = append(h.changes, change)
+	return h.err
+}
+
+func TestUsecase_notifyAssignment(t *testing.T) {
+	t.Parallel()
+
+	svc := corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "svc"},
+		Spec:       corev1.ServiceSpec{ExternalIPs: []string{"10.0.0.1"}},
+	}
+	target := IPStatus{ExternalIPs: []string{"10.0.0.2"}}
+	conditions := []metav1.Condition{{Type: ConditionTypeIPsAssigned, Reason: ConditionReasonAssigned}}
+
+	tests := []struct {
+		name           string
+		drifted        bool
+		expectedReason string
+	}{
+		{
+			name:           "assigned",
+			expectedReason: ConditionReasonAssigned,
+		},
+		{
+			name:           "drift corrected",
+			drifted:        true,
+			expectedReason: EventReasonDriftCorrected,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			failing := &recordingHook{err: errors.New("unavailable")}
+			hook := &recordingHook{}
+			u := usecase{assignmentHooks: []AssignmentHook{failing, hook}}
+
+			u.notifyAssignment(context.Background(), svc, target, conditions, tc.drifted)
+
+			expected := []IPChange{{
+				Service: types.NamespacedName{Namespace: "default", Name: "svc"},
+				Old:     IPStatus{IngressIPs: []string{}, ExternalIPs: []string{"10.0.0.1"}},
+				New:     target,
+				Reason:  tc.expectedReason,
+			}}
+			assert.Equal(t, expected, failing.changes)
+			assert.Equal(t, expected, hook.changes)
+		})
+	}
+}
+
+func TestUsecase_ResolveIPs(t *testing.T) {
+	t.Parallel()
+
+	u := usecase{
+		endpointSliceRepo: fakeEndpointSliceRepository{slices: discoveryv1.EndpointSliceList{
+			Items: []discoveryv1.EndpointSlice{newFakeEndpointSlice("node-a", "node-b")},
+		}},
+		nodeRepo: fakeNodeRepository{nodes: map[string]corev1.Node{
+			"node-a": newFakeNode("node-a", "192.168.0.1"),
+			"node-b": newFakeNode("node-b", "192.168.0.2"),
+		}},
+		eventRecorder:             &fakeEventRecorder{},
+		defaultInternalIPMappings: []IPMappingTarget{IPMappingTargetIngress},
+		instanceName:              "x",
+	}
+	newService := func(policy corev1.ServiceExternalTrafficPolicyType, annotations map[string]string) corev1.Service {
+		return corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "svc", Annotations: annotations},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer, ExternalTrafficPolicy: policy},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		svc      corev1.Service
+		expected IPStatus
+	}{
+		{
+			name: "bound",
+			svc: newService(corev1.ServiceExternalTrafficPolicyTypeLocal, map[string]string{
+				LabelInstance: "x",
+			}),
+			expected: IPStatus{IngressIPs: []string{"192.168.0.1", "192.168.0.2"}},
+		},
+		{
+			name:     "not bound",
+			svc:      newService(corev1.ServiceExternalTrafficPolicyTypeLocal, nil),
+			expected: IPStatus{},
+		},
+		{
+			name: "annotations of the instance",
+			svc: newService(corev1.ServiceExternalTrafficPolicyTypeLocal, map[string]string{
+				LabelInstance: "x",
+				"x." + annotationDomain + "/requested-ips": "192.168.0.2",
+				LabelExcludeIngressIPNets:                  "192.168.0.0/24",
+			}),
+			expected: IPStatus{IngressIPs: []string{"192.168.0.2"}},
+		},
+		{
+			name:     "unknown external traffic policy",
+			svc:      newService("", map[string]string{LabelInstance: "x"}),
+			expected: IPStatus{},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := u.ResolveIPs(context.Background(), tc.svc)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+// recordingServiceRepository records what is written by AssignIPs.
+type recordingServiceRepository struct {
+	fakeServiceRepository
+	ips        IPStatus
+	conditions []metav1.Condition
+	err        error
+}
+
+func (r *recordingServiceRepository) AssignIPs(
+	_ context.Context,
+	_ corev1.Service,
+	ips IPStatus,
+	conditions []metav1.Condition,
+) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.ips = ips
+	r.conditions = conditions
+	return nil
+}
+
+func TestUsecase_AssignIPs(t *testing.T) {
+	t.Parallel()
+
+	noNodePorts := false
+	newService := func(annotations map[string]string) corev1.Service {
+		return corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "svc", Annotations: annotations},
+			Spec: corev1.ServiceSpec{
+				Type:                  corev1.ServiceTypeLoadBalancer,
+				ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyTypeLocal,
+			},
+			Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{{IP: "192.168.0.9"}},
+			}},
+		}
+	}
+	withoutNodePorts := newService(map[string]string{LabelNoNodePortsPolicy: string(NoNodePortsModeSkip)})
+	withoutNodePorts.Spec.AllocateLoadBalancerNodePorts = &noNodePorts
+
+	tests := []struct {
+		name           string
+		svc            corev1.Service
+		nodeNames      []string
+		expected       IPStatus
+		expectedReason string
+	}{
+		{
+			name:           "assigned",
+			svc:            newService(nil),
+			nodeNames:      []string{"node-a", "node-b"},
+			expected:       IPStatus{IngressIPs: []string{"192.168.0.1", "192.168.0.2"}},
+			expectedReason: ConditionReasonAssigned,
+		},
+		{
+			name:           "candidates vanished",
+			svc:            newService(nil),
+			expected:       IPStatus{IngressIPs: []string{"192.168.0.9"}},
+			expectedReason: ConditionReasonStaleAddresses,
+		},
+		{
+			name:           "requested IP is not eligible",
+			svc:            newService(map[string]string{LabelRequestedIPs: "192.168.0.3"}),
+			nodeNames:      []string{"node-a", "node-b"},
+			expected:       IPStatus{},
+			expectedReason: ConditionReasonRequestedIPUnavailable,
+		},
+		{
+			name:           "ingress IPs are skipped without node ports",
+			svc:            withoutNodePorts,
+			nodeNames:      []string{"node-a", "node-b"},
+			expected:       IPStatus{},
+			expectedReason: ConditionReasonFilteredToEmpty,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			serviceRepo := &recordingServiceRepository{}
+			u := New(Options{
+				EndpointSlices: fakeEndpointSliceRepository{slices: discoveryv1.EndpointSliceList{
+					Items: []discoveryv1.EndpointSlice{newFakeEndpointSlice(tc.nodeNames...)},
+				}},
+				Nodes: fakeNodeRepository{nodes: map[string]corev1.Node{
+					"node-a": newFakeNode("node-a", "192.168.0.1"),
+					"node-b": newFakeNode("node-b", "192.168.0.2"),
+				}},
+				Services:           serviceRepo,
+				EventRecorder:      &fakeEventRecorder{},
+				InternalIPMappings: []IPMappingTarget{IPMappingTargetIngress},
+				EmptyIPsPolicy:     EmptyIPsPolicy{Mode: EmptyIPsModeKeepLast},
+			})
+
+			_, err := u.AssignIPs(context.Background(), tc.svc)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, serviceRepo.ips)
+			ipsAssigned := meta.FindStatusCondition(serviceRepo.conditions, ConditionTypeIPsAssigned)
+			if assert.NotNil(t, ipsAssigned) {
+				assert.Equal(t, tc.expectedReason, ipsAssigned.Reason)
+			}
+		})
+	}
+}
+
+func TestUsecase_AssignIPs_addressesCleared(t *testing.T) {
+	t.Parallel()
+
+	svc := corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "svc"},
+		Spec: corev1.ServiceSpec{
+			Type:                  corev1.ServiceTypeLoadBalancer,
+			ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyTypeLocal,
+		},
+		Status: corev1.ServiceStatus{
+			LoadBalancer: corev1.LoadBalancerStatus{Ingress: []corev1.LoadBalancerIngress{{IP: "192.168.0.9"}}},
+			Conditions: []metav1.Condition{{
+				Type:    ConditionTypeIPsAssigned,
+				Status:  metav1.ConditionFalse,
+				Reason:  ConditionReasonStaleAddresses,
+				Message: staleAddressesMessage(time.Now().Add(-2 * time.Hour)),
+			}},
+		},
+	}
+	serviceRepo := &recordingServiceRepository{err: errors.New("conflict")}
+	recorder := &fakeEventRecorder{}
+	u := New(Options{
+		EndpointSlices:     fakeEndpointSliceRepository{},
+		Nodes:              fakeNodeRepository{},
+		Services:           serviceRepo,
+		EventRecorder:      recorder,
+		InternalIPMappings: []IPMappingTarget{IPMappingTargetIngress},
+		EmptyIPsPolicy:     EmptyIPsPolicy{Mode: EmptyIPsModeHoldFor, HoldFor: time.Hour},
+	})
+
+	_, err := u.AssignIPs(context.Background(), svc)
+	assert.Error(t, err)
+	assert.Empty(t, recorder.events, "IPs are not cleared yet")
+
+	serviceRepo.err = nil
+	_, err = u.AssignIPs(context.Background(), svc)
+	assert.NoError(t, err)
+	assert.Equal(t, IPStatus{}, serviceRepo.ips)
+	assert.Equal(t, []string{EventReasonAddressesCleared}, recorder.events)
+}
+
+func TestUsecase_isSynced(t *testing.T) {
+	t.Parallel()
+
+	svc := corev1.Service{
+		Spec: corev1.ServiceSpec{ExternalIPs: []string{"10.0.0.1", "10.0.0.2"}},
+		Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{
+			Ingress: []corev1.LoadBalancerIngress{{IP: "10.0.0.3"}},
+		}},
+	}
+
+	tests := []struct {
+		name      string
+		targetIPs IPStatus
+		expected  bool
+	}{
+		{
+			name:      "same",
+			targetIPs: IPStatus{IngressIPs: []string{"10.0.0.3"}, ExternalIPs: []string{"10.0.0.1", "10.0.0.2"}},
+			expected:  true,
+		},
+		{
+			name:      "reordered",
+			targetIPs: IPStatus{IngressIPs: []string{"10.0.0.3"}, ExternalIPs: []string{"10.0.0.2", "10.0.0.1"}},
+		},
+		{
+			name:      "changed",
+			targetIPs: IPStatus{ExternalIPs: []string{"10.0.0.1", "10.0.0.2"}},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, usecase{}.isSynced(svc, tc.targetIPs))
+		})
+	}
+}
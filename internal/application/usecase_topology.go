@@ -0,0 +1,10 @@
+package application
+
+import (
+	"github.com/isac322/static-lb/pkg/staticlb"
+)
+
+func (u usecase) arrangeByTopology(targetIPs IPStatus, nodeIPs NodeIPs, annotations map[string]string) IPStatus {
+	targetIPs, _ = staticlb.ArrangeByTopology(targetIPs, nodeIPs, annotations, u.pipelineConfig())
+	return targetIPs
+}
@@ -0,0 +1,10 @@
+package application
+
+import (
+	"github.com/isac322/static-lb/pkg/staticlb"
+)
+
+func (u usecase) translateIPs(targetIPs IPStatus, annotations map[string]string) IPStatus {
+	targetIPs, _ = staticlb.TranslateIPs(targetIPs, annotations, u.pipelineConfig())
+	return targetIPs
+}
@@ -0,0 +1,88 @@
+package application
+
+import (
+	"testing"
+
+	"github.com/isac322/static-lb/pkg/staticlb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsecase_translateIPs(t *testing.T) {
+	t.Parallel()
+
+	mustParse := func(ss ...string) []NATRule {
+		rules := make([]NATRule, 0, len(ss))
+		for _, s := range ss {
+			rule, err := staticlb.ParseNATRule(s)
+			if err != nil {
+				panic(err)
+			}
+			rules = append(rules, rule)
+		}
+		return rules
+	}
+
+	tests := []struct {
+		name             string
+		targetIPs        IPStatus
+		annotations      map[string]string
+		defaultRules     []NATRule
+		dropUntranslated bool
+		expected         IPStatus
+	}{
+		{
+			name:      "no rules",
+			targetIPs: IPStatus{IngressIPs: []string{"10.0.1.5"}},
+			expected:  IPStatus{IngressIPs: []string{"10.0.1.5"}},
+		},
+		{
+			name: "prefix translation keeps host bits",
+			targetIPs: IPStatus{
+				IngressIPs:  []string{"10.0.1.5", "10.0.2.5", "fd00:1::abcd"},
+				ExternalIPs: []string{"10.0.1.200"},
+			},
+			defaultRules: mustParse("10.0.1.0/24=203.0.113.0/24", "fd00:1::/64=2001:db8:1::/64"),
+			expected: IPStatus{
+				IngressIPs:  []string{"203.0.113.5", "10.0.2.5", "2001:db8:1::abcd"},
+				ExternalIPs: []string{"203.0.113.200"},
+			},
+		},
+		{
+			name:             "drop untranslated",
+			targetIPs:        IPStatus{IngressIPs: []string{"10.0.1.5", "10.0.2.5"}},
+			defaultRules:     mustParse("10.0.1.0/24=203.0.113.0/24"),
+			dropUntranslated: true,
+			expected:         IPStatus{IngressIPs: []string{"203.0.113.5"}},
+		},
+		{
+			name:         "most specific rule wins",
+			targetIPs:    IPStatus{IngressIPs: []string{"10.0.1.5", "10.0.1.6"}},
+			defaultRules: mustParse("10.0.1.0/24=203.0.113.0/24", "10.0.1.5=198.51.100.1"),
+			expected:     IPStatus{IngressIPs: []string{"198.51.100.1", "203.0.113.6"}},
+		},
+		{
+			name:      "annotation overrides default",
+			targetIPs: IPStatus{IngressIPs: []string{"10.0.1.5"}},
+			annotations: map[string]string{
+				LabelNATRules:            "10.0.0.0/16=192.168.0.0/16",
+				LabelDropUntranslatedIPs: "true",
+			},
+			defaultRules: mustParse("10.0.1.0/24=203.0.113.0/24"),
+			expected:     IPStatus{IngressIPs: []string{"192.168.1.5"}},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			u := usecase{
+				defaultNATRules:            tc.defaultRules,
+				defaultDropUntranslatedIPs: tc.dropUntranslated,
+			}
+			actual := u.translateIPs(tc.targetIPs, tc.annotations)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package infrastructure
+
+import (
+	"strings"
+
+	"github.com/isac322/static-lb/pkg/staticlb"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SetTrimmedCacheOptions sets cache options of Nodes, Services and EndpointSlices into byObject, so that the
+// informer cache holds only fields static-lb reads. EndpointSlices that do not belong to any Service, or belong to
+// headless Services, are not cached.
+//
+// EndpointSlices of other Services that are not LoadBalancer, e.g. ClusterIP or NodePort ones, are still cached,
+// trimmed to what TrimEndpointSlice keeps, since slices carry no type of their Service to select them by. They are
+// dropped when they are mapped to Services to reconcile.
+func SetTrimmedCacheOptions(byObject map[client.Object]cache.ByObject) {
+	byObject[&corev1.Node{}] = cache.ByObject{Transform: NewNodeTrimmer(staticlb.NodeAnnotationKeys()...)}
+	byObject[&corev1.Service{}] = cache.ByObject{Transform: TrimService}
+	byObject[&discoveryv1.EndpointSlice{}] = cache.ByObject{
+		Label:     EndpointSliceOfServiceSelector(),
+		Transform: TrimEndpointSlice,
+	}
+}
+
+const staticLBDomain = "static-lb.bhyoo.com"
+
+var (
+	_ toolscache.TransformFunc = TrimService
+	_ toolscache.TransformFunc = TrimEndpointSlice
+)
+
+// EndpointSliceOfServiceSelector selects EndpointSlices that belong to a Service which may be a LoadBalancer, i.e.
+// is not headless.
+func EndpointSliceOfServiceSelector() labels.Selector {
+	ofService, err := labels.NewRequirement(discoveryv1.LabelServiceName, selection.Exists, nil)
+	if err != nil {
+		panic(err)
+	}
+	notHeadless, err := labels.NewRequirement(corev1.IsHeadlessService, selection.DoesNotExist, nil)
+	if err != nil {
+		panic(err)
+	}
+	return labels.NewSelector().Add(*ofService, *notHeadless)
+}
+
+// NewNodeTrimmer returns a transform that keeps metadata, spec, addresses and conditions of a Node. Annotations are
+// dropped as well since nodes are never written back by static-lb, except for ones of static-lb and ones of
+// annotationKeys that address sources read.
+func NewNodeTrimmer(annotationKeys ...string) toolscache.TransformFunc {
+	keys := make(map[string]struct{}, len(annotationKeys))
+	for _, key := range annotationKeys {
+		keys[key] = struct{}{}
+	}
+
+	return func(obj interface{}) (interface{}, error) {
+		node, ok := obj.(*corev1.Node)
+		if !ok {
+			return obj, nil
+		}
+
+		node.ManagedFields = nil
+		node.Annotations = keptAnnotations(node.Annotations, keys)
+		node.Status = corev1.NodeStatus{
+			Addresses:  node.Status.Addresses,
+			Conditions: node.Status.Conditions,
+		}
+		return node, nil
+	}
+}
+
+// TrimService drops only managedFields of a Service. Other fields must be kept intact because Services are updated
+// with the cached object.
+func TrimService(obj interface{}) (interface{}, error) {
+	svc, ok := obj.(*corev1.Service)
+	if !ok {
+		return obj, nil
+	}
+
+	svc.ManagedFields = nil
+	return svc, nil
+}
+
+// TrimEndpointSlice keeps metadata, ports and node, zone and conditions of each endpoint of an EndpointSlice.
+func TrimEndpointSlice(obj interface{}) (interface{}, error) {
+	epSlice, ok := obj.(*discoveryv1.EndpointSlice)
+	if !ok {
+		return obj, nil
+	}
+
+	epSlice.ManagedFields = nil
+	epSlice.Annotations = nil
+	for i, endpoint := range epSlice.Endpoints {
+		epSlice.Endpoints[i] = discoveryv1.Endpoint{
+			Conditions: endpoint.Conditions,
+			NodeName:   endpoint.NodeName,
+			Zone:       endpoint.Zone,
+		}
+	}
+	return epSlice, nil
+}
+
+// keptAnnotations returns annotations of static-lb, including ones of named instances, and ones of keys out of
+// annotations.
+func keptAnnotations(annotations map[string]string, keys map[string]struct{}) map[string]string {
+	var result map[string]string
+	for key, val := range annotations {
+		domain, _, found := strings.Cut(key, "/")
+		isStaticLB := found && (domain == staticLBDomain || strings.HasSuffix(domain, "."+staticLBDomain))
+		if _, exists := keys[key]; !isStaticLB && !exists {
+			continue
+		}
+		if result == nil {
+			result = make(map[string]string)
+		}
+		result[key] = val
+	}
+	return result
+}
@@ -0,0 +1,152 @@
+package infrastructure
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewNodeTrimmer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		annotationKeys []string
+		annotations    map[string]string
+		expected       map[string]string
+	}{
+		{
+			name: "static-lb annotations",
+			annotations: map[string]string{
+				"static-lb.bhyoo.com/node-addresses":      "10.0.0.1",
+				"edge.static-lb.bhyoo.com/node-addresses": "10.0.0.2",
+				"example.com/addresses":                   "10.0.0.3",
+			},
+			expected: map[string]string{
+				"static-lb.bhyoo.com/node-addresses":      "10.0.0.1",
+				"edge.static-lb.bhyoo.com/node-addresses": "10.0.0.2",
+			},
+		},
+		{
+			name:           "annotations of address sources",
+			annotationKeys: []string{"example.com/addresses"},
+			annotations: map[string]string{
+				"example.com/addresses": "10.0.0.3",
+				"example.com/other":     "value",
+			},
+			expected: map[string]string{"example.com/addresses": "10.0.0.3"},
+		},
+		{
+			name:           "no annotation is kept",
+			annotationKeys: []string{"example.com/addresses"},
+			annotations:    map[string]string{"example.com/other": "value"},
+			expected:       nil,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a", Annotations: tc.annotations}}
+			trimmed, err := NewNodeTrimmer(tc.annotationKeys...)(node)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, trimmed.(*corev1.Node).Annotations)
+		})
+	}
+}
+
+func TestEndpointSliceOfServiceSelector(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		labels   map[string]string
+		expected bool
+	}{
+		{
+			name:     "slice of a Service",
+			labels:   map[string]string{discoveryv1.LabelServiceName: "web"},
+			expected: true,
+		},
+		{
+			name:   "slice of a headless Service",
+			labels: map[string]string{discoveryv1.LabelServiceName: "web", corev1.IsHeadlessService: ""},
+		},
+		{
+			name:   "slice of no Service",
+			labels: map[string]string{discoveryv1.LabelManagedBy: "example.com/controller"},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, EndpointSliceOfServiceSelector().Matches(labels.Set(tc.labels)))
+		})
+	}
+}
+
+func TestTrimService(t *testing.T) {
+	t.Parallel()
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:          "web",
+			Annotations:   map[string]string{"example.com/other": "value"},
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+		},
+		Spec:   corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer, ExternalIPs: []string{"10.0.0.1"}},
+		Status: corev1.ServiceStatus{Conditions: []metav1.Condition{{Type: "IPsAssigned"}}},
+	}
+	expected := svc.DeepCopy()
+	expected.ManagedFields = nil
+
+	trimmed, err := TrimService(svc)
+	require.NoError(t, err)
+	assert.Equal(t, expected, trimmed, "every field but managedFields is kept, since Services are written back")
+}
+
+func TestTrimEndpointSlice(t *testing.T) {
+	t.Parallel()
+
+	serving := true
+	nodeName := "node-a"
+	zone := "zone-a"
+	hostname := "web-0"
+	epSlice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:          "web-abcde",
+			Labels:        map[string]string{discoveryv1.LabelServiceName: "web"},
+			Annotations:   map[string]string{"endpoints.kubernetes.io/last-change-trigger-time": "now"},
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "endpointslice-controller"}},
+		},
+		Endpoints: []discoveryv1.Endpoint{{
+			Addresses:  []string{"10.244.0.10"},
+			Conditions: discoveryv1.EndpointConditions{Serving: &serving},
+			Hostname:   &hostname,
+			NodeName:   &nodeName,
+			Zone:       &zone,
+			TargetRef:  &corev1.ObjectReference{Kind: "Pod", Name: "web-0"},
+		}},
+	}
+
+	trimmed, err := TrimEndpointSlice(epSlice)
+	require.NoError(t, err)
+	actual := trimmed.(*discoveryv1.EndpointSlice)
+	assert.Equal(t, map[string]string{discoveryv1.LabelServiceName: "web"}, actual.Labels)
+	assert.Nil(t, actual.Annotations)
+	assert.Nil(t, actual.ManagedFields)
+	assert.Equal(t, []discoveryv1.Endpoint{{
+		Conditions: discoveryv1.EndpointConditions{Serving: &serving},
+		NodeName:   &nodeName,
+		Zone:       &zone,
+	}}, actual.Endpoints)
+}
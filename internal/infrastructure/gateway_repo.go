@@ -0,0 +1,99 @@
+package infrastructure
+
+import (
+	"context"
+
+	"github.com/isac322/static-lb/internal/application"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+const (
+	gatewayServiceIndexName = "BackingServiceName"
+)
+
+type K8sClientGatewayRepository struct {
+	k8sClient    client.Client
+	instanceName string
+}
+
+// NewGatewayRepository creates a repository of Gateways, whose backing Services are indexed as the instance named
+// instanceName reads them.
+func NewGatewayRepository(cli client.Client, instanceName string) K8sClientGatewayRepository {
+	return K8sClientGatewayRepository{
+		k8sClient:    cli,
+		instanceName: instanceName,
+	}
+}
+
+func (k K8sClientGatewayRepository) AssignAddresses(
+	ctx context.Context,
+	gw gatewayv1.Gateway,
+	addresses []string,
+	conditions []metav1.Condition,
+) error {
+	newGW := gw.DeepCopy()
+
+	ipAddressType := gatewayv1.IPAddressType
+	newGW.Status.Addresses = make([]gatewayv1.GatewayStatusAddress, len(addresses))
+	for i, address := range addresses {
+		newGW.Status.Addresses[i] = gatewayv1.GatewayStatusAddress{Type: &ipAddressType, Value: address}
+	}
+
+	for _, condition := range conditions {
+		meta.SetStatusCondition(&newGW.Status.Conditions, condition)
+	}
+
+	return k.k8sClient.Status().Update(ctx, newGW)
+}
+
+func (k K8sClientGatewayRepository) ListBackedBy(
+	ctx context.Context,
+	svcKey types.NamespacedName,
+) (gateways gatewayv1.GatewayList, err error) {
+	if err = k.k8sClient.List(
+		ctx,
+		&gateways,
+		client.MatchingFields{gatewayServiceIndexName: svcKey.String()},
+	); err != nil {
+		return gatewayv1.GatewayList{}, err
+	}
+
+	return gateways, nil
+}
+
+func (k K8sClientGatewayRepository) ListReferenceGrants(
+	ctx context.Context,
+	namespace string,
+) ([]gatewayv1beta1.ReferenceGrant, error) {
+	var grants gatewayv1beta1.ReferenceGrantList
+	if err := k.k8sClient.List(ctx, &grants, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+
+	return grants.Items, nil
+}
+
+func (k K8sClientGatewayRepository) RegisterFieldIndex(ctx context.Context, indexer client.FieldIndexer) error {
+	return indexer.IndexField(
+		ctx,
+		&gatewayv1.Gateway{},
+		gatewayServiceIndexName,
+		func(rawObj client.Object) []string {
+			gw, ok := rawObj.(*gatewayv1.Gateway)
+			if gw == nil || !ok {
+				return nil
+			}
+			serviceKey, exists := application.GatewayServiceKey(*gw, k.instanceName)
+			if !exists {
+				return nil
+			}
+			return []string{serviceKey.String()}
+		},
+	)
+}
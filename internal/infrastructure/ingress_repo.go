@@ -0,0 +1,29 @@
+package infrastructure
+
+import (
+	"context"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type K8sClientIngressRepository struct {
+	k8sClient client.Client
+}
+
+func NewIngressRepository(cli client.Client) K8sClientIngressRepository {
+	return K8sClientIngressRepository{
+		k8sClient: cli,
+	}
+}
+
+func (k K8sClientIngressRepository) AssignIPs(ctx context.Context, ing networkingv1.Ingress, ips []string) error {
+	newIng := ing.DeepCopy()
+
+	newIng.Status.LoadBalancer.Ingress = make([]networkingv1.IngressLoadBalancerIngress, len(ips))
+	for i, ip := range ips {
+		newIng.Status.LoadBalancer.Ingress[i].IP = ip
+	}
+
+	return k.k8sClient.Status().Update(ctx, newIng)
+}
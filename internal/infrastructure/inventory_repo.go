@@ -0,0 +1,198 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/isac322/static-lb/internal/application"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	InventoryJSONKey = "inventory.json"
+	InventoryTextKey = "inventory.txt"
+)
+
+type inventory struct {
+	Services []inventoryService `json:"services"`
+}
+
+type inventoryService struct {
+	Namespace   string          `json:"namespace"`
+	Name        string          `json:"name"`
+	IngressIPs  []string        `json:"ingressIPs"`
+	ExternalIPs []string        `json:"externalIPs"`
+	Ports       []inventoryPort `json:"ports"`
+	Nodes       []string        `json:"nodes"`
+}
+
+type inventoryPort struct {
+	Name     string `json:"name,omitempty"`
+	Protocol string `json:"protocol"`
+	Port     int32  `json:"port"`
+	NodePort int32  `json:"nodePort,omitempty"`
+}
+
+// K8sClientInventoryRepository keeps the inventory in a ConfigMap, in JSON and in plain text of a line per Service.
+// Each change is a read-modify-write of the ConfigMap that is retried on conflicts, so that writers never overwrite
+// changes of each other.
+type K8sClientInventoryRepository struct {
+	reader    client.Reader
+	k8sClient client.Client
+	key       types.NamespacedName
+}
+
+// NewInventoryRepository creates a repository of the ConfigMap of key. The ConfigMap is read with reader, which
+// should not be cached, so that no informer of ConfigMaps is started.
+func NewInventoryRepository(
+	reader client.Reader,
+	cli client.Client,
+	key types.NamespacedName,
+) K8sClientInventoryRepository {
+	return K8sClientInventoryRepository{
+		reader:    reader,
+		k8sClient: cli,
+		key:       key,
+	}
+}
+
+func (k K8sClientInventoryRepository) Put(ctx context.Context, entry application.InventoryEntry) error {
+	service := inventoryService{
+		Namespace:   entry.Service.Namespace,
+		Name:        entry.Service.Name,
+		IngressIPs:  entry.IngressIPs,
+		ExternalIPs: entry.ExternalIPs,
+		Nodes:       entry.Nodes,
+	}
+	for _, port := range entry.Ports {
+		service.Ports = append(service.Ports, inventoryPort(port))
+	}
+
+	return k.update(ctx, func(inv *inventory) {
+		for i := range inv.Services {
+			if inv.Services[i].Namespace == service.Namespace && inv.Services[i].Name == service.Name {
+				inv.Services[i] = service
+				return
+			}
+		}
+		inv.Services = append(inv.Services, service)
+	})
+}
+
+func (k K8sClientInventoryRepository) Delete(ctx context.Context, svcKey types.NamespacedName) error {
+	return k.update(ctx, func(inv *inventory) {
+		services := inv.Services[:0]
+		for _, service := range inv.Services {
+			if service.Namespace != svcKey.Namespace || service.Name != svcKey.Name {
+				services = append(services, service)
+			}
+		}
+		inv.Services = services
+	})
+}
+
+func (k K8sClientInventoryRepository) Retain(
+	ctx context.Context,
+	keep func(svcKey types.NamespacedName) bool,
+) error {
+	return k.update(ctx, func(inv *inventory) {
+		services := inv.Services[:0]
+		for _, service := range inv.Services {
+			if keep(types.NamespacedName{Namespace: service.Namespace, Name: service.Name}) {
+				services = append(services, service)
+			}
+		}
+		inv.Services = services
+	})
+}
+
+// update applies modify to the inventory in the ConfigMap, creating the ConfigMap if it does not exist.
+func (k K8sClientInventoryRepository) update(ctx context.Context, modify func(inv *inventory)) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var cm corev1.ConfigMap
+		err := k.reader.Get(ctx, k.key, &cm)
+		exists := err == nil
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+
+		var inv inventory
+		if raw := cm.Data[InventoryJSONKey]; raw != "" {
+			if err = json.Unmarshal([]byte(raw), &inv); err != nil {
+				return fmt.Errorf("unable to parse %s of ConfigMap %s: %w", InventoryJSONKey, k.key, err)
+			}
+		}
+		origJSON, origText := cm.Data[InventoryJSONKey], cm.Data[InventoryTextKey]
+
+		modify(&inv)
+		sort.Slice(inv.Services, func(i, j int) bool {
+			if inv.Services[i].Namespace != inv.Services[j].Namespace {
+				return inv.Services[i].Namespace < inv.Services[j].Namespace
+			}
+			return inv.Services[i].Name < inv.Services[j].Name
+		})
+
+		rawJSON, err := json.MarshalIndent(inv, "", "  ")
+		if err != nil {
+			return err
+		}
+		text := inventoryText(inv)
+		if exists && string(rawJSON) == origJSON && text == origText {
+			return nil
+		}
+
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[InventoryJSONKey] = string(rawJSON)
+		cm.Data[InventoryTextKey] = text
+
+		if !exists {
+			cm.ObjectMeta = metav1.ObjectMeta{Namespace: k.key.Namespace, Name: k.key.Name}
+			err = k.k8sClient.Create(ctx, &cm)
+			if apierrors.IsAlreadyExists(err) {
+				// created by another writer in the meantime
+				return apierrors.NewConflict(corev1.Resource("configmaps"), k.key.Name, err)
+			}
+			return err
+		}
+		return k.k8sClient.Update(ctx, &cm)
+	})
+}
+
+// inventoryText formats inv as a line per Service:
+// "<namespace>/<name> ingress=<ip>,... external=<ip>,... ports=<name>:<port>/<protocol>,... nodes=<node>,...".
+func inventoryText(inv inventory) string {
+	var b strings.Builder
+	for _, service := range inv.Services {
+		ports := make([]string, 0, len(service.Ports))
+		for _, port := range service.Ports {
+			formatted := fmt.Sprintf("%d/%s", port.Port, port.Protocol)
+			if port.Name != "" {
+				formatted = port.Name + ":" + formatted
+			}
+			ports = append(ports, formatted)
+		}
+
+		fmt.Fprintf(
+			&b,
+			"%s/%s ingress=%s external=%s ports=%s nodes=%s\n",
+			service.Namespace,
+			service.Name,
+			strings.Join(service.IngressIPs, ","),
+			strings.Join(service.ExternalIPs, ","),
+			strings.Join(ports, ","),
+			strings.Join(service.Nodes, ","),
+		)
+	}
+	return b.String()
+}
@@ -0,0 +1,105 @@
+// Package lister reads objects from informers of client-go, for entrypoints that do not run controller-runtime, so
+// that they do not depend on it.
+package lister
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
+)
+
+// NodeRepository reads Nodes from an informer of client-go.
+type NodeRepository struct {
+	lister corelisters.NodeLister
+}
+
+func NewNodeRepository(lister corelisters.NodeLister) NodeRepository {
+	return NodeRepository{lister: lister}
+}
+
+func (l NodeRepository) List(context.Context) ([]corev1.Node, error) {
+	nodeList, err := l.lister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]corev1.Node, 0, len(nodeList))
+	for _, node := range nodeList {
+		nodes = append(nodes, *node)
+	}
+	return nodes, nil
+}
+
+func (l NodeRepository) ListByNames(
+	_ context.Context,
+	names []string,
+) (nodes []corev1.Node, missing []string, err error) {
+	nodes = make([]corev1.Node, 0, len(names))
+	for _, name := range names {
+		node, err := l.lister.Get(name)
+		switch {
+		case apierrors.IsNotFound(err):
+			missing = append(missing, name)
+		case err != nil:
+			return nil, nil, err
+		default:
+			nodes = append(nodes, *node)
+		}
+	}
+	return nodes, missing, nil
+}
+
+func (l NodeRepository) ListReady(ctx context.Context) ([]corev1.Node, error) {
+	return l.ListReadyMatching(ctx, labels.Everything())
+}
+
+func (l NodeRepository) ListReadyMatching(_ context.Context, selector labels.Selector) ([]corev1.Node, error) {
+	nodeList, err := l.lister.List(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]corev1.Node, 0, len(nodeList))
+	for _, node := range nodeList {
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == corev1.NodeReady && condition.Status == corev1.ConditionTrue {
+				nodes = append(nodes, *node)
+				break
+			}
+		}
+	}
+	return nodes, nil
+}
+
+// EndpointSliceRepository reads EndpointSlices from an informer of client-go.
+type EndpointSliceRepository struct {
+	lister discoverylisters.EndpointSliceLister
+}
+
+func NewEndpointSliceRepository(lister discoverylisters.EndpointSliceLister) EndpointSliceRepository {
+	return EndpointSliceRepository{lister: lister}
+}
+
+func (l EndpointSliceRepository) ListLinkedTo(
+	_ context.Context,
+	svcKey types.NamespacedName,
+) (discoveryv1.EndpointSliceList, error) {
+	slices, err := l.lister.EndpointSlices(svcKey.Namespace).List(
+		labels.SelectorFromSet(labels.Set{discoveryv1.LabelServiceName: svcKey.Name}),
+	)
+	if err != nil {
+		return discoveryv1.EndpointSliceList{}, err
+	}
+
+	result := discoveryv1.EndpointSliceList{Items: make([]discoveryv1.EndpointSlice, 0, len(slices))}
+	for _, slice := range slices {
+		result.Items = append(result.Items, *slice)
+	}
+	return result, nil
+}
@@ -0,0 +1,34 @@
+package infrastructure
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+type PrometheusMetricsRecorder struct {
+	driftCorrections *prometheus.CounterVec
+}
+
+// NewMetricsRecorder creates metrics of static-lb and registers them to the registry of controller-runtime,
+// so that they are exposed on the same endpoint.
+func NewMetricsRecorder() (PrometheusMetricsRecorder, error) {
+	r := PrometheusMetricsRecorder{
+		driftCorrections: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "static_lb_drift_corrections_total",
+				Help: "Number of times IPs of a Service were modified outside of static-lb and put back",
+			},
+			[]string{"namespace"},
+		),
+	}
+
+	if err := metrics.Registry.Register(r.driftCorrections); err != nil {
+		return PrometheusMetricsRecorder{}, err
+	}
+	return r, nil
+}
+
+func (r PrometheusMetricsRecorder) DriftCorrected(svcKey types.NamespacedName) {
+	r.driftCorrections.WithLabelValues(svcKey.Namespace).Inc()
+}
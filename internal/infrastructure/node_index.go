@@ -0,0 +1,94 @@
+package infrastructure
+
+import (
+	"context"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+// nodeIndex keeps Nodes of the informer by name, so that nodes of endpoints are looked up without listing and copying
+// every node of the cluster. Nodes are trimmed by the transform of the cache before they reach the index.
+type nodeIndex struct {
+	mu           sync.RWMutex
+	byName       map[string]*corev1.Node
+	registration toolscache.ResourceEventHandlerRegistration
+}
+
+func newNodeIndex() *nodeIndex {
+	return &nodeIndex{byName: map[string]*corev1.Node{}}
+}
+
+// register fills the index from the Node informer of informers.
+func (i *nodeIndex) register(ctx context.Context, informers cache.Informers) error {
+	informer, err := informers.GetInformer(ctx, &corev1.Node{})
+	if err != nil {
+		return err
+	}
+
+	registration, err := informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    i.put,
+		UpdateFunc: func(_, obj interface{}) { i.put(obj) },
+		DeleteFunc: i.delete,
+	})
+	if err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.registration = registration
+	return nil
+}
+
+// synced reports whether the index has every Node of the informer.
+func (i *nodeIndex) synced() bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.registration != nil && i.registration.HasSynced()
+}
+
+func (i *nodeIndex) put(obj interface{}) {
+	node, ok := obj.(*corev1.Node)
+	if !ok {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.byName[node.Name] = node
+}
+
+func (i *nodeIndex) delete(obj interface{}) {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	node, ok := obj.(*corev1.Node)
+	if !ok {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	delete(i.byName, node.Name)
+}
+
+// get returns Nodes of names, and names of Nodes that are not in the index. Nodes are shared with the informer, so
+// callers must not modify them.
+func (i *nodeIndex) get(names []string) (nodes []corev1.Node, missing []string) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	nodes = make([]corev1.Node, 0, len(names))
+	for _, name := range names {
+		node, exists := i.byName[name]
+		if !exists {
+			missing = append(missing, name)
+			continue
+		}
+		nodes = append(nodes, *node)
+	}
+	return nodes, missing
+}
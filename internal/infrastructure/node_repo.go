@@ -4,31 +4,80 @@ import (
 	"context"
 
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type K8sClientNodeRepository struct {
 	k8sClient client.Client
+	index     *nodeIndex
 }
 
 func NewNodeRepository(cli client.Client) K8sClientNodeRepository {
 	return K8sClientNodeRepository{
 		k8sClient: cli,
+		index:     newNodeIndex(),
 	}
 }
 
-func (k K8sClientNodeRepository) GetByName(ctx context.Context, name string) (node corev1.Node, err error) {
-	if err = k.k8sClient.Get(ctx, types.NamespacedName{Name: name}, &node); err != nil {
-		return corev1.Node{}, err
+// RegisterIndex fills the index of Nodes by name that ListByNames reads, from the Node informer of informers.
+func (k K8sClientNodeRepository) RegisterIndex(ctx context.Context, informers cache.Informers) error {
+	return k.index.register(ctx, informers)
+}
+
+func (k K8sClientNodeRepository) List(ctx context.Context) ([]corev1.Node, error) {
+	var nodeList corev1.NodeList
+	if err := k.k8sClient.List(ctx, &nodeList); err != nil {
+		return nil, err
+	}
+
+	return nodeList.Items, nil
+}
+
+// ListByNames reads nodes from the index once it is synced. Until then, or without RegisterIndex, nodes are fetched one
+// by one from the client.
+func (k K8sClientNodeRepository) ListByNames(
+	ctx context.Context,
+	names []string,
+) (nodes []corev1.Node, missing []string, err error) {
+	if len(names) == 0 {
+		return nil, nil, nil
 	}
 
-	return node, nil
+	if k.index.synced() {
+		nodes, missing = k.index.get(names)
+		return nodes, missing, nil
+	}
+
+	nodes = make([]corev1.Node, 0, len(names))
+	for _, name := range names {
+		var node corev1.Node
+		err = k.k8sClient.Get(ctx, client.ObjectKey{Name: name}, &node)
+		switch {
+		case apierrors.IsNotFound(err):
+			missing = append(missing, name)
+		case err != nil:
+			return nil, nil, err
+		default:
+			nodes = append(nodes, node)
+		}
+	}
+
+	return nodes, missing, nil
 }
 
 func (k K8sClientNodeRepository) ListReady(ctx context.Context) ([]corev1.Node, error) {
+	return k.ListReadyMatching(ctx, labels.Everything())
+}
+
+func (k K8sClientNodeRepository) ListReadyMatching(
+	ctx context.Context,
+	selector labels.Selector,
+) ([]corev1.Node, error) {
 	var nodeList corev1.NodeList
-	if err := k.k8sClient.List(ctx, &nodeList); err != nil {
+	if err := k.k8sClient.List(ctx, &nodeList, client.MatchingLabelsSelector{Selector: selector}); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,170 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncedRegistration stands in for the registration of an informer that has delivered every Node.
+type syncedRegistration struct{}
+
+func (syncedRegistration) HasSynced() bool { return true }
+
+func newNode(name string) *corev1.Node {
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+}
+
+// newNodeRepository returns a repository over nodes. With indexed, ListByNames reads the synced index instead of the
+// client.
+func newNodeRepository(indexed bool, nodes ...*corev1.Node) K8sClientNodeRepository {
+	objects := make([]client.Object, 0, len(nodes))
+	for _, node := range nodes {
+		objects = append(objects, node)
+	}
+	repo := NewNodeRepository(fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objects...).Build())
+
+	if indexed {
+		for _, node := range nodes {
+			repo.index.put(node)
+		}
+		repo.index.registration = syncedRegistration{}
+	}
+	return repo
+}
+
+func TestK8sClientNodeRepository_ListByNames(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		indexed         bool
+		names           []string
+		expectedNodes   []string
+		expectedMissing []string
+	}{
+		{
+			name:          "from index",
+			indexed:       true,
+			names:         []string{"node-b", "node-a"},
+			expectedNodes: []string{"node-b", "node-a"},
+		},
+		{
+			name:            "missing from index",
+			indexed:         true,
+			names:           []string{"node-a", "node-gone"},
+			expectedNodes:   []string{"node-a"},
+			expectedMissing: []string{"node-gone"},
+		},
+		{
+			name:          "from client before index is synced",
+			names:         []string{"node-b", "node-a"},
+			expectedNodes: []string{"node-b", "node-a"},
+		},
+		{
+			name:            "missing from client",
+			names:           []string{"node-gone", "node-a"},
+			expectedNodes:   []string{"node-a"},
+			expectedMissing: []string{"node-gone"},
+		},
+		{
+			name: "no names",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			repo := newNodeRepository(tc.indexed, newNode("node-a"), newNode("node-b"))
+
+			nodes, missing, err := repo.ListByNames(context.Background(), tc.names)
+			require.NoError(t, err)
+
+			var nodeNames []string
+			for _, node := range nodes {
+				nodeNames = append(nodeNames, node.Name)
+			}
+			assert.Equal(t, tc.expectedNodes, nodeNames)
+			assert.Equal(t, tc.expectedMissing, missing)
+		})
+	}
+}
+
+func TestNodeIndex_delete(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		obj  interface{}
+	}{
+		{
+			name: "node",
+			obj:  newNode("node-a"),
+		},
+		{
+			name: "tombstone",
+			obj:  toolscache.DeletedFinalStateUnknown{Key: "node-a", Obj: newNode("node-a")},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			index := newNodeIndex()
+			index.put(newNode("node-a"))
+			index.delete(tc.obj)
+
+			_, missing := index.get([]string{"node-a"})
+			assert.Equal(t, []string{"node-a"}, missing)
+		})
+	}
+}
+
+func BenchmarkK8sClientNodeRepository_ListByNames(b *testing.B) {
+	const (
+		nodeCount     = 100
+		endpointCount = 1000
+	)
+
+	nodes := make([]*corev1.Node, 0, nodeCount)
+	for i := 0; i < nodeCount; i++ {
+		nodes = append(nodes, newNode(fmt.Sprintf("node-%d", i)))
+	}
+
+	// Endpoints of a Service spread over every node, deduplicated like the usecase does before calling ListByNames.
+	// BenchmarkUsecase_listServingNodes covers resolving the endpoints themselves.
+	names := make([]string, 0, nodeCount)
+	visited := make(map[string]struct{}, nodeCount)
+	for i := 0; i < endpointCount; i++ {
+		name := fmt.Sprintf("node-%d", i%nodeCount)
+		if _, exists := visited[name]; !exists {
+			visited[name] = struct{}{}
+			names = append(names, name)
+		}
+	}
+
+	for _, indexed := range []bool{true, false} {
+		repo := newNodeRepository(indexed, nodes...)
+		b.Run(fmt.Sprintf("indexed=%t", indexed), func(b *testing.B) {
+			ctx := context.Background()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := repo.ListByNames(ctx, names); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package infrastructure
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type K8sClientPodRepository struct {
+	k8sClient client.Client
+}
+
+func NewPodRepository(cli client.Client) K8sClientPodRepository {
+	return K8sClientPodRepository{
+		k8sClient: cli,
+	}
+}
+
+func (k K8sClientPodRepository) ListReadyMatching(
+	ctx context.Context,
+	namespace string,
+	selector labels.Selector,
+) ([]corev1.Pod, error) {
+	var podList corev1.PodList
+	if err := k.k8sClient.List(
+		ctx,
+		&podList,
+		client.InNamespace(namespace),
+		client.MatchingLabelsSelector{Selector: selector},
+	); err != nil {
+		return nil, err
+	}
+
+	pods := make([]corev1.Pod, 0, len(podList.Items))
+	for _, pod := range podList.Items {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type != corev1.PodReady || condition.Status != corev1.ConditionTrue {
+				continue
+			}
+
+			pods = append(pods, pod)
+			break
+		}
+	}
+
+	return pods, nil
+}
@@ -5,29 +5,64 @@ import (
 
 	"github.com/isac322/static-lb/internal/application"
 
+	"golang.org/x/time/rate"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type K8sClientServiceRepository struct {
-	k8sClient client.Client
+	k8sClient    client.Client
+	writeLimiter *rate.Limiter
+	fieldOwner   client.FieldOwner
 }
 
-func NewServiceRepository(cli client.Client) K8sClientServiceRepository {
+// NewServiceRepository creates a repository whose writes to the API server wait for writeLimiter.
+// Writes are not limited if writeLimiter is nil. Written fields are managed by fieldOwner, which tells instances apart
+// in managedFields.
+func NewServiceRepository(cli client.Client, writeLimiter *rate.Limiter, fieldOwner string) K8sClientServiceRepository {
+	if writeLimiter == nil {
+		writeLimiter = rate.NewLimiter(rate.Inf, 0)
+	}
 	return K8sClientServiceRepository{
-		k8sClient: cli,
+		k8sClient:    cli,
+		writeLimiter: writeLimiter,
+		fieldOwner:   client.FieldOwner(fieldOwner),
+	}
+}
+
+func (k K8sClientServiceRepository) Get(ctx context.Context, key types.NamespacedName) (svc corev1.Service, err error) {
+	if err = k.k8sClient.Get(ctx, key, &svc); err != nil {
+		return corev1.Service{}, err
+	}
+
+	return svc, nil
+}
+
+func (k K8sClientServiceRepository) List(ctx context.Context) ([]corev1.Service, error) {
+	var svcList corev1.ServiceList
+	if err := k.k8sClient.List(ctx, &svcList); err != nil {
+		return nil, err
 	}
+
+	return svcList.Items, nil
 }
 
 func (k K8sClientServiceRepository) AssignIPs(
 	ctx context.Context,
 	svc corev1.Service,
 	target application.IPStatus,
+	conditions []metav1.Condition,
 ) error {
 	newSvc := svc.DeepCopy()
 	newSvc.Spec.ExternalIPs = target.ExternalIPs
 
-	if err := k.k8sClient.Update(ctx, newSvc); err != nil {
+	if err := k.writeLimiter.Wait(ctx); err != nil {
+		return err
+	}
+	if err := k.k8sClient.Update(ctx, newSvc, k.fieldOwner); err != nil {
 		return err
 	}
 
@@ -36,5 +71,17 @@ func (k K8sClientServiceRepository) AssignIPs(
 		newSvc.Status.LoadBalancer.Ingress[i].IP = ip
 	}
 
-	return k.k8sClient.Status().Update(ctx, newSvc)
+	for _, conditionType := range application.ServiceConditionTypes {
+		if meta.FindStatusCondition(conditions, conditionType) == nil {
+			meta.RemoveStatusCondition(&newSvc.Status.Conditions, conditionType)
+		}
+	}
+	for _, condition := range conditions {
+		meta.SetStatusCondition(&newSvc.Status.Conditions, condition)
+	}
+
+	if err := k.writeLimiter.Wait(ctx); err != nil {
+		return err
+	}
+	return k.k8sClient.Status().Update(ctx, newSvc, k.fieldOwner)
 }
@@ -0,0 +1,68 @@
+package infrastructure
+
+import (
+	"context"
+	"testing"
+
+	"github.com/isac322/static-lb/internal/application"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestK8sClientServiceRepository_AssignIPs(t *testing.T) {
+	t.Parallel()
+
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+	}
+
+	var fieldManagers []string
+	cli := fake.NewClientBuilder().
+		WithScheme(scheme.Scheme).
+		WithObjects(svc).
+		WithStatusSubresource(svc).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+				updateOpts := &client.UpdateOptions{}
+				updateOpts.ApplyOptions(opts)
+				fieldManagers = append(fieldManagers, updateOpts.FieldManager)
+				return c.Update(ctx, obj, opts...)
+			},
+			SubResourceUpdate: func(
+				ctx context.Context,
+				c client.Client,
+				subResourceName string,
+				obj client.Object,
+				opts ...client.SubResourceUpdateOption,
+			) error {
+				updateOpts := &client.SubResourceUpdateOptions{}
+				updateOpts.ApplyOptions(opts)
+				fieldManagers = append(fieldManagers, updateOpts.FieldManager)
+				return c.SubResource(subResourceName).Update(ctx, obj, opts...)
+			},
+		}).
+		Build()
+
+	var current corev1.Service
+	require.NoError(t, cli.Get(context.Background(), client.ObjectKeyFromObject(svc), &current))
+
+	repo := NewServiceRepository(cli, nil, "static-lb-edge")
+	target := application.IPStatus{IngressIPs: []string{"10.0.0.1"}, ExternalIPs: []string{"10.0.0.2"}}
+	require.NoError(t, repo.AssignIPs(context.Background(), current, target, nil))
+
+	assert.Equal(t, []string{"static-lb-edge", "static-lb-edge"}, fieldManagers, "spec and status are written")
+
+	var actual corev1.Service
+	require.NoError(t, cli.Get(context.Background(), client.ObjectKeyFromObject(svc), &actual))
+	assert.Equal(t, []string{"10.0.0.2"}, actual.Spec.ExternalIPs)
+	assert.Equal(t, []corev1.LoadBalancerIngress{{IP: "10.0.0.1"}}, actual.Status.LoadBalancer.Ingress)
+}
@@ -0,0 +1,262 @@
+package infrastructure
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/isac322/static-lb/internal/pkg/shard"
+
+	"github.com/go-logr/logr"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	shardGroupLabel = "static-lb.bhyoo.com/shard-group"
+)
+
+// LeaseShardMembership keeps a Lease per replica of a shard group and assigns each object to one of replicas
+// whose Lease is not expired. Every replica computes the same assignment from the same set of Leases.
+type LeaseShardMembership struct {
+	reader        client.Reader
+	writer        client.Client
+	namespace     string
+	group         string
+	identity      string
+	leaseDuration time.Duration
+	now           func() time.Time
+
+	mu          sync.RWMutex
+	members     []string
+	renewedAt   time.Time
+	subscribers []chan struct{}
+}
+
+// NewLeaseShardMembership creates a membership of identity in group. Leases are read with reader, which should not
+// be cached, so that no informer of Leases is started.
+func NewLeaseShardMembership(
+	reader client.Reader,
+	writer client.Client,
+	namespace string,
+	group string,
+	identity string,
+	leaseDuration time.Duration,
+) *LeaseShardMembership {
+	return &LeaseShardMembership{
+		reader:        reader,
+		writer:        writer,
+		namespace:     namespace,
+		group:         group,
+		identity:      identity,
+		leaseDuration: leaseDuration,
+		now:           time.Now,
+	}
+}
+
+// Owns reports whether this replica owns key. Nothing is owned until the first membership is observed, nor once
+// the lease duration has passed since the last successful renewal, as other replicas may have taken over the shard.
+func (m *LeaseShardMembership) Owns(key types.NamespacedName) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.isExpired(m.now()) {
+		return false
+	}
+	return shard.Owner(m.members, key.String()) == m.identity
+}
+
+// Changes returns a new channel that notifies every change of the membership, that is, when ownership of some keys
+// may have moved. Each consumer has to call it once and keep the channel.
+func (m *LeaseShardMembership) Changes() <-chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	changes := make(chan struct{}, 1)
+	m.subscribers = append(m.subscribers, changes)
+	return changes
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, since every replica has to keep its Lease.
+func (m *LeaseShardMembership) NeedLeaderElection() bool {
+	return false
+}
+
+// Start renews the Lease of this replica and refreshes members on every third of the lease duration until ctx is
+// done, then releases the Lease so that other replicas take over without waiting for it to expire.
+func (m *LeaseShardMembership) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithValues("shardGroup", m.group, "identity", m.identity)
+
+	ticker := time.NewTicker(m.leaseDuration / 3)
+	defer ticker.Stop()
+
+	for {
+		if err := m.renew(ctx); err != nil {
+			logger.Error(err, "unable to renew shard Lease")
+			m.expireIfOverdue()
+		} else if err = m.refreshMembers(ctx); err != nil {
+			logger.Error(err, "unable to list shard Leases")
+		}
+
+		select {
+		case <-ctx.Done():
+			m.release(logger)
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *LeaseShardMembership) leaseName() string {
+	return m.group + "-" + m.identity
+}
+
+// renew creates or updates the Lease of this replica, and records when it succeeded.
+func (m *LeaseShardMembership) renew(ctx context.Context) error {
+	renewedAt := m.now()
+	if err := m.writeLease(ctx, renewedAt); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.renewedAt = renewedAt
+	return nil
+}
+
+func (m *LeaseShardMembership) writeLease(ctx context.Context, renewedAt time.Time) error {
+	now := metav1.NewMicroTime(renewedAt)
+	durationSeconds := leaseDurationSeconds(m.leaseDuration)
+
+	var lease coordinationv1.Lease
+	err := m.reader.Get(ctx, types.NamespacedName{Namespace: m.namespace, Name: m.leaseName()}, &lease)
+	if apierrors.IsNotFound(err) {
+		lease = coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: m.namespace,
+				Name:      m.leaseName(),
+				Labels:    map[string]string{shardGroupLabel: m.group},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &m.identity,
+				LeaseDurationSeconds: &durationSeconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		return m.writer.Create(ctx, &lease)
+	}
+	if err != nil {
+		return err
+	}
+
+	lease.Spec.HolderIdentity = &m.identity
+	lease.Spec.LeaseDurationSeconds = &durationSeconds
+	lease.Spec.RenewTime = &now
+	return m.writer.Update(ctx, &lease)
+}
+
+// leaseDurationSeconds rounds d up to whole seconds, which Leases are written in, so that other replicas never see the
+// Lease expire before this replica stops owning its shard.
+func leaseDurationSeconds(d time.Duration) int32 {
+	return int32((d + time.Second - 1) / time.Second)
+}
+
+func (m *LeaseShardMembership) refreshMembers(ctx context.Context) error {
+	var leases coordinationv1.LeaseList
+	if err := m.reader.List(
+		ctx,
+		&leases,
+		client.InNamespace(m.namespace),
+		client.MatchingLabels{shardGroupLabel: m.group},
+	); err != nil {
+		return err
+	}
+
+	now := m.now()
+	members := make([]string, 0, len(leases.Items))
+	for _, lease := range leases.Items {
+		if isLeaseAlive(lease, now) {
+			members = append(members, *lease.Spec.HolderIdentity)
+		}
+	}
+	sort.Strings(members)
+
+	m.setMembers(members)
+	return nil
+}
+
+// expireIfOverdue drops every member once the Lease of this replica is expired, so that consumers of Changes learn
+// that this replica owns nothing, and get notified again when the Lease is renewed.
+func (m *LeaseShardMembership) expireIfOverdue() {
+	m.mu.RLock()
+	expired := m.isExpired(m.now())
+	m.mu.RUnlock()
+
+	if expired {
+		m.setMembers(nil)
+	}
+}
+
+// isExpired reports whether the lease duration has passed since the last successful renewal at now. It must be
+// called with mu held.
+func (m *LeaseShardMembership) isExpired(now time.Time) bool {
+	return !now.Before(m.renewedAt.Add(m.leaseDuration))
+}
+
+func (m *LeaseShardMembership) setMembers(members []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if equalMembers(m.members, members) {
+		return
+	}
+	m.members = members
+	for _, changes := range m.subscribers {
+		select {
+		case changes <- struct{}{}:
+		default:
+			// a change is already pending
+		}
+	}
+}
+
+func (m *LeaseShardMembership) release(logger logr.Logger) {
+	// ctx is already done, so the Lease is deleted with a fresh deadline
+	ctx, cancel := context.WithTimeout(context.Background(), m.leaseDuration/3)
+	defer cancel()
+
+	lease := coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Namespace: m.namespace, Name: m.leaseName()},
+	}
+	if err := client.IgnoreNotFound(m.writer.Delete(ctx, &lease)); err != nil {
+		logger.Error(err, "unable to release shard Lease")
+	}
+}
+
+func isLeaseAlive(lease coordinationv1.Lease, now time.Time) bool {
+	spec := lease.Spec
+	if spec.HolderIdentity == nil || spec.RenewTime == nil || spec.LeaseDurationSeconds == nil {
+		return false
+	}
+	expiresAt := spec.RenewTime.Add(time.Duration(*spec.LeaseDurationSeconds) * time.Second)
+	return now.Before(expiresAt)
+}
+
+func equalMembers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
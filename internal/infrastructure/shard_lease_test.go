@@ -0,0 +1,198 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/isac322/static-lb/internal/pkg/shard"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testLeaseDuration = 15 * time.Second
+
+var testNow = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newShardLease(group, holder string, renewedAt time.Time) *coordinationv1.Lease {
+	durationSeconds := int32(testLeaseDuration.Seconds())
+	renewTime := metav1.NewMicroTime(renewedAt)
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "static-lb",
+			Name:      group + "-" + holder,
+			Labels:    map[string]string{shardGroupLabel: group},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &durationSeconds,
+			RenewTime:            &renewTime,
+		},
+	}
+}
+
+// newTestMembership returns a membership of identity "a" in group "g" over leases, whose clock reads *now.
+func newTestMembership(
+	now *time.Time,
+	funcs interceptor.Funcs,
+	leases ...*coordinationv1.Lease,
+) *LeaseShardMembership {
+	objects := make([]client.Object, 0, len(leases))
+	for _, lease := range leases {
+		objects = append(objects, lease)
+	}
+	cli := fake.NewClientBuilder().
+		WithScheme(scheme.Scheme).
+		WithObjects(objects...).
+		WithInterceptorFuncs(funcs).
+		Build()
+
+	m := NewLeaseShardMembership(cli, cli, "static-lb", "g", "a", testLeaseDuration)
+	m.now = func() time.Time { return *now }
+	return m
+}
+
+func TestLeaseShardMembership_refreshMembers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		leases          []*coordinationv1.Lease
+		expectedMembers []string
+	}{
+		{
+			name: "alive leases of the group",
+			leases: []*coordinationv1.Lease{
+				newShardLease("g", "c", testNow),
+				newShardLease("g", "b", testNow.Add(-time.Second)),
+			},
+			expectedMembers: []string{"a", "b", "c"},
+		},
+		{
+			name: "expired lease",
+			leases: []*coordinationv1.Lease{
+				newShardLease("g", "b", testNow.Add(-testLeaseDuration)),
+			},
+			expectedMembers: []string{"a"},
+		},
+		{
+			name: "lease of another group",
+			leases: []*coordinationv1.Lease{
+				newShardLease("other", "b", testNow),
+			},
+			expectedMembers: []string{"a"},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			now := testNow
+			m := newTestMembership(&now, interceptor.Funcs{}, tc.leases...)
+			changes := m.Changes()
+
+			require.NoError(t, m.renew(context.Background()))
+			require.NoError(t, m.refreshMembers(context.Background()))
+			assert.Equal(t, tc.expectedMembers, m.members)
+			assert.Len(t, changes, 1)
+
+			for _, member := range tc.expectedMembers {
+				key := types.NamespacedName{Namespace: "default", Name: "svc-of-" + member}
+				assert.Equal(t, shard.Owner(tc.expectedMembers, key.String()) == "a", m.Owns(key))
+			}
+
+			<-changes
+			require.NoError(t, m.refreshMembers(context.Background()))
+			assert.Empty(t, changes, "unchanged members must not be notified")
+		})
+	}
+}
+
+func TestLeaseShardMembership_expiry(t *testing.T) {
+	t.Parallel()
+
+	renewErr := errors.New("apiserver is unavailable")
+	failing := false
+	now := testNow
+	m := newTestMembership(&now, interceptor.Funcs{
+		Update: func(ctx context.Context, cli client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+			if failing {
+				return renewErr
+			}
+			return cli.Update(ctx, obj, opts...)
+		},
+	})
+	key := types.NamespacedName{Namespace: "default", Name: "web"}
+	assert.False(t, m.Owns(key), "nothing is owned before the first renewal")
+
+	require.NoError(t, m.renew(context.Background()))
+	require.NoError(t, m.refreshMembers(context.Background()))
+	changes := m.Changes()
+	assert.True(t, m.Owns(key), "the only member owns every key")
+
+	failing = true
+	now = testNow.Add(testLeaseDuration - time.Second)
+	require.ErrorIs(t, m.renew(context.Background()), renewErr)
+	m.expireIfOverdue()
+	assert.True(t, m.Owns(key), "keys are owned until the lease duration passes")
+	assert.Empty(t, changes)
+
+	now = testNow.Add(testLeaseDuration)
+	assert.False(t, m.Owns(key), "nothing is owned once the lease duration passes")
+	require.ErrorIs(t, m.renew(context.Background()), renewErr)
+	m.expireIfOverdue()
+	assert.Empty(t, m.members)
+	assert.Len(t, changes, 1, "expiry is notified")
+
+	<-changes
+	failing = false
+	require.NoError(t, m.renew(context.Background()))
+	require.NoError(t, m.refreshMembers(context.Background()))
+	assert.True(t, m.Owns(key))
+	assert.Len(t, changes, 1, "recovery is notified")
+}
+
+func TestLeaseShardMembership_Changes(t *testing.T) {
+	t.Parallel()
+
+	now := testNow
+	m := newTestMembership(&now, interceptor.Funcs{})
+	first, second := m.Changes(), m.Changes()
+
+	require.NoError(t, m.renew(context.Background()))
+	require.NoError(t, m.refreshMembers(context.Background()))
+	assert.Len(t, first, 1)
+	assert.Len(t, second, 1, "every consumer is notified")
+}
+
+func TestLeaseDurationSeconds(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		duration time.Duration
+		expected int32
+	}{
+		{duration: 15 * time.Second, expected: 15},
+		{duration: 1500 * time.Millisecond, expected: 2},
+		{duration: 500 * time.Millisecond, expected: 1},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.duration.String(), func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, leaseDurationSeconds(tc.duration))
+		})
+	}
+}
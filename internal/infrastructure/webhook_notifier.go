@@ -0,0 +1,324 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/isac322/static-lb/internal/application"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// WebhookSignatureHeader carries "sha256=<hex HMAC-SHA256 of the body>" if a secret is configured.
+	WebhookSignatureHeader = "X-Static-LB-Signature"
+
+	webhookQueueFileSuffix = ".json"
+	webhookRequestTimeout  = 10 * time.Second
+	webhookBaseDelay       = time.Second
+	webhookMaxDelay        = 5 * time.Minute
+)
+
+// WebhookPayload is the JSON body POSTed on every change of IPs of a Service.
+type WebhookPayload struct {
+	Namespace string     `json:"namespace"`
+	Name      string     `json:"name"`
+	Old       WebhookIPs `json:"old"`
+	New       WebhookIPs `json:"new"`
+	Reason    string     `json:"reason"`
+	Time      time.Time  `json:"time"`
+}
+
+type WebhookIPs struct {
+	IngressIPs  []string `json:"ingressIPs"`
+	ExternalIPs []string `json:"externalIPs"`
+}
+
+// webhookDelivery is a queued POST of a payload to a URL, stored as a file until it is delivered or given up.
+type webhookDelivery struct {
+	URL           string          `json:"url"`
+	Payload       json.RawMessage `json:"payload"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"nextAttemptAt"`
+}
+
+// WebhookNotifier POSTs changes of IPs to URLs. Deliveries are queued as files in a directory per URL, so that they
+// survive restarts, and retried with exponential backoff. Each URL has its own worker, so that a slow or failing URL
+// does not delay the others, and deliveries to a URL are kept in order.
+type WebhookNotifier struct {
+	queues      []*webhookQueue
+	secret      []byte
+	maxAttempts int
+	httpClient  *http.Client
+	seq         atomic.Uint64
+}
+
+// webhookQueue is the directory of deliveries to a URL.
+type webhookQueue struct {
+	url  string
+	dir  string
+	wake chan struct{}
+}
+
+// NewWebhookNotifier creates a notifier that queues deliveries in queueDir, which must be writable. Bodies are
+// signed with secret unless it is empty. A delivery is dropped after maxAttempts failures, or retried forever if
+// maxAttempts is not positive.
+func NewWebhookNotifier(urls []string, secret []byte, queueDir string, maxAttempts int) (*WebhookNotifier, error) {
+	queues := make([]*webhookQueue, 0, len(urls))
+	for _, url := range urls {
+		queue := &webhookQueue{url: url, dir: filepath.Join(queueDir, webhookQueueName(url)), wake: make(chan struct{}, 1)}
+		if err := os.MkdirAll(queue.dir, 0o700); err != nil {
+			return nil, err
+		}
+		if err := checkWritable(queue.dir); err != nil {
+			return nil, err
+		}
+		queues = append(queues, queue)
+	}
+	return &WebhookNotifier{
+		queues:      queues,
+		secret:      secret,
+		maxAttempts: maxAttempts,
+		httpClient:  &http.Client{Timeout: webhookRequestTimeout},
+	}, nil
+}
+
+// webhookQueueName names the directory of url, which is stable across restarts.
+func webhookQueueName(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:8])
+}
+
+// checkWritable fails if files cannot be created in dir, which MkdirAll does not tell for an existing one.
+func checkWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".probe-*")
+	if err != nil {
+		return fmt.Errorf("webhook queue directory is not writable: %w", err)
+	}
+	_ = f.Close()
+	return os.Remove(f.Name())
+}
+
+// IPsAssigned implements application.AssignmentHook by queueing a delivery for each URL.
+func (n *WebhookNotifier) IPsAssigned(_ context.Context, change application.IPChange) error {
+	payload, err := json.Marshal(WebhookPayload{
+		Namespace: change.Service.Namespace,
+		Name:      change.Service.Name,
+		Old:       WebhookIPs{IngressIPs: change.Old.IngressIPs, ExternalIPs: change.Old.ExternalIPs},
+		New:       WebhookIPs{IngressIPs: change.New.IngressIPs, ExternalIPs: change.New.ExternalIPs},
+		Reason:    change.Reason,
+		Time:      time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, queue := range n.queues {
+		name := fmt.Sprintf("%020d-%010d%s", time.Now().UnixNano(), n.seq.Add(1), webhookQueueFileSuffix)
+		if err = queue.store(name, webhookDelivery{URL: queue.url, Payload: payload}); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		select {
+		case queue.wake <- struct{}{}:
+		default:
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, so that deliveries queued before a restart are
+// sent even if this replica is not the leader.
+func (n *WebhookNotifier) NeedLeaderElection() bool {
+	return false
+}
+
+// Start delivers queued payloads, with a worker per URL, until ctx is done.
+func (n *WebhookNotifier) Start(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, queue := range n.queues {
+		wg.Add(1)
+		go func(queue *webhookQueue) {
+			defer wg.Done()
+			n.run(ctx, queue)
+		}(queue)
+	}
+	wg.Wait()
+	return nil
+}
+
+// run delivers payloads of queue until ctx is done.
+func (n *WebhookNotifier) run(ctx context.Context, queue *webhookQueue) {
+	logger := log.FromContext(ctx).WithName("webhook")
+
+	for {
+		nextAttemptAt, err := n.deliverDue(ctx, queue)
+		if err != nil {
+			logger.Error(err, "unable to process webhook queue", "url", queue.url, "dir", queue.dir)
+		}
+
+		wait := webhookMaxDelay
+		if !nextAttemptAt.IsZero() {
+			wait = time.Until(nextAttemptAt)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-queue.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// deliverDue sends queued deliveries of queue, oldest first, until one is not due or fails, and returns when it
+// is due. Later deliveries wait for it, to keep them in order.
+func (n *WebhookNotifier) deliverDue(ctx context.Context, queue *webhookQueue) (nextAttemptAt time.Time, err error) {
+	logger := log.FromContext(ctx).WithName("webhook")
+
+	entries, err := os.ReadDir(queue.dir)
+	if err != nil {
+		return time.Time{}, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), webhookQueueFileSuffix) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if ctx.Err() != nil {
+			return time.Time{}, nil
+		}
+
+		delivery, err := queue.load(name)
+		if err != nil {
+			logger.Error(err, "dropping unreadable webhook delivery", "file", name)
+			_ = os.Remove(filepath.Join(queue.dir, name))
+			continue
+		}
+		if time.Now().Before(delivery.NextAttemptAt) {
+			return delivery.NextAttemptAt, nil
+		}
+
+		if err = n.post(ctx, delivery); err == nil {
+			if err = os.Remove(filepath.Join(queue.dir, name)); err != nil {
+				return time.Time{}, err
+			}
+			continue
+		}
+
+		delivery.Attempts++
+		if n.maxAttempts > 0 && delivery.Attempts >= n.maxAttempts {
+			logger.Error(err, "giving up webhook delivery", "url", delivery.URL, "attempts", delivery.Attempts)
+			if err = os.Remove(filepath.Join(queue.dir, name)); err != nil {
+				return time.Time{}, err
+			}
+			continue
+		}
+
+		logger.Info("webhook delivery failed, will retry", "url", delivery.URL, "error", err.Error())
+		delivery.NextAttemptAt = time.Now().Add(webhookBackoff(delivery.Attempts))
+		if err = queue.store(name, delivery); err != nil {
+			return time.Time{}, err
+		}
+		return delivery.NextAttemptAt, nil
+	}
+	return time.Time{}, nil
+}
+
+func (n *WebhookNotifier) post(ctx context.Context, delivery webhookDelivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(n.secret) != 0 {
+		req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhookPayload(n.secret, delivery.Payload))
+	}
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// store writes delivery to the file name atomically, so that a crash never leaves a partial file.
+func (q *webhookQueue) store(name string, delivery webhookDelivery) error {
+	data, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(q.dir, name+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(q.dir, name))
+}
+
+func (q *webhookQueue) load(name string) (delivery webhookDelivery, err error) {
+	data, err := os.ReadFile(filepath.Join(q.dir, name))
+	if err != nil {
+		return webhookDelivery{}, err
+	}
+	err = json.Unmarshal(data, &delivery)
+	return delivery, err
+}
+
+// SignWebhookPayload returns the hex encoded HMAC-SHA256 of body, which receivers compare with
+// WebhookSignatureHeader.
+func SignWebhookPayload(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func webhookBackoff(attempts int) time.Duration {
+	delay := webhookBaseDelay
+	for i := 1; i < attempts && delay < webhookMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > webhookMaxDelay {
+		return webhookMaxDelay
+	}
+	return delay
+}
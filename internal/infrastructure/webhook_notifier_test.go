@@ -0,0 +1,312 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/isac322/static-lb/internal/application"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookReceiver records payloads POSTed to it, and fails requests while failing is set.
+type webhookReceiver struct {
+	mu         sync.Mutex
+	failing    bool
+	names      []string
+	signatures []string
+	attempts   int
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts++
+	if r.failing {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	var payload WebhookPayload
+	_ = json.Unmarshal(body, &payload)
+	r.names = append(r.names, payload.Name)
+	r.signatures = append(r.signatures, req.Header.Get(WebhookSignatureHeader))
+}
+
+func (r *webhookReceiver) setFailing(failing bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failing = failing
+}
+
+func newWebhookReceiver(t *testing.T, failing bool) (*webhookReceiver, string) {
+	t.Helper()
+
+	receiver := &webhookReceiver{failing: failing}
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+	return receiver, server.URL
+}
+
+func assignIPs(t *testing.T, n *WebhookNotifier, names ...string) {
+	t.Helper()
+
+	for _, name := range names {
+		require.NoError(t, n.IPsAssigned(context.Background(), application.IPChange{
+			Service: types.NamespacedName{Namespace: "default", Name: name},
+			New:     application.IPStatus{IngressIPs: []string{"10.0.0.1"}},
+		}))
+	}
+}
+
+// makeDue makes every queued delivery due now, instead of waiting for its backoff.
+func makeDue(t *testing.T, n *WebhookNotifier) {
+	t.Helper()
+
+	for _, queue := range n.queues {
+		entries, err := os.ReadDir(queue.dir)
+		require.NoError(t, err)
+		for _, entry := range entries {
+			delivery, err := queue.load(entry.Name())
+			require.NoError(t, err)
+			delivery.NextAttemptAt = time.Time{}
+			require.NoError(t, queue.store(entry.Name(), delivery))
+		}
+	}
+}
+
+// deliverDue runs a pass of every worker of n.
+func deliverDue(t *testing.T, n *WebhookNotifier) {
+	t.Helper()
+
+	for _, queue := range n.queues {
+		_, err := n.deliverDue(context.Background(), queue)
+		require.NoError(t, err)
+	}
+}
+
+func queueLen(t *testing.T, n *WebhookNotifier) int {
+	t.Helper()
+
+	var total int
+	for _, queue := range n.queues {
+		entries, err := os.ReadDir(queue.dir)
+		require.NoError(t, err)
+		total += len(entries)
+	}
+	return total
+}
+
+func mustOnlyQueued(t *testing.T, queue *webhookQueue) string {
+	t.Helper()
+
+	entries, err := os.ReadDir(queue.dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	return entries[0].Name()
+}
+
+func TestSignWebhookPayload(t *testing.T) {
+	t.Parallel()
+
+	// HMAC-SHA256 of RFC 4231 test case 2
+	assert.Equal(
+		t,
+		"5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843",
+		SignWebhookPayload([]byte("Jefe"), []byte("what do ya want for nothing?")),
+	)
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{attempts: 1, expected: time.Second},
+		{attempts: 2, expected: 2 * time.Second},
+		{attempts: 5, expected: 16 * time.Second},
+		{attempts: 9, expected: 256 * time.Second},
+		{attempts: 10, expected: webhookMaxDelay},
+		{attempts: 100, expected: webhookMaxDelay},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(fmt.Sprintf("attempts=%d", tc.attempts), func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, webhookBackoff(tc.attempts))
+		})
+	}
+}
+
+func TestWebhookNotifier_signature(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		secret   []byte
+		expected bool
+	}{
+		{
+			name:     "signed",
+			secret:   []byte("secret"),
+			expected: true,
+		},
+		{
+			name: "unsigned",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			receiver, url := newWebhookReceiver(t, false)
+			n, err := NewWebhookNotifier([]string{url}, tc.secret, t.TempDir(), 0)
+			require.NoError(t, err)
+			assignIPs(t, n, "web")
+
+			payload, err := n.queues[0].load(mustOnlyQueued(t, n.queues[0]))
+			require.NoError(t, err)
+			deliverDue(t, n)
+
+			require.Len(t, receiver.signatures, 1)
+			if tc.expected {
+				assert.Equal(t, "sha256="+SignWebhookPayload(tc.secret, payload.Payload), receiver.signatures[0])
+			} else {
+				assert.Empty(t, receiver.signatures[0])
+			}
+			assert.Zero(t, queueLen(t, n))
+		})
+	}
+}
+
+func TestWebhookNotifier_order(t *testing.T) {
+	t.Parallel()
+
+	failingReceiver, failingURL := newWebhookReceiver(t, true)
+	healthyReceiver, healthyURL := newWebhookReceiver(t, false)
+	n, err := NewWebhookNotifier([]string{failingURL, healthyURL}, nil, t.TempDir(), 0)
+	require.NoError(t, err)
+	assignIPs(t, n, "first", "second", "third")
+
+	nextAttemptAt, err := n.deliverDue(context.Background(), n.queues[0])
+	require.NoError(t, err)
+	_, err = n.deliverDue(context.Background(), n.queues[1])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first", "second", "third"}, healthyReceiver.names)
+	assert.Equal(t, 1, failingReceiver.attempts, "later deliveries wait for the failed one of the same URL")
+	assert.Equal(t, 3, queueLen(t, n))
+	assert.WithinDuration(t, time.Now().Add(webhookBaseDelay), nextAttemptAt, webhookBaseDelay)
+
+	deliverDue(t, n)
+	assert.Equal(t, 1, failingReceiver.attempts, "a delivery is not retried before its backoff")
+
+	failingReceiver.setFailing(false)
+	makeDue(t, n)
+	deliverDue(t, n)
+	assert.Equal(t, []string{"first", "second", "third"}, failingReceiver.names)
+	assert.Zero(t, queueLen(t, n))
+}
+
+func TestWebhookNotifier_maxAttempts(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name             string
+		maxAttempts      int
+		expectedQueue    []int
+		expectedAttempts int
+	}{
+		{
+			name:             "dropped after max attempts",
+			maxAttempts:      2,
+			expectedQueue:    []int{1, 0, 0},
+			expectedAttempts: 2,
+		},
+		{
+			name:             "retried forever",
+			maxAttempts:      0,
+			expectedQueue:    []int{1, 1, 1},
+			expectedAttempts: 3,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			receiver, url := newWebhookReceiver(t, true)
+			n, err := NewWebhookNotifier([]string{url}, nil, t.TempDir(), tc.maxAttempts)
+			require.NoError(t, err)
+			assignIPs(t, n, "web")
+
+			for i, expected := range tc.expectedQueue {
+				deliverDue(t, n)
+				assert.Equal(t, expected, queueLen(t, n), "after attempt %d", i+1)
+				makeDue(t, n)
+			}
+			assert.Equal(t, tc.expectedAttempts, receiver.attempts)
+		})
+	}
+}
+
+func TestNewWebhookNotifier_unwritableQueueDir(t *testing.T) {
+	t.Parallel()
+	if os.Geteuid() == 0 {
+		t.Skip("permissions are not enforced for root")
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, os.Chmod(dir, 0o500))
+	t.Cleanup(func() { _ = os.Chmod(dir, 0o700) })
+
+	_, err := NewWebhookNotifier([]string{"http://example.com"}, nil, dir, 0)
+	assert.Error(t, err)
+}
+
+func TestWebhookNotifier_Start_workerPerURL(t *testing.T) {
+	t.Parallel()
+
+	queueDir := t.TempDir()
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { <-release }))
+	t.Cleanup(slow.Close)
+	t.Cleanup(func() { close(release) })
+	fastReceiver, fastURL := newWebhookReceiver(t, false)
+
+	n, err := NewWebhookNotifier([]string{slow.URL, fastURL}, nil, queueDir, 0)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = n.Start(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	assignIPs(t, n, "first", "second")
+	assert.Eventually(t, func() bool {
+		fastReceiver.mu.Lock()
+		defer fastReceiver.mu.Unlock()
+		return len(fastReceiver.names) == 2
+	}, 5*time.Second, 10*time.Millisecond, "a slow URL does not delay deliveries to the others")
+}
@@ -0,0 +1,40 @@
+package shard
+
+import (
+	"hash/fnv"
+)
+
+// Owner returns the member that owns key by rendezvous hashing, so that only keys of a leaving member,
+// or about 1/len(members) of keys for a joining member, move on membership changes.
+// It returns an empty string if there is no member.
+func Owner(members []string, key string) string {
+	var (
+		owner     string
+		maxWeight uint64
+	)
+	for _, member := range members {
+		weight := weightOf(member, key)
+		if owner == "" || weight > maxWeight || (weight == maxWeight && member < owner) {
+			owner, maxWeight = member, weight
+		}
+	}
+	return owner
+}
+
+func weightOf(member, key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(member))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(key))
+	return mix(h.Sum64())
+}
+
+// mix spreads bits of FNV hashes that differ only in a few bytes, as in the finalizer of SplitMix64.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,118 @@
+package shard
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOwner(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		members []string
+	}{
+		{
+			name:    "single member",
+			members: []string{"a"},
+		},
+		{
+			name:    "several members",
+			members: []string{"a", "b", "c"},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			reversed := make([]string, len(tc.members))
+			for i, member := range tc.members {
+				reversed[len(tc.members)-1-i] = member
+			}
+
+			for _, key := range keys(100) {
+				owner := Owner(tc.members, key)
+				assert.Contains(t, tc.members, owner)
+				assert.Equal(t, owner, Owner(reversed, key), "owner must not depend on order of members")
+			}
+		})
+	}
+}
+
+func TestOwner_noMember(t *testing.T) {
+	t.Parallel()
+
+	assert.Empty(t, Owner(nil, "default/web"))
+}
+
+func TestOwner_membershipChanges(t *testing.T) {
+	t.Parallel()
+
+	const keyCount = 10000
+	before := []string{"a", "b", "c", "d"}
+
+	tests := []struct {
+		name  string
+		after []string
+		// keys of these members of before may move, and no other key may
+		movable []string
+		// bounds of the fraction of keys that move
+		minMoved float64
+		maxMoved float64
+	}{
+		{
+			name:     "member joins",
+			after:    []string{"a", "b", "c", "d", "e"},
+			movable:  []string{"a", "b", "c", "d"},
+			minMoved: 0.15,
+			maxMoved: 0.25,
+		},
+		{
+			name:     "member leaves",
+			after:    []string{"a", "b", "d"},
+			movable:  []string{"c"},
+			minMoved: 0.2,
+			maxMoved: 0.3,
+		},
+		{
+			name:     "no change",
+			after:    []string{"a", "b", "c", "d"},
+			minMoved: 0,
+			maxMoved: 0,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			moved := 0
+			for _, key := range keys(keyCount) {
+				oldOwner, newOwner := Owner(before, key), Owner(tc.after, key)
+				if oldOwner == newOwner {
+					continue
+				}
+				moved++
+				assert.Contains(t, tc.movable, oldOwner, "key %s must not move", key)
+				if len(tc.after) > len(before) {
+					assert.Equal(t, "e", newOwner, "keys move only to the joining member")
+				}
+			}
+
+			fraction := float64(moved) / keyCount
+			assert.GreaterOrEqual(t, fraction, tc.minMoved)
+			assert.LessOrEqual(t, fraction, tc.maxMoved)
+		})
+	}
+}
+
+func keys(n int) []string {
+	result := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		result = append(result, fmt.Sprintf("default/svc-%d", i))
+	}
+	return result
+}
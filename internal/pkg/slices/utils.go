@@ -16,3 +16,16 @@ func Match[T comparable](s1, s2 []T) bool {
 
 	return len(set) == 0
 }
+
+// Equal reports whether s1 and s2 have the same elements in the same order.
+func Equal[T comparable](s1, s2 []T) bool {
+	if len(s1) != len(s2) {
+		return false
+	}
+	for i := range s1 {
+		if s1[i] != s2[i] {
+			return false
+		}
+	}
+	return true
+}
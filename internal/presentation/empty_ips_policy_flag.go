@@ -0,0 +1,31 @@
+package presentation
+
+import (
+	"github.com/isac322/static-lb/internal/application"
+)
+
+type EmptyIPsPolicyFlag struct {
+	value application.EmptyIPsPolicy
+}
+
+func NewEmptyIPsPolicyFlag(defaultVal application.EmptyIPsPolicy) EmptyIPsPolicyFlag {
+	return EmptyIPsPolicyFlag{value: defaultVal}
+}
+
+func (f *EmptyIPsPolicyFlag) String() string {
+	return f.value.String()
+}
+
+func (f *EmptyIPsPolicyFlag) Policy() application.EmptyIPsPolicy {
+	return f.value
+}
+
+func (f *EmptyIPsPolicyFlag) Set(s string) error {
+	policy, err := application.ParseEmptyIPsPolicy(s)
+	if err != nil {
+		return err
+	}
+
+	f.value = policy
+	return nil
+}
@@ -1,30 +1,36 @@
 package presentation
 
 import (
-	"fmt"
 	"net"
 	"strings"
+
+	"github.com/isac322/static-lb/pkg/staticlb"
 )
 
-type IPNetFilterFlag []*net.IPNet
+// IPNetFilterFlag collects CIDRs and address classes, possibly negated, from every occurrence of the flag, so that
+// a negated entry applies regardless of its position. The flag is rejected once negated entries exclude every IP
+// network.
+type IPNetFilterFlag struct {
+	entries []string
+	ipNets  []*net.IPNet
+}
 
 func (f *IPNetFilterFlag) String() string {
-	var ss []string
-	for _, ipNet := range *f {
-		ss = append(ss, ipNet.String())
-	}
-	return strings.Join(ss, ",")
+	return strings.Join(f.entries, ",")
+}
+
+func (f *IPNetFilterFlag) IPNets() []*net.IPNet {
+	return f.ipNets
 }
 
 func (f *IPNetFilterFlag) Set(s string) error {
-	_, ipNet, err := net.ParseCIDR(s)
+	entries := append(append([]string{}, f.entries...), strings.Split(s, ",")...)
+	ipNets, err := staticlb.ParseIPNets(entries)
 	if err != nil {
 		return err
 	}
-	if ipNet == nil {
-		return fmt.Errorf("invalid IP network: %s", s)
-	}
 
-	*f = append(*f, ipNet)
+	f.entries = entries
+	f.ipNets = ipNets
 	return nil
 }
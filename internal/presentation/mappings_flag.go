@@ -2,8 +2,10 @@ package presentation
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/isac322/static-lb/internal/application"
+	"github.com/isac322/static-lb/pkg/staticlb"
 )
 
 type IPMappingTargets struct {
@@ -27,3 +29,49 @@ func (f *IPMappingTargets) Set(s string) error {
 		return fmt.Errorf("invalid mapping target: %s", s)
 	}
 }
+
+type IPMappingPriorities []application.IPMappingPriority
+
+func (f *IPMappingPriorities) String() string {
+	var ss []string
+	for _, priority := range *f {
+		ss = append(ss, priority.String())
+	}
+	return strings.Join(ss, ",")
+}
+
+func (f *IPMappingPriorities) Set(s string) error {
+	priority, err := staticlb.ParseIPMappingPriority(s)
+	if err != nil {
+		return err
+	}
+
+	*f = append(*f, priority)
+	return nil
+}
+
+type NodeAddressSelectionFlag struct {
+	value application.NodeAddressSelection
+}
+
+func NewNodeAddressSelectionFlag(defaultVal application.NodeAddressSelection) NodeAddressSelectionFlag {
+	return NodeAddressSelectionFlag{value: defaultVal}
+}
+
+func (f *NodeAddressSelectionFlag) String() string {
+	return string(f.value)
+}
+
+func (f *NodeAddressSelectionFlag) Selection() application.NodeAddressSelection {
+	return f.value
+}
+
+func (f *NodeAddressSelectionFlag) Set(s string) error {
+	selection, err := staticlb.ParseNodeAddressSelection(s)
+	if err != nil {
+		return err
+	}
+
+	f.value = selection
+	return nil
+}
@@ -0,0 +1,52 @@
+package presentation
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"github.com/isac322/static-lb/internal/application"
+	"github.com/isac322/static-lb/pkg/staticlb"
+)
+
+type NATRulesFlag []application.NATRule
+
+func (f *NATRulesFlag) String() string {
+	var ss []string
+	for _, rule := range *f {
+		ss = append(ss, rule.String())
+	}
+	return strings.Join(ss, ",")
+}
+
+func (f *NATRulesFlag) Set(s string) error {
+	rule, err := staticlb.ParseNATRule(s)
+	if err != nil {
+		return err
+	}
+
+	*f = append(*f, rule)
+	return nil
+}
+
+// LoadFile appends rules in the file at path. Each line has a rule, and empty lines or lines starting with # are
+// ignored.
+func (f *NATRulesFlag) LoadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err = f.Set(line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,31 @@
+package presentation
+
+import (
+	"github.com/isac322/static-lb/internal/application"
+)
+
+type NoNodePortsModeFlag struct {
+	value application.NoNodePortsMode
+}
+
+func NewNoNodePortsModeFlag(defaultVal application.NoNodePortsMode) NoNodePortsModeFlag {
+	return NoNodePortsModeFlag{value: defaultVal}
+}
+
+func (f *NoNodePortsModeFlag) String() string {
+	return string(f.value)
+}
+
+func (f *NoNodePortsModeFlag) Mode() application.NoNodePortsMode {
+	return f.value
+}
+
+func (f *NoNodePortsModeFlag) Set(s string) error {
+	mode, err := application.ParseNoNodePortsMode(s)
+	if err != nil {
+		return err
+	}
+
+	f.value = mode
+	return nil
+}
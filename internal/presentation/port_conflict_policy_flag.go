@@ -0,0 +1,31 @@
+package presentation
+
+import (
+	"github.com/isac322/static-lb/internal/application"
+)
+
+type PortConflictPolicyFlag struct {
+	value application.PortConflictPolicy
+}
+
+func NewPortConflictPolicyFlag(defaultVal application.PortConflictPolicy) PortConflictPolicyFlag {
+	return PortConflictPolicyFlag{value: defaultVal}
+}
+
+func (f *PortConflictPolicyFlag) String() string {
+	return string(f.value)
+}
+
+func (f *PortConflictPolicyFlag) Policy() application.PortConflictPolicy {
+	return f.value
+}
+
+func (f *PortConflictPolicyFlag) Set(s string) error {
+	policy, err := application.ParsePortConflictPolicy(s)
+	if err != nil {
+		return err
+	}
+
+	f.value = policy
+	return nil
+}
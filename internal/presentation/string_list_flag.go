@@ -0,0 +1,16 @@
+package presentation
+
+import (
+	"strings"
+)
+
+type StringListFlag []string
+
+func (f *StringListFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *StringListFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
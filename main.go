@@ -18,24 +18,41 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	"go.opentelemetry.io/otel"
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
 
 	"github.com/isac322/static-lb/controllers"
 	"github.com/isac322/static-lb/internal/application"
 	"github.com/isac322/static-lb/internal/infrastructure"
 	"github.com/isac322/static-lb/internal/presentation"
+	"github.com/isac322/static-lb/pkg/staticlb"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -46,20 +63,65 @@ var (
 
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(gatewayv1.AddToScheme(scheme))
+	utilruntime.Must(gatewayv1beta1.AddToScheme(scheme))
 
 	//+kubebuilder:scaffold:scheme
 }
 
+// ipNetFilterUsage explains entries that IP network filter flags accept.
+const ipNetFilterUsage = "Comma-separated, and can be repeated. Each entry is a CIDR or an address class " +
+	"(private, public, cgnat, link-local, ula, loopback, ipv4, ipv6), negated with a leading \"!\"."
+
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
 	var internalIPMappings presentation.IPMappingTargets
 	var externalIPMappings presentation.IPMappingTargets
+	var ipMappingPriorities presentation.IPMappingPriorities
+	nodeAddressSelection := presentation.NewNodeAddressSelectionFlag(application.NodeAddressSelectionAll)
 	var includeIngressIPFilter presentation.IPNetFilterFlag
 	var includeExternalIPFilter presentation.IPNetFilterFlag
 	var excludeIngressIPFilter presentation.IPNetFilterFlag
 	var excludeExternalIPFilter presentation.IPNetFilterFlag
+	var gatewayClassName string
+	var ingressClasses presentation.StringListFlag
+	var ingressControllerNamespace string
+	var ingressControllerPodSelector string
+	var natRules presentation.NATRulesFlag
+	var natRulesFile string
+	var dropUntranslatedIPs bool
+	var excludeClusterNetworks bool
+	var serviceCIDRs presentation.IPNetFilterFlag
+	var zones presentation.StringListFlag
+	var regions presentation.StringListFlag
+	var maxIPsPerZone int
+	var resyncPeriod time.Duration
+	var ipAddDelay time.Duration
+	var ipRemoveDelay time.Duration
+	noNodePortsMode := presentation.NewNoNodePortsModeFlag(application.NoNodePortsModePublish)
+	var refuseNodePortConflicts bool
+	portConflictPolicy := presentation.NewPortConflictPolicyFlag(application.PortConflictPolicyWarn)
+	var reconcileOpts controllers.ReconcileOptions
+	var serviceWriteQPS float64
+	var serviceWriteBurst int
+	var instanceName string
+	var inventoryConfigMap string
+	var webhookURLs presentation.StringListFlag
+	var webhookSecretFile string
+	var webhookQueueDir string
+	var webhookMaxAttempts int
+	var otlpEndpoint string
+	var otlpInsecure bool
+	var traceSampleRatio float64
+	var shardGroup string
+	var shardLeaseNamespace string
+	var shardIdentity string
+	var shardLeaseDuration time.Duration
+	emptyIPsPolicy := presentation.NewEmptyIPsPolicyFlag(
+		application.EmptyIPsPolicy{Mode: application.EmptyIPsModeClear},
+	)
 
 	flag.StringVar(
 		&metricsAddr,
@@ -83,32 +145,291 @@ func main() {
 	flag.Var(
 		&internalIPMappings,
 		"internal-ip-mapping",
-		"where to assign node's internal ips (enum: ingress, external).",
+		"where to assign node's internal ips (enum: ingress, external). "+
+			"Deprecated: use --ip-mapping=<target>=internal instead.",
 	)
 	flag.Var(
 		&externalIPMappings,
 		"external-ip-mapping",
-		"where to assign node's external ips (enum: ingress, external).",
+		"where to assign node's external ips (enum: ingress, external). "+
+			"Deprecated: use --ip-mapping=<target>=external instead.",
+	)
+	flag.Var(
+		&ipMappingPriorities,
+		"ip-mapping",
+		"where to assign each node's ips by priority of address types, "+
+			"e.g. ingress=external|internal assigns external ips of each node, or internal ips if it has none. "+
+			"Address types are names of address sources: internal, external, hostname, "+
+			"annotation (the "+staticlb.LabelNodeAddresses+" annotation of nodes) and compiled-in ones.",
+	)
+	flag.Var(
+		&nodeAddressSelection,
+		"node-address-selection",
+		"which addresses of a type each node contributes (enum: all, first-per-family).",
 	)
 	flag.Var(
 		&includeIngressIPFilter,
 		"include-ingress-ip-net",
-		"IP networks that filters Ingress IP candidates before assign. (default: empty)",
+		"IP networks that filters Ingress IP candidates before assign. "+
+			ipNetFilterUsage+" (default: empty)",
 	)
 	flag.Var(
 		&includeExternalIPFilter,
 		"include-external-ip-net",
-		"IP networks that filters External IP candidates before assign. (default: empty)",
+		"IP networks that filters External IP candidates before assign. "+
+			ipNetFilterUsage+" (default: empty)",
 	)
 	flag.Var(
 		&excludeIngressIPFilter,
 		"exclude-ingress-ip-net",
-		"IP networks that filters Ingress IP candidates out before assign. (default: empty)",
+		"IP networks that filters Ingress IP candidates out before assign. "+
+			ipNetFilterUsage+" (default: empty)",
 	)
 	flag.Var(
 		&excludeExternalIPFilter,
 		"exclude-external-ip-net",
-		"IP networks that filters External IP candidates out before assign. (default: empty)",
+		"IP networks that filters External IP candidates out before assign. "+
+			ipNetFilterUsage+" (default: empty)",
+	)
+	flag.Var(
+		&natRules,
+		"nat-rule",
+		"1:1 NAT rule that translates candidate IPs before filtering (e.g. 10.0.1.0/24=203.0.113.0/24 or "+
+			"10.0.1.1=203.0.113.1). (default: empty)",
+	)
+	flag.StringVar(
+		&natRulesFile,
+		"nat-rules-file",
+		"",
+		"file that has a NAT rule per line, in addition to --nat-rule.",
+	)
+	flag.BoolVar(
+		&dropUntranslatedIPs,
+		"drop-untranslated-ips",
+		false,
+		"drop candidate IPs that no NAT rule matches.",
+	)
+	flag.BoolVar(
+		&excludeClusterNetworks,
+		"exclude-cluster-networks",
+		false,
+		"exclude pod CIDRs of nodes, the Service CIDR, and loopback and link-local ranges from candidate ips "+
+			"before --include-* and --exclude-* filters.",
+	)
+	flag.Var(
+		&serviceCIDRs,
+		"service-cidr",
+		"Service CIDR that --exclude-cluster-networks excludes. Can be repeated. "+
+			"(default: only ClusterIPs of the kubernetes Service)",
+	)
+	flag.Var(
+		&zones,
+		"zone",
+		"topology zone whose nodes' ips are published. Can be repeated. (default: every zone)",
+	)
+	flag.Var(
+		&regions,
+		"region",
+		"topology region whose nodes' ips are published. Can be repeated. (default: every region)",
+	)
+	flag.IntVar(
+		&maxIPsPerZone,
+		"max-ips-per-zone",
+		0,
+		"maximum number of ips to publish from each zone, or every ip of a zone that has fewer. "+
+			"Every ip is published if 0.",
+	)
+	flag.Var(
+		&emptyIPsPolicy,
+		"empty-ips-policy",
+		"what to do with assigned IPs when no candidate remains "+
+			"(enum: clear, keep-last, hold-for=<duration>).",
+	)
+	flag.DurationVar(
+		&ipAddDelay,
+		"ip-add-delay",
+		0,
+		"how long a node IP has to be continuously eligible before it is assigned.",
+	)
+	flag.DurationVar(
+		&ipRemoveDelay,
+		"ip-remove-delay",
+		0,
+		"how long an assigned node IP has to be continuously ineligible before it is removed.",
+	)
+	flag.Var(
+		&noNodePortsMode,
+		"no-node-ports-policy",
+		"how to publish ips of LoadBalancer Services without node ports (allocateLoadBalancerNodePorts: false) "+
+			"(enum: publish, external-only, skip).",
+	)
+	flag.BoolVar(
+		&refuseNodePortConflicts,
+		"refuse-node-port-conflicts",
+		false,
+		"drop external ips of nodes whose ports are node ports of other Services, instead of only warning. "+
+			"Overridden by the "+application.LabelNodePortConflictPolicy+" annotation (enum: warn, exclude).",
+	)
+	flag.Var(
+		&portConflictPolicy,
+		"port-conflict-policy",
+		"what to do with ips on which an older Service publishes the same ports, unless both Services have "+
+			"the same "+application.LabelSharingKey+" annotation (enum: warn, exclude).",
+	)
+	flag.StringVar(
+		&gatewayClassName,
+		"gateway-class-name",
+		"",
+		"GatewayClass whose Gateways get node addresses assigned. Gateways are ignored if empty.",
+	)
+	flag.DurationVar(
+		&resyncPeriod,
+		"resync-period",
+		0,
+		"interval to reconcile every LoadBalancer Service even if nothing changes. Disabled if zero.",
+	)
+	flag.Var(
+		&ingressClasses,
+		"ingress-class",
+		"IngressClass whose Ingresses get IPs of nodes running the ingress controller. "+
+			"Ingresses are ignored if empty.",
+	)
+	flag.StringVar(
+		&ingressControllerNamespace,
+		"ingress-controller-namespace",
+		"",
+		"namespace of the ingress controller Pods. (default: every namespace)",
+	)
+	flag.StringVar(
+		&ingressControllerPodSelector,
+		"ingress-controller-pod-selector",
+		"",
+		"label selector of the ingress controller Pods (e.g. app.kubernetes.io/name=ingress-nginx).",
+	)
+	flag.IntVar(
+		&reconcileOpts.MaxConcurrentReconciles,
+		"max-concurrent-reconciles",
+		1,
+		"number of reconciles that each controller runs at once.",
+	)
+	flag.DurationVar(
+		&reconcileOpts.BaseDelay,
+		"rate-limiter-base-delay",
+		5*time.Millisecond,
+		"initial delay to retry a failed reconcile, doubled on each failure of the same object.",
+	)
+	flag.DurationVar(
+		&reconcileOpts.MaxDelay,
+		"rate-limiter-max-delay",
+		1000*time.Second,
+		"maximum delay to retry a failed reconcile of an object.",
+	)
+	flag.Float64Var(
+		&reconcileOpts.QPS,
+		"rate-limiter-qps",
+		10,
+		"overall reconciles per second that each controller enqueues for retry. Unlimited if zero.",
+	)
+	flag.IntVar(
+		&reconcileOpts.Burst,
+		"rate-limiter-burst",
+		100,
+		"burst of --rate-limiter-qps.",
+	)
+	flag.Float64Var(
+		&serviceWriteQPS,
+		"service-write-qps",
+		0,
+		"maximum writes per second to Services across every reconcile. Unlimited if zero.",
+	)
+	flag.IntVar(
+		&serviceWriteBurst,
+		"service-write-burst",
+		10,
+		"burst of --service-write-qps. Must be positive if --service-write-qps is set.",
+	)
+	flag.StringVar(
+		&instanceName,
+		"instance-name",
+		"",
+		"name of this static-lb instance, to run several independent instances in a cluster. "+
+			"The instance handles Services whose static-lb.bhyoo.com/instance annotation is the name "+
+			"and reads annotations prefixed with <name>.static-lb.bhyoo.com/ instead of static-lb.bhyoo.com/. "+
+			"(default: handles Services without the annotation)",
+	)
+	flag.StringVar(
+		&inventoryConfigMap,
+		"inventory-configmap",
+		"",
+		"namespace/name of the ConfigMap that lists IPs, ports and nodes of every managed Service. Disabled if empty.",
+	)
+	flag.Var(
+		&webhookURLs,
+		"webhook-url",
+		"URL that receives a JSON payload by POST whenever IPs of a Service are changed. (default: empty)",
+	)
+	flag.StringVar(
+		&webhookSecretFile,
+		"webhook-secret-file",
+		"",
+		"file that has the secret to sign webhook payloads with HMAC-SHA256 in the X-Static-LB-Signature header.",
+	)
+	flag.StringVar(
+		&webhookQueueDir,
+		"webhook-queue-dir",
+		"/var/lib/static-lb/webhook-queue",
+		"writable directory to keep undelivered webhook payloads across restarts, in a subdirectory per URL. "+
+			"Startup fails if it is not writable.",
+	)
+	flag.IntVar(
+		&webhookMaxAttempts,
+		"webhook-max-attempts",
+		20,
+		"number of attempts to deliver a webhook payload before it is dropped. Retried forever if zero.",
+	)
+	flag.StringVar(
+		&otlpEndpoint,
+		"otlp-endpoint",
+		"",
+		"host:port of the OTLP/HTTP collector that receives traces of reconciles. Tracing is disabled if empty.",
+	)
+	flag.BoolVar(
+		&otlpInsecure,
+		"otlp-insecure",
+		false,
+		"send traces to --otlp-endpoint over plain HTTP.",
+	)
+	flag.Float64Var(
+		&traceSampleRatio,
+		"trace-sample-ratio",
+		1,
+		"fraction of reconciles to be traced.",
+	)
+	flag.StringVar(
+		&shardGroup,
+		"shard-group",
+		"",
+		"name of the group of replicas that share objects through Leases. "+
+			"Every replica of a group reconciles its own shard of Services, Gateways and Ingresses at once. "+
+			"Disabled if empty.",
+	)
+	flag.StringVar(
+		&shardLeaseNamespace,
+		"shard-lease-namespace",
+		"",
+		"namespace of Leases of --shard-group.",
+	)
+	flag.StringVar(
+		&shardIdentity,
+		"shard-identity",
+		"",
+		"unique name of this replica in --shard-group. (default: hostname)",
+	)
+	flag.DurationVar(
+		&shardLeaseDuration,
+		"shard-lease-duration",
+		15*time.Second,
+		"how long a replica keeps its shard after it stops renewing its Lease, in whole seconds.",
 	)
 	opts := zap.Options{
 		Development: true,
@@ -118,13 +439,112 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	if natRulesFile != "" {
+		if err := natRules.LoadFile(natRulesFile); err != nil {
+			setupLog.Error(err, "unable to load NAT rules", "file", natRulesFile)
+			os.Exit(1)
+		}
+	}
+
+	if maxIPsPerZone < 0 {
+		setupLog.Error(errors.New("must not be negative"), "invalid max IPs per zone", "maxIPsPerZone", maxIPsPerZone)
+		os.Exit(1)
+	}
+
+	if err := reconcileOpts.Validate(); err != nil {
+		setupLog.Error(err, "invalid rate limiter delays")
+		os.Exit(1)
+	}
+
+	if serviceWriteQPS > 0 && serviceWriteBurst < 1 {
+		setupLog.Error(
+			errors.New("must be positive if --service-write-qps is set"),
+			"invalid service write burst",
+			"serviceWriteBurst",
+			serviceWriteBurst,
+		)
+		os.Exit(1)
+	}
+
+	ingressControllerPods := application.IngressControllerPods{
+		Namespace: ingressControllerNamespace,
+		Selector:  labels.Nothing(),
+	}
+	cacheOpts := cache.Options{ByObject: map[client.Object]cache.ByObject{}}
+	infrastructure.SetTrimmedCacheOptions(cacheOpts.ByObject)
+	if len(ingressClasses) != 0 {
+		selector, err := labels.Parse(ingressControllerPodSelector)
+		if err == nil && selector.Empty() {
+			err = errors.New("ingress controller pod selector is required for --ingress-class")
+		}
+		if err != nil {
+			setupLog.Error(err, "invalid ingress controller pod selector", "selector", ingressControllerPodSelector)
+			os.Exit(1)
+		}
+		ingressControllerPods.Selector = selector
+		// only Pods of the ingress controller are needed
+		cacheOpts.ByObject[&corev1.Pod{}] = cache.ByObject{Label: selector}
+	}
+
+	if errs := validation.IsDNS1123Label(instanceName); instanceName != "" && len(errs) != 0 {
+		setupLog.Error(errors.New(strings.Join(errs, ", ")), "invalid instance name", "instanceName", instanceName)
+		os.Exit(1)
+	}
+	leaderElectionID, eventSource := "899c85cb.bhyoo.com", "static-lb"
+	if instanceName != "" {
+		leaderElectionID = instanceName + "." + leaderElectionID
+		eventSource = eventSource + "-" + instanceName
+	}
+	restConfig := ctrl.GetConfigOrDie()
+	// the API server names the field manager of updates after the user agent
+	restConfig.UserAgent = eventSource
+
+	if otlpEndpoint != "" {
+		tracerProvider, err := infrastructure.NewOTLPTracerProvider(
+			context.Background(),
+			otlpEndpoint,
+			otlpInsecure,
+			eventSource,
+			traceSampleRatio,
+		)
+		if err != nil {
+			setupLog.Error(err, "unable to set up tracing", "endpoint", otlpEndpoint)
+			os.Exit(1)
+		}
+		otel.SetTracerProvider(tracerProvider)
+		defer func() {
+			if err := tracerProvider.Shutdown(context.Background()); err != nil {
+				setupLog.Error(err, "unable to flush traces")
+			}
+		}()
+	}
+
+	if shardGroup != "" {
+		var err error
+		switch {
+		case enableLeaderElection:
+			err = errors.New("--shard-group can not be used with --leader-elect")
+		case shardLeaseNamespace == "":
+			err = errors.New("--shard-lease-namespace is required for --shard-group")
+		case shardLeaseDuration < time.Second || shardLeaseDuration%time.Second != 0:
+			err = errors.New("--shard-lease-duration must be whole seconds of at least 1s, which Leases are written in")
+		case shardIdentity == "":
+			shardIdentity, err = os.Hostname()
+		}
+		if err != nil {
+			setupLog.Error(err, "invalid sharding options", "shardGroup", shardGroup)
+			os.Exit(1)
+		}
+	}
+
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
 		Scheme:                 scheme,
-		MetricsBindAddress:     metricsAddr,
-		Port:                   9443,
+		Cache:                  cacheOpts,
+		Metrics:                metricsserver.Options{BindAddress: metricsAddr},
+		WebhookServer:          webhook.NewServer(webhook.Options{Port: 9443}),
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "899c85cb.bhyoo.com",
+		LeaderElectionID:       leaderElectionID,
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
@@ -142,36 +562,190 @@ func main() {
 		os.Exit(1)
 	}
 
+	metricsRecorder, err := infrastructure.NewMetricsRecorder()
+	if err != nil {
+		setupLog.Error(err, "unable to register metrics")
+		os.Exit(1)
+	}
+
+	var serviceWriteLimiter *rate.Limiter
+	if serviceWriteQPS > 0 {
+		serviceWriteLimiter = rate.NewLimiter(rate.Limit(serviceWriteQPS), serviceWriteBurst)
+	}
+
+	var inventoryRepo application.InventoryRepository
+	if inventoryConfigMap != "" {
+		namespace, name, found := strings.Cut(inventoryConfigMap, "/")
+		if !found || namespace == "" || name == "" {
+			setupLog.Error(
+				errors.New("must be namespace/name"),
+				"invalid inventory ConfigMap",
+				"inventoryConfigMap",
+				inventoryConfigMap,
+			)
+			os.Exit(1)
+		}
+		inventoryRepo = infrastructure.NewInventoryRepository(
+			mgr.GetAPIReader(),
+			mgr.GetClient(),
+			types.NamespacedName{Namespace: namespace, Name: name},
+		)
+	}
+
+	var assignmentHooks []application.AssignmentHook
+	if len(webhookURLs) != 0 {
+		var secret []byte
+		if webhookSecretFile != "" {
+			if secret, err = os.ReadFile(webhookSecretFile); err != nil {
+				setupLog.Error(err, "unable to read webhook secret", "file", webhookSecretFile)
+				os.Exit(1)
+			}
+			secret = []byte(strings.TrimSpace(string(secret)))
+		}
+
+		notifier, err := infrastructure.NewWebhookNotifier(webhookURLs, secret, webhookQueueDir, webhookMaxAttempts)
+		if err == nil {
+			err = mgr.Add(notifier)
+		}
+		if err != nil {
+			setupLog.Error(err, "unable to set up webhook notifier", "queueDir", webhookQueueDir)
+			os.Exit(1)
+		}
+		assignmentHooks = append(assignmentHooks, notifier)
+	}
+
+	portClaimChanges := controllers.NewServiceKeySet()
+
 	var (
 		nodeRepo          = infrastructure.NewNodeRepository(mgr.GetClient())
-		svcRepo           = infrastructure.NewServiceRepository(mgr.GetClient())
+		svcRepo           = infrastructure.NewServiceRepository(mgr.GetClient(), serviceWriteLimiter, eventSource)
 		endpointSliceRepo = infrastructure.NewEndpointSliceRepository(mgr.GetClient())
-		usecase           = application.New(
-			endpointSliceRepo,
-			nodeRepo,
-			svcRepo,
-			internalIPMappings.Mappings(),
-			externalIPMappings.Mappings(),
-			includeIngressIPFilter,
-			includeExternalIPFilter,
-			excludeIngressIPFilter,
-			excludeExternalIPFilter,
-		)
+		gatewayRepo       = infrastructure.NewGatewayRepository(mgr.GetClient(), instanceName)
+		podRepo           = infrastructure.NewPodRepository(mgr.GetClient())
+		ingressRepo       = infrastructure.NewIngressRepository(mgr.GetClient())
+		usecase           = application.New(application.Options{
+			EndpointSlices:        endpointSliceRepo,
+			Nodes:                 nodeRepo,
+			Services:              svcRepo,
+			Gateways:              gatewayRepo,
+			Pods:                  podRepo,
+			Ingresses:             ingressRepo,
+			Inventory:             inventoryRepo,
+			EventRecorder:         mgr.GetEventRecorderFor(eventSource),
+			Metrics:               metricsRecorder,
+			AssignmentHooks:       assignmentHooks,
+			InternalIPMappings:    internalIPMappings.Mappings(),
+			ExternalIPMappings:    externalIPMappings.Mappings(),
+			IPMappingPriorities:   ipMappingPriorities,
+			NodeAddressSelection:  nodeAddressSelection.Selection(),
+			IncludeIngressIPNets:  includeIngressIPFilter.IPNets(),
+			IncludeExternalIPNets: includeExternalIPFilter.IPNets(),
+			ExcludeIngressIPNets:  excludeIngressIPFilter.IPNets(),
+			ExcludeExternalIPNets: excludeExternalIPFilter.IPNets(),
+			NATRules:              natRules,
+			DropUntranslatedIPs:   dropUntranslatedIPs,
+			ClusterNetworks: application.ClusterNetworks{
+				Exclude:      excludeClusterNetworks,
+				ServiceCIDRs: serviceCIDRs.IPNets(),
+			},
+			Topology:       application.Topology{Zones: zones, Regions: regions, MaxIPsPerZone: maxIPsPerZone},
+			EmptyIPsPolicy: emptyIPsPolicy.Policy(),
+			IPAddDelay:     ipAddDelay,
+			IPRemoveDelay:  ipRemoveDelay,
+			NodePortsPolicy: application.NodePortsPolicy{
+				NoNodePorts:     noNodePortsMode.Mode(),
+				RefuseConflicts: refuseNodePortConflicts,
+			},
+			PortConflictPolicy:    portConflictPolicy.Policy(),
+			PortClaimsChanged:     portClaimChanges.Add,
+			IngressControllerPods: ingressControllerPods,
+			InstanceName:          instanceName,
+		})
 	)
 
 	if err = endpointSliceRepo.RegisterFieldIndex(context.Background(), mgr.GetFieldIndexer()); err != nil {
 		setupLog.Error(err, "unable to register index", "resource", "EndpointSlice")
 		os.Exit(1)
 	}
+	if err = nodeRepo.RegisterIndex(context.Background(), mgr.GetCache()); err != nil {
+		setupLog.Error(err, "unable to register index", "resource", "Node")
+		os.Exit(1)
+	}
+
+	var shard controllers.ShardFilter
+	if shardGroup != "" {
+		membership := infrastructure.NewLeaseShardMembership(
+			mgr.GetAPIReader(),
+			mgr.GetClient(),
+			shardLeaseNamespace,
+			shardGroup,
+			shardIdentity,
+			shardLeaseDuration,
+		)
+		if err = mgr.Add(membership); err != nil {
+			setupLog.Error(err, "unable to set up shard membership")
+			os.Exit(1)
+		}
+		shard = membership
+	}
 
 	if err = (&controllers.ServiceReconciler{
-		Client:  mgr.GetClient(),
-		Scheme:  mgr.GetScheme(),
-		Usecase: usecase,
+		Client:           mgr.GetClient(),
+		Scheme:           mgr.GetScheme(),
+		Usecase:          usecase,
+		ResyncPeriod:     resyncPeriod,
+		Options:          reconcileOpts,
+		Shard:            shard,
+		PortClaimChanges: portClaimChanges,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Service")
 		os.Exit(1)
 	}
+	if gatewayClassName != "" {
+		if err = gatewayRepo.RegisterFieldIndex(context.Background(), mgr.GetFieldIndexer()); err != nil {
+			setupLog.Error(err, "unable to register index", "resource", "Gateway")
+			os.Exit(1)
+		}
+
+		if err = (&controllers.GatewayReconciler{
+			Client:           mgr.GetClient(),
+			Scheme:           mgr.GetScheme(),
+			Usecase:          usecase,
+			Gateways:         gatewayRepo,
+			GatewayClassName: gatewayv1.ObjectName(gatewayClassName),
+			Options:          reconcileOpts,
+			Shard:            shard,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Gateway")
+			os.Exit(1)
+		}
+	}
+	if len(ingressClasses) != 0 {
+		if err = (&controllers.IngressReconciler{
+			Client:         mgr.GetClient(),
+			Scheme:         mgr.GetScheme(),
+			Usecase:        usecase,
+			IngressClasses: ingressClasses,
+			ControllerPods: ingressControllerPods,
+			Options:        reconcileOpts,
+			Shard:          shard,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Ingress")
+			os.Exit(1)
+		}
+	}
+	if inventoryRepo != nil {
+		// Services deleted while static-lb was down are never reconciled, so their entries are pruned once
+		if err = mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			if err := usecase.PruneInventory(ctx); err != nil {
+				setupLog.Error(err, "unable to prune inventory", "inventoryConfigMap", inventoryConfigMap)
+			}
+			return nil
+		})); err != nil {
+			setupLog.Error(err, "unable to set up inventory pruning")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
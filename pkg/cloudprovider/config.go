@@ -0,0 +1,126 @@
+package cloudprovider
+
+import (
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/isac322/static-lb/internal/application"
+	"github.com/isac322/static-lb/internal/presentation"
+	"github.com/isac322/static-lb/pkg/staticlb"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the cloud config of the static-lb provider, in YAML or JSON.
+// Fields have the same meaning as flags of the static-lb controller of the same names.
+// Only ingress IPs are published, since a LoadBalancerStatus has no room for external IPs.
+type Config struct {
+	InstanceName         string   `json:"instanceName"`
+	IPMappings           []string `json:"ipMappings"`
+	NodeAddressSelection string   `json:"nodeAddressSelection"`
+	IncludeIngressIPNets []string `json:"includeIngressIPNets"`
+	ExcludeIngressIPNets []string `json:"excludeIngressIPNets"`
+	NATRules             []string `json:"natRules"`
+	DropUntranslatedIPs  bool     `json:"dropUntranslatedIPs"`
+	// ExcludeClusterNetworks excludes pod CIDRs of nodes, ServiceCIDRs, and loopback and link-local ranges.
+	ExcludeClusterNetworks bool     `json:"excludeClusterNetworks"`
+	ServiceCIDRs           []string `json:"serviceCIDRs"`
+	Zones                  []string `json:"zones"`
+	Regions                []string `json:"regions"`
+	MaxIPsPerZone          int      `json:"maxIPsPerZone"`
+}
+
+// defaultIPMapping publishes internal IPs of nodes as ingress IPs if no mapping is configured.
+const defaultIPMapping = "ingress=internal"
+
+// ReadConfig reads Config from r. An empty Config is returned if r is nil.
+func ReadConfig(r io.Reader) (Config, error) {
+	var cfg Config
+	if r == nil {
+		return cfg, nil
+	}
+
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return Config{}, err
+	}
+	if err = yaml.UnmarshalStrict(raw, &cfg); err != nil {
+		return Config{}, fmt.Errorf("invalid cloud config: %w", err)
+	}
+	return cfg, nil
+}
+
+// parsedConfig is Config converted to defaults of the application layer.
+type parsedConfig struct {
+	ipMappingPriorities  []application.IPMappingPriority
+	nodeAddressSelection application.NodeAddressSelection
+	includeIngressIPNets []*net.IPNet
+	excludeIngressIPNets []*net.IPNet
+	natRules             []application.NATRule
+	dropUntranslatedIPs  bool
+	clusterNetworks      application.ClusterNetworks
+	topology             application.Topology
+}
+
+func (c Config) parse() (parsed parsedConfig, err error) {
+	mappings := c.IPMappings
+	if len(mappings) == 0 {
+		mappings = []string{defaultIPMapping}
+	}
+	for _, mapping := range mappings {
+		priority, err := staticlb.ParseIPMappingPriority(mapping)
+		if err != nil {
+			return parsedConfig{}, fmt.Errorf("ipMappings: %w", err)
+		}
+		if priority.Target != application.IPMappingTargetIngress {
+			return parsedConfig{}, fmt.Errorf("ipMappings: only ingress is supported: %s", mapping)
+		}
+		parsed.ipMappingPriorities = append(parsed.ipMappingPriorities, priority)
+	}
+
+	parsed.nodeAddressSelection = application.NodeAddressSelectionAll
+	if c.NodeAddressSelection != "" {
+		if parsed.nodeAddressSelection, err = staticlb.ParseNodeAddressSelection(c.NodeAddressSelection); err != nil {
+			return parsedConfig{}, fmt.Errorf("nodeAddressSelection: %w", err)
+		}
+	}
+
+	if parsed.includeIngressIPNets, err = parseIPNets(c.IncludeIngressIPNets); err != nil {
+		return parsedConfig{}, fmt.Errorf("includeIngressIPNets: %w", err)
+	}
+	if parsed.excludeIngressIPNets, err = parseIPNets(c.ExcludeIngressIPNets); err != nil {
+		return parsedConfig{}, fmt.Errorf("excludeIngressIPNets: %w", err)
+	}
+
+	for _, s := range c.NATRules {
+		rule, err := staticlb.ParseNATRule(s)
+		if err != nil {
+			return parsedConfig{}, fmt.Errorf("natRules: %w", err)
+		}
+		parsed.natRules = append(parsed.natRules, rule)
+	}
+	parsed.dropUntranslatedIPs = c.DropUntranslatedIPs
+
+	parsed.clusterNetworks.Exclude = c.ExcludeClusterNetworks
+	if parsed.clusterNetworks.ServiceCIDRs, err = parseIPNets(c.ServiceCIDRs); err != nil {
+		return parsedConfig{}, fmt.Errorf("serviceCIDRs: %w", err)
+	}
+
+	if c.MaxIPsPerZone < 0 {
+		return parsedConfig{}, fmt.Errorf("maxIPsPerZone: must not be negative: %d", c.MaxIPsPerZone)
+	}
+	parsed.topology = application.Topology{Zones: c.Zones, Regions: c.Regions, MaxIPsPerZone: c.MaxIPsPerZone}
+	return parsed, nil
+}
+
+// parseIPNets parses ss the same way as the IP network flags of the static-lb controller.
+func parseIPNets(ss []string) ([]*net.IPNet, error) {
+	var ipNets presentation.IPNetFilterFlag
+	for _, s := range ss {
+		if err := ipNets.Set(s); err != nil {
+			return nil, err
+		}
+	}
+	return ipNets.IPNets(), nil
+}
@@ -0,0 +1,137 @@
+package cloudprovider
+
+import (
+	"net"
+	"testing"
+
+	"github.com/isac322/static-lb/internal/application"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_parse(t *testing.T) {
+	t.Parallel()
+
+	mustParseCIDR := func(s string) *net.IPNet {
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			panic(err)
+		}
+		return ipNet
+	}
+
+	tests := []struct {
+		name     string
+		config   Config
+		expected parsedConfig
+		hasError bool
+	}{
+		{
+			name:   "defaults",
+			config: Config{},
+			expected: parsedConfig{
+				ipMappingPriorities: []application.IPMappingPriority{{
+					Target: application.IPMappingTargetIngress,
+					Types:  []application.NodeAddressType{application.NodeAddressTypeInternal},
+				}},
+				nodeAddressSelection: application.NodeAddressSelectionAll,
+			},
+		},
+		{
+			name: "every field",
+			config: Config{
+				IPMappings:             []string{"ingress=external|internal"},
+				NodeAddressSelection:   "first-per-family",
+				IncludeIngressIPNets:   []string{"192.168.0.0/16"},
+				ExcludeIngressIPNets:   []string{"192.168.1.0/24"},
+				NATRules:               []string{"192.168.0.1=203.0.113.1"},
+				DropUntranslatedIPs:    true,
+				ExcludeClusterNetworks: true,
+				ServiceCIDRs:           []string{"10.96.0.0/12"},
+				Zones:                  []string{"zone-a"},
+				Regions:                []string{"region-a"},
+				MaxIPsPerZone:          2,
+			},
+			expected: parsedConfig{
+				ipMappingPriorities: []application.IPMappingPriority{{
+					Target: application.IPMappingTargetIngress,
+					Types: []application.NodeAddressType{
+						application.NodeAddressTypeExternal,
+						application.NodeAddressTypeInternal,
+					},
+				}},
+				nodeAddressSelection: application.NodeAddressSelectionFirstPerFamily,
+				includeIngressIPNets: []*net.IPNet{mustParseCIDR("192.168.0.0/16")},
+				excludeIngressIPNets: []*net.IPNet{mustParseCIDR("192.168.1.0/24")},
+				natRules: []application.NATRule{{
+					From: mustParseCIDR("192.168.0.1/32"),
+					To:   mustParseCIDR("203.0.113.1/32"),
+				}},
+				dropUntranslatedIPs: true,
+				clusterNetworks: application.ClusterNetworks{
+					Exclude:      true,
+					ServiceCIDRs: []*net.IPNet{mustParseCIDR("10.96.0.0/12")},
+				},
+				topology: application.Topology{
+					Zones:         []string{"zone-a"},
+					Regions:       []string{"region-a"},
+					MaxIPsPerZone: 2,
+				},
+			},
+		},
+		{
+			name:     "external IP mapping",
+			config:   Config{IPMappings: []string{"external=internal"}},
+			hasError: true,
+		},
+		{
+			name:     "invalid IP mapping",
+			config:   Config{IPMappings: []string{"ingress=unknown"}},
+			hasError: true,
+		},
+		{
+			name:     "invalid node address selection",
+			config:   Config{NodeAddressSelection: "first"},
+			hasError: true,
+		},
+		{
+			name:     "invalid include IP network",
+			config:   Config{IncludeIngressIPNets: []string{"192.168.0.0"}},
+			hasError: true,
+		},
+		{
+			name:     "invalid exclude IP network",
+			config:   Config{ExcludeIngressIPNets: []string{"unknown"}},
+			hasError: true,
+		},
+		{
+			name:     "invalid NAT rule",
+			config:   Config{NATRules: []string{"192.168.0.0/24=203.0.113.0/25"}},
+			hasError: true,
+		},
+		{
+			name:     "invalid service CIDR",
+			config:   Config{ServiceCIDRs: []string{"10.96.0.0/33"}},
+			hasError: true,
+		},
+		{
+			name:     "negative max IPs per zone",
+			config:   Config{MaxIPsPerZone: -1},
+			hasError: true,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := tc.config.parse()
+			if tc.hasError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
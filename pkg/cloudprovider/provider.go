@@ -0,0 +1,278 @@
+// Package cloudprovider implements the LoadBalancer of k8s.io/cloud-provider with the IP pipeline of static-lb,
+// so that static-lb runs inside a cloud-controller-manager instead of as a separate controller.
+package cloudprovider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/isac322/static-lb/internal/application"
+	"github.com/isac322/static-lb/internal/infrastructure/lister"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/klog/v2"
+)
+
+// ProviderName is the name that the provider is registered as.
+const ProviderName = "static-lb"
+
+func init() {
+	cloudprovider.RegisterCloudProvider(ProviderName, func(config io.Reader) (cloudprovider.Interface, error) {
+		return New(config)
+	})
+}
+
+// Provider is a cloudprovider.Interface that supports only LoadBalancer.
+type Provider struct {
+	config       parsedConfig
+	instanceName string
+
+	mu        sync.RWMutex
+	clientset kubernetes.Interface
+	usecase   application.Usecase
+}
+
+var (
+	_ cloudprovider.Interface    = (*Provider)(nil)
+	_ cloudprovider.LoadBalancer = (*Provider)(nil)
+)
+
+// New creates a Provider from the cloud config in config, which may be nil.
+func New(config io.Reader) (*Provider, error) {
+	cfg, err := ReadConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := cfg.parse()
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{config: parsed, instanceName: cfg.InstanceName}, nil
+}
+
+// Initialize starts informers of Nodes and EndpointSlices that the IP pipeline reads, and refreshes statuses of
+// Services whose EndpointSlices change.
+func (p *Provider) Initialize(clientBuilder cloudprovider.ControllerClientBuilder, stop <-chan struct{}) {
+	clientset := clientBuilder.ClientOrDie(ProviderName)
+
+	factory := informers.NewSharedInformerFactory(clientset, 0)
+	nodeInformer := factory.Core().V1().Nodes()
+	endpointSliceInformer := factory.Discovery().V1().EndpointSlices()
+	serviceInformer := factory.Core().V1().Services()
+	refresher := newStatusRefresher(serviceInformer.Lister(), func(ctx context.Context, svc *corev1.Service) error {
+		return p.UpdateLoadBalancer(ctx, "", svc, nil)
+	})
+	if _, err := endpointSliceInformer.Informer().AddEventHandler(refresher.eventHandler()); err != nil {
+		klog.ErrorS(err, "static-lb: failed to watch EndpointSlices")
+		return
+	}
+
+	usecase := application.New(application.Options{
+		EndpointSlices:       lister.NewEndpointSliceRepository(endpointSliceInformer.Lister()),
+		Nodes:                lister.NewNodeRepository(nodeInformer.Lister()),
+		IPMappingPriorities:  p.config.ipMappingPriorities,
+		NodeAddressSelection: p.config.nodeAddressSelection,
+		IncludeIngressIPNets: p.config.includeIngressIPNets,
+		ExcludeIngressIPNets: p.config.excludeIngressIPNets,
+		NATRules:             p.config.natRules,
+		DropUntranslatedIPs:  p.config.dropUntranslatedIPs,
+		ClusterNetworks:      p.config.clusterNetworks,
+		Topology:             p.config.topology,
+		EmptyIPsPolicy:       application.EmptyIPsPolicy{Mode: application.EmptyIPsModeClear},
+		NodePortsPolicy:      application.NodePortsPolicy{NoNodePorts: application.NoNodePortsModePublish},
+		PortConflictPolicy:   application.PortConflictPolicyWarn,
+		InstanceName:         p.instanceName,
+	})
+	if _, err := nodeInformer.Informer().AddEventHandler(reservedIPNetsInvalidator(usecase)); err != nil {
+		klog.ErrorS(err, "static-lb: failed to watch Nodes")
+		return
+	}
+
+	factory.Start(stop)
+	if !cache.WaitForCacheSync(
+		stop,
+		nodeInformer.Informer().HasSynced,
+		endpointSliceInformer.Informer().HasSynced,
+		serviceInformer.Informer().HasSynced,
+	) {
+		klog.Error("static-lb: caches of Nodes, EndpointSlices and Services are not synced")
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clientset = clientset
+	p.usecase = usecase
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stop
+		cancel()
+	}()
+	go refresher.run(ctx)
+}
+
+// reservedIPNetsInvalidator drops networks internal to the cluster that usecase cached whenever nodes are added or
+// removed, or their pod CIDRs change.
+func reservedIPNetsInvalidator(usecase application.Usecase) cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(interface{}) { usecase.InvalidateReservedIPNets() },
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNode, ok := oldObj.(*corev1.Node)
+			if !ok {
+				return
+			}
+			newNode, ok := newObj.(*corev1.Node)
+			if !ok {
+				return
+			}
+			if oldNode.Spec.PodCIDR != newNode.Spec.PodCIDR ||
+				!equality.Semantic.DeepEqual(oldNode.Spec.PodCIDRs, newNode.Spec.PodCIDRs) {
+				usecase.InvalidateReservedIPNets()
+			}
+		},
+		DeleteFunc: func(interface{}) { usecase.InvalidateReservedIPNets() },
+	}
+}
+
+func (p *Provider) LoadBalancer() (cloudprovider.LoadBalancer, bool) {
+	return p, true
+}
+
+func (p *Provider) Instances() (cloudprovider.Instances, bool) {
+	return nil, false
+}
+
+func (p *Provider) InstancesV2() (cloudprovider.InstancesV2, bool) {
+	return nil, false
+}
+
+func (p *Provider) Zones() (cloudprovider.Zones, bool) {
+	return nil, false
+}
+
+func (p *Provider) Clusters() (cloudprovider.Clusters, bool) {
+	return nil, false
+}
+
+func (p *Provider) Routes() (cloudprovider.Routes, bool) {
+	return nil, false
+}
+
+func (p *Provider) ProviderName() string {
+	return ProviderName
+}
+
+// HasClusterID returns true, since nothing outside the cluster is shared with other clusters.
+func (p *Provider) HasClusterID() bool {
+	return true
+}
+
+// GetLoadBalancer returns the status of service as it is, since static-lb owns nothing outside the cluster.
+func (p *Provider) GetLoadBalancer(
+	_ context.Context,
+	_ string,
+	service *corev1.Service,
+) (*corev1.LoadBalancerStatus, bool, error) {
+	status := service.Status.LoadBalancer.DeepCopy()
+	return status, len(status.Ingress) != 0, nil
+}
+
+func (p *Provider) GetLoadBalancerName(_ context.Context, _ string, service *corev1.Service) string {
+	return cloudprovider.DefaultLoadBalancerName(service)
+}
+
+// EnsureLoadBalancer returns node IPs of service as its status.
+func (p *Provider) EnsureLoadBalancer(
+	ctx context.Context,
+	_ string,
+	service *corev1.Service,
+	_ []*corev1.Node,
+) (*corev1.LoadBalancerStatus, error) {
+	return p.resolveStatus(ctx, service)
+}
+
+// UpdateLoadBalancer is called when nodes change, and by the status refresher of Initialize when EndpointSlices of
+// service change. The service controller of cloud-controller-manager only writes the status returned by
+// EnsureLoadBalancer, so the status is patched here if node IPs of service changed.
+func (p *Provider) UpdateLoadBalancer(
+	ctx context.Context,
+	_ string,
+	service *corev1.Service,
+	_ []*corev1.Node,
+) error {
+	status, err := p.resolveStatus(ctx, service)
+	if err != nil {
+		return err
+	}
+	if loadBalancerStatusEqual(service.Status.LoadBalancer, *status) {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{"loadBalancer": status},
+	})
+	if err != nil {
+		return err
+	}
+
+	p.mu.RLock()
+	clientset := p.clientset
+	p.mu.RUnlock()
+	_, err = clientset.CoreV1().Services(service.Namespace).Patch(
+		ctx,
+		service.Name,
+		types.MergePatchType,
+		patch,
+		metav1.PatchOptions{},
+		"status",
+	)
+	return err
+}
+
+// EnsureLoadBalancerDeleted does nothing, since static-lb owns nothing outside the cluster.
+func (p *Provider) EnsureLoadBalancerDeleted(context.Context, string, *corev1.Service) error {
+	return nil
+}
+
+func (p *Provider) resolveStatus(ctx context.Context, service *corev1.Service) (*corev1.LoadBalancerStatus, error) {
+	p.mu.RLock()
+	usecase := p.usecase
+	p.mu.RUnlock()
+	if usecase == nil {
+		return nil, fmt.Errorf("%s is not initialized", ProviderName)
+	}
+
+	ips, err := usecase.ResolveIPs(ctx, *service)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &corev1.LoadBalancerStatus{Ingress: make([]corev1.LoadBalancerIngress, 0, len(ips.IngressIPs))}
+	for _, ip := range ips.IngressIPs {
+		status.Ingress = append(status.Ingress, corev1.LoadBalancerIngress{IP: ip})
+	}
+	return status, nil
+}
+
+func loadBalancerStatusEqual(a, b corev1.LoadBalancerStatus) bool {
+	if len(a.Ingress) != len(b.Ingress) {
+		return false
+	}
+	for i := range a.Ingress {
+		if a.Ingress[i].IP != b.Ingress[i].IP || a.Ingress[i].Hostname != b.Ingress[i].Hostname {
+			return false
+		}
+	}
+	return true
+}
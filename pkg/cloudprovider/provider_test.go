@@ -0,0 +1,135 @@
+package cloudprovider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/isac322/static-lb/internal/application"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeUsecase resolves every Service to ips, or fails with err.
+type fakeUsecase struct {
+	application.Usecase
+	ips application.IPStatus
+	err error
+}
+
+func (f fakeUsecase) ResolveIPs(context.Context, corev1.Service) (application.IPStatus, error) {
+	return f.ips, f.err
+}
+
+func TestProvider_resolveStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		usecase  application.Usecase
+		expected *corev1.LoadBalancerStatus
+		hasError bool
+	}{
+		{
+			name:     "not initialized",
+			hasError: true,
+		},
+		{
+			name:    "ingress IPs",
+			usecase: fakeUsecase{ips: application.IPStatus{IngressIPs: []string{"192.168.0.1", "192.168.0.2"}}},
+			expected: &corev1.LoadBalancerStatus{Ingress: []corev1.LoadBalancerIngress{
+				{IP: "192.168.0.1"},
+				{IP: "192.168.0.2"},
+			}},
+		},
+		{
+			name:    "external IPs are not published",
+			usecase: fakeUsecase{ips: application.IPStatus{ExternalIPs: []string{"203.0.113.1"}}},
+			expected: &corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{},
+			},
+		},
+		{
+			name:     "resolve error",
+			usecase:  fakeUsecase{err: errors.New("failed")},
+			hasError: true,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := &Provider{usecase: tc.usecase}
+			actual, err := p.resolveStatus(context.Background(), &corev1.Service{})
+			if tc.hasError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestProvider_UpdateLoadBalancer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		currentIPs    []string
+		resolvedIPs   []string
+		expectedPatch bool
+	}{
+		{
+			name:          "changed",
+			currentIPs:    []string{"192.168.0.1"},
+			resolvedIPs:   []string{"192.168.0.2"},
+			expectedPatch: true,
+		},
+		{
+			name:        "unchanged",
+			currentIPs:  []string{"192.168.0.1"},
+			resolvedIPs: []string{"192.168.0.1"},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "svc"}}
+			for _, ip := range tc.currentIPs {
+				svc.Status.LoadBalancer.Ingress = append(svc.Status.LoadBalancer.Ingress, corev1.LoadBalancerIngress{IP: ip})
+			}
+			clientset := fake.NewSimpleClientset(svc)
+			p := &Provider{
+				clientset: clientset,
+				usecase:   fakeUsecase{ips: application.IPStatus{IngressIPs: tc.resolvedIPs}},
+			}
+
+			err := p.UpdateLoadBalancer(context.Background(), "", svc, nil)
+			assert.NoError(t, err)
+
+			actual, err := clientset.CoreV1().Services("default").Get(context.Background(), "svc", metav1.GetOptions{})
+			assert.NoError(t, err)
+			var actualIPs []string
+			for _, ingress := range actual.Status.LoadBalancer.Ingress {
+				actualIPs = append(actualIPs, ingress.IP)
+			}
+			assert.Equal(t, tc.resolvedIPs, actualIPs)
+
+			patched := false
+			for _, action := range clientset.Actions() {
+				if action.GetVerb() == "patch" {
+					patched = true
+				}
+			}
+			assert.Equal(t, tc.expectedPatch, patched)
+		})
+	}
+}
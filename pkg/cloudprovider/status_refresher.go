@@ -0,0 +1,124 @@
+package cloudprovider
+
+import (
+	"context"
+	"time"
+
+	"github.com/isac322/static-lb/internal/pkg/endpointslice"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+// loadBalancerCleanupFinalizer is added by the service controller of cloud-controller-manager once it ensured the
+// load balancer of a Service.
+const loadBalancerCleanupFinalizer = "service.kubernetes.io/load-balancer-cleanup"
+
+// statusRefresher updates statuses of Services when their EndpointSlices change. The service controller of
+// cloud-controller-manager calls UpdateLoadBalancer only when nodes change, but IPs of Services depend on nodes of
+// their endpoints, e.g. with the Local external traffic policy.
+type statusRefresher struct {
+	services corelisters.ServiceLister
+	queue    workqueue.RateLimitingInterface
+	update   func(ctx context.Context, service *corev1.Service) error
+}
+
+func newStatusRefresher(
+	services corelisters.ServiceLister,
+	update func(ctx context.Context, service *corev1.Service) error,
+) *statusRefresher {
+	return &statusRefresher{
+		services: services,
+		queue:    workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		update:   update,
+	}
+}
+
+// eventHandler enqueues Services of EndpointSlices.
+func (r *statusRefresher) eventHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    r.enqueue,
+		UpdateFunc: func(_, newObj interface{}) { r.enqueue(newObj) },
+		DeleteFunc: r.enqueue,
+	}
+}
+
+func (r *statusRefresher) enqueue(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	slice, ok := obj.(*discoveryv1.EndpointSlice)
+	if !ok {
+		return
+	}
+	key, err := endpointslice.ServiceKeyForSlice(slice)
+	if err != nil {
+		return
+	}
+	r.queue.Add(key)
+}
+
+// run processes enqueued Services until ctx is done.
+func (r *statusRefresher) run(ctx context.Context) {
+	defer r.queue.ShutDown()
+	go wait.UntilWithContext(ctx, func(ctx context.Context) {
+		for r.processNext(ctx) {
+		}
+	}, time.Second)
+	<-ctx.Done()
+}
+
+func (r *statusRefresher) processNext(ctx context.Context) bool {
+	item, shutdown := r.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer r.queue.Done(item)
+
+	key := item.(types.NamespacedName)
+	if err := r.refresh(ctx, key); err != nil {
+		klog.ErrorS(err, "static-lb: failed to refresh the LoadBalancer status", "service", key)
+		r.queue.AddRateLimited(key)
+		return true
+	}
+	r.queue.Forget(key)
+	return true
+}
+
+func (r *statusRefresher) refresh(ctx context.Context, key types.NamespacedName) error {
+	svc, err := r.services.Services(key.Namespace).Get(key.Name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !isEnsuredLoadBalancer(svc) {
+		return nil
+	}
+	return r.update(ctx, svc.DeepCopy())
+}
+
+// isEnsuredLoadBalancer reports whether the service controller of cloud-controller-manager ensured the load balancer of
+// svc and still manages it, so that its status may be written.
+func isEnsuredLoadBalancer(svc *corev1.Service) bool {
+	if svc.Spec.Type != corev1.ServiceTypeLoadBalancer || svc.Spec.LoadBalancerClass != nil {
+		return false
+	}
+	if svc.DeletionTimestamp != nil {
+		return false
+	}
+	for _, finalizer := range svc.Finalizers {
+		if finalizer == loadBalancerCleanupFinalizer {
+			return true
+		}
+	}
+	return false
+}
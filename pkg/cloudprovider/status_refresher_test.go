@@ -0,0 +1,111 @@
+package cloudprovider
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusRefresher_processNext(t *testing.T) {
+	t.Parallel()
+
+	now := metav1.Now()
+	className := "other"
+	newService := func(mutate func(svc *corev1.Service)) *corev1.Service {
+		svc := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:  "default",
+				Name:       "svc",
+				Finalizers: []string{loadBalancerCleanupFinalizer},
+			},
+			Spec: corev1.ServiceSpec{
+				Type:                  corev1.ServiceTypeLoadBalancer,
+				ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyTypeLocal,
+			},
+		}
+		if mutate != nil {
+			mutate(svc)
+		}
+		return svc
+	}
+
+	tests := []struct {
+		name            string
+		service         *corev1.Service
+		expectedUpdated bool
+	}{
+		{
+			name:            "ensured load balancer",
+			service:         newService(nil),
+			expectedUpdated: true,
+		},
+		{
+			name:    "not found",
+			service: nil,
+		},
+		{
+			name:    "not ensured yet",
+			service: newService(func(svc *corev1.Service) { svc.Finalizers = nil }),
+		},
+		{
+			name:    "ClusterIP",
+			service: newService(func(svc *corev1.Service) { svc.Spec.Type = corev1.ServiceTypeClusterIP }),
+		},
+		{
+			name:    "another load balancer class",
+			service: newService(func(svc *corev1.Service) { svc.Spec.LoadBalancerClass = &className }),
+		},
+		{
+			name:    "being deleted",
+			service: newService(func(svc *corev1.Service) { svc.DeletionTimestamp = &now }),
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			if tc.service != nil {
+				assert.NoError(t, indexer.Add(tc.service))
+			}
+			var updated []types.NamespacedName
+			refresher := newStatusRefresher(
+				corelisters.NewServiceLister(indexer),
+				func(_ context.Context, svc *corev1.Service) error {
+					updated = append(updated, types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name})
+					return nil
+				},
+			)
+			defer refresher.queue.ShutDown()
+
+			refresher.enqueue(&discoveryv1.EndpointSlice{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "svc-abcde",
+				Labels:    map[string]string{discoveryv1.LabelServiceName: "svc"},
+			}})
+			// not owned by a Service
+			refresher.enqueue(&discoveryv1.EndpointSlice{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "orphan",
+			}})
+			assert.Equal(t, 1, refresher.queue.Len())
+
+			assert.True(t, refresher.processNext(context.Background()))
+			var expected []types.NamespacedName
+			if tc.expectedUpdated {
+				expected = []types.NamespacedName{{Namespace: "default", Name: "svc"}}
+			}
+			assert.Equal(t, expected, updated)
+			assert.Zero(t, refresher.queue.Len())
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package staticlb
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+)
+
+// Compute returns IPs to publish for svc. endpointSlices are EndpointSlices of svc. nodes may be every node of the
+// cluster, since nodes without serving endpoints of svc (Local policy) or not ready (Cluster policy) are ignored.
+// Compute reads nothing but its arguments, so networks to reserve, e.g. ClusterIPNets, are discovered by the caller
+// and passed as ReservedIPNets of cfg.
+func Compute(
+	svc corev1.Service,
+	endpointSlices []discoveryv1.EndpointSlice,
+	nodes []corev1.Node,
+	cfg Config,
+) (result Result) {
+	if svc.Spec.Type != corev1.ServiceTypeLoadBalancer {
+		result.Trace.add(StageCollect, "type is %s, not %s: every IP is removed", svc.Spec.Type,
+			corev1.ServiceTypeLoadBalancer)
+		return result
+	}
+
+	nodeIPs, ok, trace := cfg.addressSources().CollectNodeIPs(svc, endpointSlices, nodes)
+	result.Trace = append(result.Trace, trace...)
+	if !ok {
+		result.Skipped = true
+		return result
+	}
+	result.NodeIPs = nodeIPs
+
+	result.Candidates, trace = MapIPs(nodeIPs, svc.Annotations, cfg)
+	result.Trace = append(result.Trace, trace...)
+
+	translated, trace := TranslateIPs(result.Candidates, svc.Annotations, cfg)
+	result.Trace = append(result.Trace, trace...)
+
+	filtered, trace := FilterIPs(translated, svc.Annotations, cfg)
+	result.Trace = append(result.Trace, trace...)
+
+	filtered, trace = ConstrainToRequestedIPs(filtered, svc)
+	result.Trace = append(result.Trace, trace...)
+
+	result.IPs, trace = ArrangeByTopology(filtered, nodeIPs, svc.Annotations, cfg)
+	result.Trace = append(result.Trace, trace...)
+	return result
+}
+
+// CollectNodeIPs returns addresses of nodes that receive external traffic of svc, regardless of the type of svc.
+// It returns false if svc has an externalTrafficPolicy that static-lb does not handle. Addresses are read from every
+// registered AddressSource.
+func CollectNodeIPs(
+	svc corev1.Service,
+	endpointSlices []discoveryv1.EndpointSlice,
+	nodes []corev1.Node,
+) (nodeIPs NodeIPs, ok bool, trace Trace) {
+	return RegisteredAddressSources().CollectNodeIPs(svc, endpointSlices, nodes)
+}
+
+// CollectNodeIPs is CollectNodeIPs that reads addresses from s.
+func (s AddressSources) CollectNodeIPs(
+	svc corev1.Service,
+	endpointSlices []discoveryv1.EndpointSlice,
+	nodes []corev1.Node,
+) (nodeIPs NodeIPs, ok bool, trace Trace) {
+	servingNodes := ServingNodeNames(endpointSlices)
+
+	var selected []corev1.Node
+	switch svc.Spec.ExternalTrafficPolicy {
+	case corev1.ServiceExternalTrafficPolicyTypeLocal:
+		trace.add(StageCollect, "externalTrafficPolicy is Local: serving endpoints run on nodes %v", servingNodes)
+
+		byName := make(map[string]corev1.Node, len(nodes))
+		for _, node := range nodes {
+			byName[node.Name] = node
+		}
+		for _, name := range servingNodes {
+			node, exists := byName[name]
+			if !exists {
+				trace.add(StageCollect, "node %s is skipped: not found", name)
+				continue
+			}
+			selected = append(selected, node)
+		}
+
+	case corev1.ServiceExternalTrafficPolicyTypeCluster:
+		if len(servingNodes) == 0 {
+			trace.add(StageCollect, "externalTrafficPolicy is Cluster: no serving endpoints, so no nodes")
+			return NodeIPs{}, true, trace
+		}
+
+		for _, node := range nodes {
+			if IsNodeReady(node) {
+				selected = append(selected, node)
+			}
+		}
+		trace.add(StageCollect, "externalTrafficPolicy is Cluster: %d of %d nodes are ready", len(selected), len(nodes))
+
+	default:
+		trace.add(StageCollect, "externalTrafficPolicy %q is not handled: IPs are left as they are",
+			svc.Spec.ExternalTrafficPolicy)
+		return NodeIPs{}, false, trace
+	}
+
+	nodeIPs = s.NodeIPsOf(selected)
+	if svc.Spec.ExternalTrafficPolicy == corev1.ServiceExternalTrafficPolicyTypeLocal {
+		nodeIPs = FillEndpointZones(nodeIPs, endpointSlices)
+	}
+	for _, node := range nodeIPs.Nodes {
+		trace.add(StageCollect, "node %s has addresses %v", node.Name, node.Addresses)
+	}
+	return nodeIPs, true, trace
+}
+
+// ServingNodeNames returns names of nodes that run serving endpoints in endpointSlices, without duplicates.
+func ServingNodeNames(endpointSlices []discoveryv1.EndpointSlice) []string {
+	var names []string
+	visited := make(map[string]struct{})
+	for _, endpointSlice := range endpointSlices {
+		for _, endpoint := range endpointSlice.Endpoints {
+			if endpoint.Conditions.Serving == nil || !*endpoint.Conditions.Serving || endpoint.NodeName == nil {
+				continue
+			}
+			if _, exists := visited[*endpoint.NodeName]; exists || *endpoint.NodeName == "" {
+				continue
+			}
+			visited[*endpoint.NodeName] = struct{}{}
+			names = append(names, *endpoint.NodeName)
+		}
+	}
+	return names
+}
+
+// NodeIPsOf reads addresses of nodes from every registered AddressSource.
+func NodeIPsOf(nodes []corev1.Node) NodeIPs {
+	return RegisteredAddressSources().NodeIPsOf(nodes)
+}
+
+// IsNodeReady reports whether node has the Ready condition of True.
+func IsNodeReady(node corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,204 @@
+package staticlb
+
+import (
+	"net"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newNode(name string, ready bool, internalIP, externalIP string) corev1.Node {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	node := corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Addresses:  []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: internalIP}},
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}},
+		},
+	}
+	if externalIP != "" {
+		node.Status.Addresses = append(node.Status.Addresses, corev1.NodeAddress{
+			Type:    corev1.NodeExternalIP,
+			Address: externalIP,
+		})
+	}
+	return node
+}
+
+func newEndpointSlice(nodeNames ...string) discoveryv1.EndpointSlice {
+	serving := true
+	endpoints := make([]discoveryv1.Endpoint, 0, len(nodeNames))
+	for _, nodeName := range nodeNames {
+		nodeName := nodeName
+		endpoints = append(endpoints, discoveryv1.Endpoint{
+			Conditions: discoveryv1.EndpointConditions{Serving: &serving},
+			NodeName:   &nodeName,
+		})
+	}
+	return discoveryv1.EndpointSlice{Endpoints: endpoints}
+}
+
+func newService(
+	svcType corev1.ServiceType,
+	policy corev1.ServiceExternalTrafficPolicyType,
+	annotations map[string]string,
+) corev1.Service {
+	return corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", Annotations: annotations},
+		Spec:       corev1.ServiceSpec{Type: svcType, ExternalTrafficPolicy: policy},
+	}
+}
+
+func TestCompute(t *testing.T) {
+	t.Parallel()
+
+	nodes := []corev1.Node{
+		newNode("a", true, "10.0.0.1", "1.1.1.1"),
+		newNode("b", true, "10.0.0.2", "1.1.1.2"),
+		newNode("c", false, "10.0.0.3", "1.1.1.3"),
+	}
+	cfg := Config{
+		IPMappingPriorities: []IPMappingPriority{{
+			Target: IPMappingTargetIngress,
+			Types:  []NodeAddressType{NodeAddressTypeExternal},
+		}},
+	}
+
+	tests := []struct {
+		name           string
+		svc            corev1.Service
+		endpointSlices []discoveryv1.EndpointSlice
+		cfg            Config
+		expected       IPStatus
+		skipped        bool
+	}{
+		{
+			name:           "not a LoadBalancer: no IPs",
+			svc:            newService(corev1.ServiceTypeClusterIP, corev1.ServiceExternalTrafficPolicyTypeCluster, nil),
+			endpointSlices: []discoveryv1.EndpointSlice{newEndpointSlice("a")},
+			cfg:            cfg,
+			expected:       IPStatus{},
+		},
+		{
+			name:           "unknown externalTrafficPolicy: skipped",
+			svc:            newService(corev1.ServiceTypeLoadBalancer, "", nil),
+			endpointSlices: []discoveryv1.EndpointSlice{newEndpointSlice("a")},
+			cfg:            cfg,
+			skipped:        true,
+		},
+		{
+			name:           "Local: only nodes of serving endpoints, skipping missing ones",
+			svc:            newService(corev1.ServiceTypeLoadBalancer, corev1.ServiceExternalTrafficPolicyTypeLocal, nil),
+			endpointSlices: []discoveryv1.EndpointSlice{newEndpointSlice("b", "b", "z")},
+			cfg:            cfg,
+			expected:       IPStatus{IngressIPs: []string{"1.1.1.2"}},
+		},
+		{
+			name:           "Cluster: every ready node",
+			svc:            newService(corev1.ServiceTypeLoadBalancer, corev1.ServiceExternalTrafficPolicyTypeCluster, nil),
+			endpointSlices: []discoveryv1.EndpointSlice{newEndpointSlice("a")},
+			cfg:            cfg,
+			expected:       IPStatus{IngressIPs: []string{"1.1.1.1", "1.1.1.2"}},
+		},
+		{
+			name:     "Cluster: no serving endpoints, no IPs",
+			svc:      newService(corev1.ServiceTypeLoadBalancer, corev1.ServiceExternalTrafficPolicyTypeCluster, nil),
+			cfg:      cfg,
+			expected: IPStatus{},
+		},
+		{
+			name: "annotations override Config",
+			svc: newService(
+				corev1.ServiceTypeLoadBalancer,
+				corev1.ServiceExternalTrafficPolicyTypeCluster,
+				map[string]string{
+					LabelIPMappings:            "external=internal",
+					LabelNATRules:              "10.0.0.0/24=192.168.0.0/24",
+					LabelExcludeExternalIPNets: "192.168.0.2/32",
+				},
+			),
+			endpointSlices: []discoveryv1.EndpointSlice{newEndpointSlice("a")},
+			cfg:            cfg,
+			expected:       IPStatus{ExternalIPs: []string{"192.168.0.1"}},
+		},
+		{
+			name:           "Config filters",
+			svc:            newService(corev1.ServiceTypeLoadBalancer, corev1.ServiceExternalTrafficPolicyTypeCluster, nil),
+			endpointSlices: []discoveryv1.EndpointSlice{newEndpointSlice("a")},
+			cfg: Config{
+				IPMappingPriorities:  cfg.IPMappingPriorities,
+				IncludeIngressIPNets: []*net.IPNet{{IP: net.IPv4(1, 1, 1, 2), Mask: net.CIDRMask(32, 32)}},
+			},
+			expected: IPStatus{IngressIPs: []string{"1.1.1.2"}},
+		},
+		{
+			name: "requested IPs",
+			svc: newService(
+				corev1.ServiceTypeLoadBalancer,
+				corev1.ServiceExternalTrafficPolicyTypeCluster,
+				map[string]string{LabelRequestedIPs: "1.1.1.2,1.1.1.3"},
+			),
+			endpointSlices: []discoveryv1.EndpointSlice{newEndpointSlice("a")},
+			cfg:            cfg,
+			expected:       IPStatus{IngressIPs: []string{"1.1.1.2"}},
+		},
+		{
+			name:           "Config address sources",
+			svc:            newService(corev1.ServiceTypeLoadBalancer, corev1.ServiceExternalTrafficPolicyTypeLocal, nil),
+			endpointSlices: []discoveryv1.EndpointSlice{newEndpointSlice("a")},
+			cfg: Config{
+				IPMappingPriorities: cfg.IPMappingPriorities,
+				AddressSources: AddressSources{
+					NodeAddressTypeExternal: AddressSourceFunc(func(node corev1.Node) []string {
+						return []string{"203.0.113.1"}
+					}),
+				},
+			},
+			expected: IPStatus{IngressIPs: []string{"203.0.113.1"}},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := Compute(tc.svc, tc.endpointSlices, nodes, tc.cfg)
+			assert.Equal(t, tc.skipped, result.Skipped)
+			assert.Equal(t, tc.expected, result.IPs)
+			assert.NotEmpty(t, result.Trace)
+		})
+	}
+}
+
+func TestCompute_trace(t *testing.T) {
+	t.Parallel()
+
+	svc := newService(
+		corev1.ServiceTypeLoadBalancer,
+		corev1.ServiceExternalTrafficPolicyTypeLocal,
+		map[string]string{LabelExcludeIngressIPNets: "10.0.0.2/32"},
+	)
+	nodes := []corev1.Node{newNode("a", true, "10.0.0.1", ""), newNode("b", true, "10.0.0.2", "")}
+	cfg := Config{InternalIPMappings: []IPMappingTarget{IPMappingTargetIngress}}
+
+	result := Compute(svc, []discoveryv1.EndpointSlice{newEndpointSlice("a", "b", "c")}, nodes, cfg)
+
+	assert.Equal(t, IPStatus{IngressIPs: []string{"10.0.0.1"}}, result.IPs)
+	assert.Equal(t, Trace{
+		{Stage: StageCollect, Message: "externalTrafficPolicy is Local: serving endpoints run on nodes [a b c]"},
+		{Stage: StageCollect, Message: "node c is skipped: not found"},
+		{Stage: StageCollect, Message: "node a has addresses map[internal:[10.0.0.1]]"},
+		{Stage: StageCollect, Message: "node b has addresses map[internal:[10.0.0.2]]"},
+		{Stage: StageMap, Message: "internal IPs [10.0.0.1 10.0.0.2] are mapped to [ingress] (default)"},
+		{Stage: StageMap, Message: "candidates are ingress IPs [10.0.0.1 10.0.0.2] and external IPs []"},
+		{Stage: StageFilter, Message: "ingress IPs [10.0.0.2] are excluded by [10.0.0.2/32] (annotation)"},
+		{Stage: StageFilter, Message: "ingress IPs [10.0.0.1] and external IPs [] are published"},
+	}, result.Trace)
+}
@@ -0,0 +1,76 @@
+package staticlb
+
+type IPMappingTarget string
+
+const (
+	IPMappingTargetIngress  IPMappingTarget = "ingress"
+	IPMappingTargetExternal IPMappingTarget = "external"
+)
+
+// NodeAddressType is the name that an AddressSource is registered as.
+type NodeAddressType string
+
+// Types of built-in AddressSources.
+const (
+	NodeAddressTypeInternal NodeAddressType = "internal"
+	NodeAddressTypeExternal NodeAddressType = "external"
+	// NodeAddressTypeHostname reads hostnames of nodes, which are published only if they are IPs.
+	NodeAddressTypeHostname NodeAddressType = "hostname"
+	// NodeAddressTypeAnnotation reads LabelNodeAddresses of nodes.
+	NodeAddressTypeAnnotation NodeAddressType = "annotation"
+)
+
+type NodeAddressSelection string
+
+const (
+	// NodeAddressSelectionAll takes every address of a type from a node.
+	NodeAddressSelectionAll NodeAddressSelection = "all"
+	// NodeAddressSelectionFirstPerFamily takes only the first IPv4 and the first IPv6 address of a type from a node.
+	NodeAddressSelectionFirstPerFamily NodeAddressSelection = "first-per-family"
+)
+
+// Annotations of a Service that override Config.
+const (
+	LabelIncludeIngressIPNets  = "static-lb.bhyoo.com/include-ingress-ip-nets"
+	LabelIncludeExternalIPNets = "static-lb.bhyoo.com/include-external-ip-nets"
+	LabelExcludeIngressIPNets  = "static-lb.bhyoo.com/exclude-ingress-ip-nets"
+	LabelExcludeExternalIPNets = "static-lb.bhyoo.com/exclude-external-ip-nets"
+
+	LabelInternalIPMappings = "static-lb.bhyoo.com/internal-ip-mappings"
+	LabelExternalIPMappings = "static-lb.bhyoo.com/external-ip-mappings"
+	LabelIPMappings         = "static-lb.bhyoo.com/ip-mappings"
+
+	LabelNodeAddressSelection = "static-lb.bhyoo.com/node-address-selection"
+
+	LabelNATRules            = "static-lb.bhyoo.com/nat-rules"
+	LabelDropUntranslatedIPs = "static-lb.bhyoo.com/drop-untranslated-ips"
+
+	// LabelRequestedIPs lists, separated by commas, IPs that the Service is published on, if they are eligible.
+	// It takes precedence over spec.loadBalancerIP.
+	LabelRequestedIPs = "static-lb.bhyoo.com/requested-ips"
+
+	LabelZones         = "static-lb.bhyoo.com/zones"
+	LabelRegions       = "static-lb.bhyoo.com/regions"
+	LabelMaxIPsPerZone = "static-lb.bhyoo.com/max-ips-per-zone"
+)
+
+// Stage is a step of the pipeline.
+type Stage string
+
+const (
+	// StageCollect selects nodes that receive traffic of the Service and reads their addresses.
+	StageCollect Stage = "collect"
+	// StageMap assigns addresses of nodes to ingress and external IPs.
+	StageMap Stage = "map"
+	// StageTranslate rewrites IPs through NAT rules.
+	StageTranslate Stage = "translate"
+	// StageFilter drops IPs out of included networks or in excluded networks.
+	StageFilter Stage = "filter"
+	// StageTopology drops IPs out of zones and regions, and orders IPs zone by zone.
+	StageTopology Stage = "topology"
+)
+
+const (
+	configSourceAnnotation = "annotation"
+	configSourceDefault    = "default"
+)
@@ -0,0 +1,21 @@
+// Package staticlb computes the IPs that static-lb publishes for a Service, from the Service, its EndpointSlices
+// and Nodes. It is the pipeline that the static-lb controller runs, without any client or cache, so that other
+// programs can embed it:
+//
+//	result := staticlb.Compute(svc, endpointSlices, nodes, staticlb.Config{
+//		IPMappingPriorities: []staticlb.IPMappingPriority{{
+//			Target: staticlb.IPMappingTargetIngress,
+//			Types:  []staticlb.NodeAddressType{staticlb.NodeAddressTypeExternal, staticlb.NodeAddressTypeInternal},
+//		}},
+//	})
+//	fmt.Println(result.IPs.IngressIPs)
+//	fmt.Print(result.Trace)
+//
+// Annotations of the Service override Config the same way as they do for the controller.
+//
+// The package is versioned by APIVersion, apart from releases of static-lb.
+// Exported identifiers are not removed or changed incompatibly without bumping it.
+package staticlb
+
+// APIVersion is the version of the exported API of the package.
+const APIVersion = "v1alpha1"
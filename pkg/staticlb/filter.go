@@ -0,0 +1,165 @@
+package staticlb
+
+import (
+	"net"
+	"strings"
+)
+
+// FilterIPs drops IPs in reserved networks of cfg and in networks excluded, then keeps only IPs in networks included,
+// by annotations or cfg. An empty list of included networks includes every IP.
+func FilterIPs(targetIPs IPStatus, annotations map[string]string, cfg Config) (IPStatus, Trace) {
+	var trace Trace
+
+	ingressIPs := ParseIPs(targetIPs.IngressIPs)
+	externalIPs := ParseIPs(targetIPs.ExternalIPs)
+
+	if len(cfg.ReservedIPNets) != 0 {
+		filtered := filterOutIPs(ingressIPs, cfg.ReservedIPNets)
+		traceDropped(&trace, ingressIPs, filtered, "ingress IPs %v are in reserved %v (%s)", cfg.ReservedIPNets,
+			configSourceDefault)
+		ingressIPs = filtered
+
+		filtered = filterOutIPs(externalIPs, cfg.ReservedIPNets)
+		traceDropped(&trace, externalIPs, filtered, "external IPs %v are in reserved %v (%s)", cfg.ReservedIPNets,
+			configSourceDefault)
+		externalIPs = filtered
+	}
+
+	excludeIngress := getIPNetFrom(annotations, LabelExcludeIngressIPNets, cfg.ExcludeIngressIPNets)
+	filtered := filterOutIPs(ingressIPs, excludeIngress)
+	traceDropped(&trace, ingressIPs, filtered, "ingress IPs %v are excluded by %v (%s)", excludeIngress,
+		sourceOf(annotations, LabelExcludeIngressIPNets))
+	ingressIPs = filtered
+
+	excludeExternal := getIPNetFrom(annotations, LabelExcludeExternalIPNets, cfg.ExcludeExternalIPNets)
+	filtered = filterOutIPs(externalIPs, excludeExternal)
+	traceDropped(&trace, externalIPs, filtered, "external IPs %v are excluded by %v (%s)", excludeExternal,
+		sourceOf(annotations, LabelExcludeExternalIPNets))
+	externalIPs = filtered
+
+	includeIngress := getIPNetFrom(annotations, LabelIncludeIngressIPNets, cfg.IncludeIngressIPNets)
+	filtered = selectIPs(ingressIPs, includeIngress)
+	traceDropped(&trace, ingressIPs, filtered, "ingress IPs %v are not in included %v (%s)", includeIngress,
+		sourceOf(annotations, LabelIncludeIngressIPNets))
+	ingressIPs = filtered
+
+	includeExternal := getIPNetFrom(annotations, LabelIncludeExternalIPNets, cfg.IncludeExternalIPNets)
+	filtered = selectIPs(externalIPs, includeExternal)
+	traceDropped(&trace, externalIPs, filtered, "external IPs %v are not in included %v (%s)", includeExternal,
+		sourceOf(annotations, LabelIncludeExternalIPNets))
+	externalIPs = filtered
+
+	result := IPStatus{
+		IngressIPs:  unparseIPs(ingressIPs),
+		ExternalIPs: unparseIPs(externalIPs),
+	}
+	trace.add(StageFilter, "ingress IPs %v and external IPs %v are published", result.IngressIPs, result.ExternalIPs)
+	return result, trace
+}
+
+// traceDropped adds a Step of IPs in before but not in after, if any, formatted as format with them and nets.
+func traceDropped(trace *Trace, before, after []net.IP, format string, nets []*net.IPNet, source string) {
+	if len(before) == len(after) {
+		return
+	}
+
+	kept := make(map[string]struct{}, len(after))
+	for _, ip := range after {
+		kept[ip.String()] = struct{}{}
+	}
+	var dropped []string
+	for _, ip := range before {
+		if _, exists := kept[ip.String()]; !exists {
+			dropped = append(dropped, ip.String())
+		}
+	}
+	trace.add(StageFilter, format, dropped, nets, source)
+}
+
+// getIPNetFrom expands the annotation with ParseIPNets, ignoring malformed entries, or returns defaultVal if it is
+// absent or its negated entries exclude every IP network.
+func getIPNetFrom(annotations map[string]string, annotationName string, defaultVal []*net.IPNet) []*net.IPNet {
+	val, exists := annotations[annotationName]
+	if !exists {
+		return defaultVal
+	}
+
+	splitted := strings.Split(strings.TrimSpace(val), ",")
+	entries := make([]string, 0, len(splitted))
+	for _, s := range splitted {
+		if _, _, err := parseIPNetEntry(s); err != nil {
+			continue
+		}
+		entries = append(entries, s)
+	}
+
+	ipNets, err := ParseIPNets(entries)
+	if err != nil {
+		return defaultVal
+	}
+	return ipNets
+}
+
+// ParseIPs parses ips, skipping the ones that are not IPs.
+func ParseIPs(ips []string) []net.IP {
+	if len(ips) == 0 {
+		return nil
+	}
+
+	parsedIPs := make([]net.IP, 0, len(ips))
+	for _, ip := range ips {
+		parsedIP := net.ParseIP(ip)
+		if parsedIP == nil {
+			continue
+		}
+		parsedIPs = append(parsedIPs, parsedIP)
+	}
+	return parsedIPs
+}
+
+func unparseIPs(ips []net.IP) []string {
+	if len(ips) == 0 {
+		return nil
+	}
+
+	result := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		result = append(result, ip.String())
+	}
+	return result
+}
+
+func filterOutIPs(src []net.IP, ipNets []*net.IPNet) (result []net.IP) {
+	if len(ipNets) == 0 {
+		return src
+	}
+
+outer:
+	for _, ip := range src {
+		for _, ipNet := range ipNets {
+			if ipNet.Contains(ip) {
+				continue outer
+			}
+		}
+		result = append(result, ip)
+	}
+
+	return result
+}
+
+func selectIPs(src []net.IP, ipNets []*net.IPNet) (result []net.IP) {
+	if len(ipNets) == 0 {
+		return src
+	}
+
+	for _, ip := range src {
+		for _, ipNet := range ipNets {
+			if ipNet.Contains(ip) {
+				result = append(result, ip)
+				break
+			}
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,156 @@
+package staticlb
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// IPClass names a well-known set of networks that lists of IP networks accept in place of a CIDR.
+type IPClass string
+
+const (
+	// IPClassPrivate is RFC 1918 networks of IPv4 and unique local addresses of IPv6, like net.IP.IsPrivate.
+	IPClassPrivate IPClass = "private"
+	// IPClassPublic is every IPv4 address out of 0.0.0.0/8, private, cgnat, loopback, link-local, multicast and
+	// reserved (240.0.0.0/4) networks, and global unicast addresses (2000::/3) of IPv6.
+	IPClassPublic    IPClass = "public"
+	IPClassCGNAT     IPClass = "cgnat"
+	IPClassLinkLocal IPClass = "link-local"
+	IPClassULA       IPClass = "ula"
+	IPClassLoopback  IPClass = "loopback"
+	IPClassIPv4      IPClass = "ipv4"
+	IPClassIPv6      IPClass = "ipv6"
+)
+
+var (
+	ipv4Nets    = mustParseCIDRs("0.0.0.0/0")
+	ipv6Nets    = mustParseCIDRs("::/0")
+	privateNets = mustParseCIDRs("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7")
+	cgnatNets   = mustParseCIDRs("100.64.0.0/10")
+	ulaNets     = mustParseCIDRs("fc00::/7")
+
+	ipClasses = map[IPClass][]*net.IPNet{
+		IPClassPrivate: privateNets,
+		IPClassPublic: append(
+			subtractIPNets(ipv4Nets, concatIPNets(
+				mustParseCIDRs("0.0.0.0/8", "224.0.0.0/4", "240.0.0.0/4"),
+				privateNets,
+				cgnatNets,
+				linkLocalIPNets,
+				loopbackIPNets,
+			)),
+			mustParseCIDRs("2000::/3")...,
+		),
+		IPClassCGNAT:     cgnatNets,
+		IPClassLinkLocal: linkLocalIPNets,
+		IPClassULA:       ulaNets,
+		IPClassLoopback:  loopbackIPNets,
+		IPClassIPv4:      ipv4Nets,
+		IPClassIPv6:      ipv6Nets,
+	}
+)
+
+// ParseIPNets expands entries into IP networks. An entry is a CIDR or an IPClass, either of which may be negated with
+// a leading "!". The result covers every network of entries that are not negated, or every IP if all entries are
+// negated, except networks of negated entries. It fails if negated entries cover every other entry, since the empty
+// result would filter nothing instead of everything.
+func ParseIPNets(entries []string) ([]*net.IPNet, error) {
+	var included, excluded []*net.IPNet
+	for _, entry := range entries {
+		ipNets, negated, err := parseIPNetEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		if negated {
+			excluded = append(excluded, ipNets...)
+		} else {
+			included = append(included, ipNets...)
+		}
+	}
+
+	if len(excluded) == 0 {
+		return included, nil
+	}
+	if len(included) == 0 {
+		included = concatIPNets(ipv4Nets, ipv6Nets)
+	}
+	result := subtractIPNets(included, excluded)
+	if len(result) == 0 {
+		return nil, fmt.Errorf("negated entries exclude every IP network: %s", strings.Join(entries, ","))
+	}
+	return result, nil
+}
+
+func parseIPNetEntry(entry string) (ipNets []*net.IPNet, negated bool, err error) {
+	entry = strings.TrimSpace(entry)
+	if rest, found := strings.CutPrefix(entry, "!"); found {
+		entry = strings.TrimSpace(rest)
+		negated = true
+	}
+
+	if classNets, exists := ipClasses[IPClass(entry)]; exists {
+		return classNets, negated, nil
+	}
+
+	_, ipNet, err := net.ParseCIDR(entry)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid IP network or class: %s", entry)
+	}
+	return []*net.IPNet{ipNet}, negated, nil
+}
+
+// subtractIPNets returns networks that cover addresses of from that no network of nets contains.
+func subtractIPNets(from []*net.IPNet, nets []*net.IPNet) []*net.IPNet {
+	result := from
+	for _, n := range nets {
+		var remaining []*net.IPNet
+		for _, r := range result {
+			remaining = append(remaining, subtractIPNet(r, n)...)
+		}
+		result = remaining
+	}
+	return result
+}
+
+// subtractIPNet splits from into halves until no half partially overlaps with n, dropping halves inside n.
+func subtractIPNet(from *net.IPNet, n *net.IPNet) []*net.IPNet {
+	fromOnes, fromBits := from.Mask.Size()
+	nOnes, nBits := n.Mask.Size()
+	switch {
+	case fromBits != nBits:
+		return []*net.IPNet{from}
+	case nOnes <= fromOnes && n.Contains(from.IP):
+		return nil
+	case nOnes <= fromOnes || !from.Contains(n.IP):
+		return []*net.IPNet{from}
+	}
+
+	mask := net.CIDRMask(fromOnes+1, fromBits)
+	lower := &net.IPNet{IP: from.IP.Mask(mask), Mask: mask}
+	upperIP := make(net.IP, len(lower.IP))
+	copy(upperIP, lower.IP)
+	upperIP[fromOnes/8] |= 0x80 >> (fromOnes % 8)
+	upper := &net.IPNet{IP: upperIP, Mask: mask}
+
+	return append(subtractIPNet(lower, n), subtractIPNet(upper, n)...)
+}
+
+func concatIPNets(lists ...[]*net.IPNet) (result []*net.IPNet) {
+	for _, list := range lists {
+		result = append(result, list...)
+	}
+	return result
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	ipNets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		ipNets = append(ipNets, ipNet)
+	}
+	return ipNets
+}
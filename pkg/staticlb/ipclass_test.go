@@ -0,0 +1,162 @@
+package staticlb
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIPNets(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		entries     []string
+		expected    []string
+		expectedErr bool
+	}{
+		{
+			name:     "empty",
+			entries:  nil,
+			expected: []string{},
+		},
+		{
+			name:     "CIDRs",
+			entries:  []string{"10.0.0.0/8", " 2001:db8::/32 "},
+			expected: []string{"10.0.0.0/8", "2001:db8::/32"},
+		},
+		{
+			name:     "class",
+			entries:  []string{"private"},
+			expected: []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"},
+		},
+		{
+			name:     "negated CIDR splits the included network",
+			entries:  []string{"10.0.0.0/8", "!10.0.0.0/9"},
+			expected: []string{"10.128.0.0/9"},
+		},
+		{
+			name:     "negated inner network",
+			entries:  []string{"cgnat", "!100.64.0.0/11"},
+			expected: []string{"100.96.0.0/11"},
+		},
+		{
+			name:     "negation is independent of order",
+			entries:  []string{"!10.0.0.0/9", "10.0.0.0/8"},
+			expected: []string{"10.128.0.0/9"},
+		},
+		{
+			name:     "only negated entries negate every IP",
+			entries:  []string{"!ipv4", "!2000::/3"},
+			expected: []string{"::/3", "4000::/2", "8000::/1"},
+		},
+		{
+			name:        "negated entries cover every other entry",
+			entries:     []string{"loopback", "!127.0.0.0/8", "!::1/128"},
+			expectedErr: true,
+		},
+		{
+			name:        "negated entries cover every IP",
+			entries:     []string{"!ipv4", "!ipv6"},
+			expectedErr: true,
+		},
+		{
+			name:        "unknown class",
+			entries:     []string{"global"},
+			expectedErr: true,
+		},
+		{
+			name:        "invalid negated CIDR",
+			entries:     []string{"!10.0.0.0/33"},
+			expectedErr: true,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ipNets, err := ParseIPNets(tc.entries)
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			actual := make([]string, 0, len(ipNets))
+			for _, ipNet := range ipNets {
+				actual = append(actual, ipNet.String())
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestIPClasses(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		class    IPClass
+		included []string
+		excluded []string
+	}{
+		{
+			class:    IPClassPrivate,
+			included: []string{"10.1.2.3", "172.31.255.255", "192.168.0.1", "fd00::1"},
+			excluded: []string{"172.32.0.1", "100.64.0.1", "8.8.8.8", "2001:db8::1"},
+		},
+		{
+			class:    IPClassPublic,
+			included: []string{"1.1.1.1", "8.8.8.8", "100.128.0.1", "223.255.255.255", "2001:db8::1"},
+			excluded: []string{"0.1.2.3", "10.0.0.1", "100.64.0.1", "127.0.0.1", "169.254.0.1", "172.16.0.1",
+				"192.168.1.1", "224.0.0.1", "255.255.255.255", "::1", "fd00::1", "fe80::1"},
+		},
+		{
+			class:    IPClassCGNAT,
+			included: []string{"100.64.0.1", "100.127.255.255"},
+			excluded: []string{"100.128.0.1", "10.0.0.1"},
+		},
+		{
+			class:    IPClassLinkLocal,
+			included: []string{"169.254.1.1", "fe80::1"},
+			excluded: []string{"169.255.0.1", "fec0::1"},
+		},
+		{
+			class:    IPClassULA,
+			included: []string{"fc00::1", "fdff::1"},
+			excluded: []string{"fe80::1", "10.0.0.1"},
+		},
+		{
+			class:    IPClassLoopback,
+			included: []string{"127.0.0.1", "127.255.0.1", "::1"},
+			excluded: []string{"128.0.0.1", "::2"},
+		},
+		{
+			class:    IPClassIPv4,
+			included: []string{"0.0.0.0", "255.255.255.255"},
+			excluded: []string{"::", "2001:db8::1"},
+		},
+		{
+			class:    IPClassIPv6,
+			included: []string{"::", "2001:db8::1"},
+			excluded: []string{"0.0.0.0", "10.0.0.1"},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(string(tc.class), func(t *testing.T) {
+			t.Parallel()
+
+			ipNets, err := ParseIPNets([]string{string(tc.class)})
+			require.NoError(t, err)
+			for _, ip := range tc.included {
+				assert.NotEmpty(t, selectIPs([]net.IP{net.ParseIP(ip)}, ipNets), ip)
+			}
+			for _, ip := range tc.excluded {
+				assert.Empty(t, selectIPs([]net.IP{net.ParseIP(ip)}, ipNets), ip)
+			}
+		})
+	}
+}
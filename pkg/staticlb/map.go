@@ -0,0 +1,254 @@
+package staticlb
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// MapIPs assigns addresses of nodeIPs to ingress and external IPs, by mappings of annotations or cfg.
+func MapIPs(nodeIPs NodeIPs, annotations map[string]string, cfg Config) (targetIPs IPStatus, trace Trace) {
+	selection := getNodeAddressSelection(annotations, LabelNodeAddressSelection, cfg.NodeAddressSelection)
+	if selection == NodeAddressSelectionFirstPerFamily {
+		trace.add(StageMap, "only the first address per IP family of each type is taken from each node (%s)",
+			sourceOf(annotations, LabelNodeAddressSelection))
+	}
+	nodeIPs = selectNodeAddresses(nodeIPs, selection)
+
+	internalIPs := nodeIPs.IPsOf(NodeAddressTypeInternal)
+	internalMappings := getMappings(annotations, LabelInternalIPMappings, cfg.InternalIPMappings)
+	for _, mapping := range internalMappings {
+		switch mapping {
+		case IPMappingTargetExternal:
+			targetIPs.ExternalIPs = append(targetIPs.ExternalIPs, internalIPs...)
+		case IPMappingTargetIngress:
+			targetIPs.IngressIPs = append(targetIPs.IngressIPs, internalIPs...)
+		default:
+			break
+		}
+	}
+	if len(internalMappings) != 0 {
+		trace.add(StageMap, "internal IPs %v are mapped to %v (%s)", internalIPs, internalMappings,
+			sourceOf(annotations, LabelInternalIPMappings))
+	}
+
+	externalIPs := nodeIPs.IPsOf(NodeAddressTypeExternal)
+	externalMappings := getMappings(annotations, LabelExternalIPMappings, cfg.ExternalIPMappings)
+	for _, mapping := range externalMappings {
+		switch mapping {
+		case IPMappingTargetExternal:
+			targetIPs.ExternalIPs = append(targetIPs.ExternalIPs, externalIPs...)
+		case IPMappingTargetIngress:
+			targetIPs.IngressIPs = append(targetIPs.IngressIPs, externalIPs...)
+		default:
+			break
+		}
+	}
+	if len(externalMappings) != 0 {
+		trace.add(StageMap, "external IPs %v are mapped to %v (%s)", externalIPs, externalMappings,
+			sourceOf(annotations, LabelExternalIPMappings))
+	}
+
+	for _, priority := range getMappingPriorities(annotations, LabelIPMappings, cfg.IPMappingPriorities) {
+		ips := nodeIPs.prioritizedIPs(priority.Types)
+		switch priority.Target {
+		case IPMappingTargetExternal:
+			targetIPs.ExternalIPs = append(targetIPs.ExternalIPs, ips...)
+		case IPMappingTargetIngress:
+			targetIPs.IngressIPs = append(targetIPs.IngressIPs, ips...)
+		default:
+			break
+		}
+		trace.add(StageMap, "IPs %v are mapped by %s (%s)", ips, priority, sourceOf(annotations, LabelIPMappings))
+	}
+
+	targetIPs.IngressIPs = uniqueIPs(targetIPs.IngressIPs)
+	targetIPs.ExternalIPs = uniqueIPs(targetIPs.ExternalIPs)
+	trace.add(StageMap, "candidates are ingress IPs %v and external IPs %v", targetIPs.IngressIPs,
+		targetIPs.ExternalIPs)
+	return targetIPs, trace
+}
+
+// prioritizedIPs returns, for each node, IPs of the first type in types that the node has IPs of. Addresses that are
+// not IPs, e.g. hostnames, are skipped, so that the next type is taken instead.
+func (n NodeIPs) prioritizedIPs(types []NodeAddressType) (ips []string) {
+	for _, node := range n.Nodes {
+		for _, addressType := range types {
+			if nodeIPs := onlyIPs(node.IPsOf(addressType)); len(nodeIPs) != 0 {
+				ips = append(ips, nodeIPs...)
+				break
+			}
+		}
+	}
+	return ips
+}
+
+func onlyIPs(addresses []string) (ips []string) {
+	for _, address := range addresses {
+		if net.ParseIP(address) != nil {
+			ips = append(ips, address)
+		}
+	}
+	return ips
+}
+
+func selectNodeAddresses(nodeIPs NodeIPs, selection NodeAddressSelection) NodeIPs {
+	if selection != NodeAddressSelectionFirstPerFamily {
+		return nodeIPs
+	}
+
+	result := NodeIPs{Nodes: make([]NodeAddresses, len(nodeIPs.Nodes))}
+	for i, node := range nodeIPs.Nodes {
+		result.Nodes[i] = NodeAddresses{Name: node.Name, Addresses: make(map[NodeAddressType][]string)}
+		for addressType, addresses := range node.Addresses {
+			result.Nodes[i].Addresses[addressType] = firstIPPerFamily(addresses)
+		}
+	}
+	return result
+}
+
+func firstIPPerFamily(ips []string) (result []string) {
+	var hasIPv4, hasIPv6 bool
+	for _, ip := range ips {
+		parsed := net.ParseIP(ip)
+		switch {
+		case parsed == nil:
+			continue
+		case parsed.To4() != nil && !hasIPv4:
+			hasIPv4 = true
+			result = append(result, ip)
+		case parsed.To4() == nil && !hasIPv6:
+			hasIPv6 = true
+			result = append(result, ip)
+		}
+	}
+	return result
+}
+
+func uniqueIPs(ips []string) []string {
+	if len(ips) == 0 {
+		return ips
+	}
+
+	visited := make(map[string]struct{}, len(ips))
+	result := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		if _, exists := visited[ip]; exists {
+			continue
+		}
+		visited[ip] = struct{}{}
+		result = append(result, ip)
+	}
+	return result
+}
+
+func getMappings(annotations map[string]string, annotationName string, defaultVal []IPMappingTarget) []IPMappingTarget {
+	annotation, exists := annotations[annotationName]
+	if !exists {
+		return defaultVal
+	}
+	if annotation == "" {
+		return nil
+	}
+
+	splitted := strings.Split(annotation, ",")
+	result := make([]IPMappingTarget, 0, len(splitted))
+	for _, s := range splitted {
+		switch IPMappingTarget(s) {
+		case IPMappingTargetExternal:
+			result = append(result, IPMappingTargetExternal)
+		case IPMappingTargetIngress:
+			result = append(result, IPMappingTargetIngress)
+		default:
+			continue
+		}
+	}
+
+	return result
+}
+
+// ParseIPMappingPriority parses a mapping in form of target=type|type... (e.g. ingress=external|internal).
+// Each type must be registered by RegisterAddressSource.
+func ParseIPMappingPriority(s string) (IPMappingPriority, error) {
+	target, types, found := strings.Cut(strings.TrimSpace(s), "=")
+	if !found {
+		return IPMappingPriority{}, fmt.Errorf("IP mapping must be in form of target=type|type...: %s", s)
+	}
+
+	result := IPMappingPriority{Target: IPMappingTarget(target)}
+	switch result.Target {
+	case IPMappingTargetIngress, IPMappingTargetExternal:
+	default:
+		return IPMappingPriority{}, fmt.Errorf("invalid mapping target: %s", target)
+	}
+
+	for _, t := range strings.Split(types, "|") {
+		addressType := NodeAddressType(t)
+		if !isRegisteredAddressType(addressType) {
+			return IPMappingPriority{}, fmt.Errorf("unknown node address type: %s", t)
+		}
+		result.Types = append(result.Types, addressType)
+	}
+
+	return result, nil
+}
+
+func (p IPMappingPriority) String() string {
+	types := make([]string, len(p.Types))
+	for i, t := range p.Types {
+		types[i] = string(t)
+	}
+	return fmt.Sprintf("%s=%s", p.Target, strings.Join(types, "|"))
+}
+
+func getMappingPriorities(
+	annotations map[string]string,
+	annotationName string,
+	defaultVal []IPMappingPriority,
+) []IPMappingPriority {
+	annotation, exists := annotations[annotationName]
+	if !exists {
+		return defaultVal
+	}
+	if annotation == "" {
+		return nil
+	}
+
+	splitted := strings.Split(annotation, ",")
+	result := make([]IPMappingPriority, 0, len(splitted))
+	for _, s := range splitted {
+		priority, err := ParseIPMappingPriority(s)
+		if err != nil {
+			continue
+		}
+		result = append(result, priority)
+	}
+
+	return result
+}
+
+func ParseNodeAddressSelection(s string) (NodeAddressSelection, error) {
+	switch selection := NodeAddressSelection(s); selection {
+	case NodeAddressSelectionAll, NodeAddressSelectionFirstPerFamily:
+		return selection, nil
+	default:
+		return "", fmt.Errorf("invalid node address selection: %s", s)
+	}
+}
+
+func getNodeAddressSelection(
+	annotations map[string]string,
+	annotationName string,
+	defaultVal NodeAddressSelection,
+) NodeAddressSelection {
+	annotation, exists := annotations[annotationName]
+	if !exists {
+		return defaultVal
+	}
+
+	selection, err := ParseNodeAddressSelection(annotation)
+	if err != nil {
+		return defaultVal
+	}
+	return selection
+}
@@ -0,0 +1,72 @@
+package staticlb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIPMappingPriority(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		value    string
+		expected IPMappingPriority
+		hasError bool
+	}{
+		{
+			name:  "single type",
+			value: "external=internal",
+			expected: IPMappingPriority{
+				Target: IPMappingTargetExternal,
+				Types:  []NodeAddressType{NodeAddressTypeInternal},
+			},
+		},
+		{
+			name:  "priority",
+			value: "ingress=external|internal",
+			expected: IPMappingPriority{
+				Target: IPMappingTargetIngress,
+				Types:  []NodeAddressType{NodeAddressTypeExternal, NodeAddressTypeInternal},
+			},
+		},
+		{
+			name:     "invalid target",
+			value:    "status=external",
+			hasError: true,
+		},
+		{
+			name:  "built-in sources",
+			value: "ingress=annotation|hostname",
+			expected: IPMappingPriority{
+				Target: IPMappingTargetIngress,
+				Types:  []NodeAddressType{NodeAddressTypeAnnotation, NodeAddressTypeHostname},
+			},
+		},
+		{
+			name:     "unknown type",
+			value:    "ingress=unknown",
+			hasError: true,
+		},
+		{
+			name:     "no separator",
+			value:    "ingress",
+			hasError: true,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := ParseIPMappingPriority(tc.value)
+			if tc.hasError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
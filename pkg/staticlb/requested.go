@@ -0,0 +1,54 @@
+package staticlb
+
+import (
+	"net"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// RequestedIPsOf returns IPs that svc asks to be published on, from LabelRequestedIPs or, without it, from
+// spec.loadBalancerIP. Invalid IPs are skipped.
+func RequestedIPsOf(svc corev1.Service) []string {
+	val, exists := svc.Annotations[LabelRequestedIPs]
+	if !exists {
+		val = svc.Spec.LoadBalancerIP
+	}
+
+	var ips []string
+	for _, s := range strings.Split(val, ",") {
+		if ip := net.ParseIP(strings.TrimSpace(s)); ip != nil {
+			ips = append(ips, ip.String())
+		}
+	}
+	return ips
+}
+
+// ConstrainToRequestedIPs keeps only requested IPs of svc in targetIPs, if svc requests any. Requested IPs that are
+// not in targetIPs are not published, even if none remains.
+func ConstrainToRequestedIPs(targetIPs IPStatus, svc corev1.Service) (IPStatus, Trace) {
+	var trace Trace
+
+	requested := RequestedIPsOf(svc)
+	if len(requested) == 0 {
+		return targetIPs, trace
+	}
+
+	requestedSet := make(map[string]struct{}, len(requested))
+	for _, ip := range requested {
+		requestedSet[ip] = struct{}{}
+	}
+	keep := func(ips []string) (result []string) {
+		for _, ip := range ips {
+			if _, exists := requestedSet[ip]; exists {
+				result = append(result, ip)
+			}
+		}
+		return result
+	}
+
+	result := IPStatus{IngressIPs: keep(targetIPs.IngressIPs), ExternalIPs: keep(targetIPs.ExternalIPs)}
+	trace.add(StageFilter, "IPs %v are requested: ingress IPs %v and external IPs %v are published", requested,
+		result.IngressIPs, result.ExternalIPs)
+	return result, trace
+}
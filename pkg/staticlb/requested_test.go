@@ -0,0 +1,61 @@
+package staticlb
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConstrainToRequestedIPs(t *testing.T) {
+	t.Parallel()
+
+	targetIPs := IPStatus{IngressIPs: []string{"10.0.0.1", "10.0.0.2"}, ExternalIPs: []string{"10.0.0.2"}}
+
+	tests := []struct {
+		name     string
+		svc      corev1.Service
+		expected IPStatus
+	}{
+		{
+			name:     "nothing requested",
+			expected: targetIPs,
+		},
+		{
+			name:     "loadBalancerIP",
+			svc:      corev1.Service{Spec: corev1.ServiceSpec{LoadBalancerIP: "10.0.0.2"}},
+			expected: IPStatus{IngressIPs: []string{"10.0.0.2"}, ExternalIPs: []string{"10.0.0.2"}},
+		},
+		{
+			name: "annotation over loadBalancerIP",
+			svc: corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{LabelRequestedIPs: "10.0.0.1, 10.0.0.3"}},
+				Spec:       corev1.ServiceSpec{LoadBalancerIP: "10.0.0.2"},
+			},
+			expected: IPStatus{IngressIPs: []string{"10.0.0.1"}},
+		},
+		{
+			name: "invalid IPs are skipped",
+			svc: corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{LabelRequestedIPs: "invalid,10.0.0.1"}},
+			},
+			expected: IPStatus{IngressIPs: []string{"10.0.0.1"}},
+		},
+		{
+			name:     "nothing eligible",
+			svc:      corev1.Service{Spec: corev1.ServiceSpec{LoadBalancerIP: "10.0.0.3"}},
+			expected: IPStatus{},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, _ := ConstrainToRequestedIPs(targetIPs, tc.svc)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package staticlb
+
+import (
+	"net"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Networks that never hold addresses that clients reach nodes at.
+var (
+	loopbackIPNets = []*net.IPNet{
+		{IP: net.IPv4(127, 0, 0, 0).To4(), Mask: net.CIDRMask(8, 32)},
+		{IP: net.IPv6loopback, Mask: net.CIDRMask(128, 128)},
+	}
+	linkLocalIPNets = []*net.IPNet{
+		{IP: net.IPv4(169, 254, 0, 0).To4(), Mask: net.CIDRMask(16, 32)},
+		{IP: net.ParseIP("fe80::"), Mask: net.CIDRMask(10, 128)},
+	}
+)
+
+// ClusterIPNets returns networks that are internal to the cluster: pod CIDRs of nodes, serviceCIDRs, and loopback and
+// link-local ranges. Some CNIs report addresses of their interfaces in those networks as addresses of nodes.
+func ClusterIPNets(nodes []corev1.Node, serviceCIDRs []*net.IPNet) []*net.IPNet {
+	var result []*net.IPNet
+	visited := make(map[string]struct{})
+	add := func(ipNet *net.IPNet) {
+		if _, exists := visited[ipNet.String()]; exists {
+			return
+		}
+		visited[ipNet.String()] = struct{}{}
+		result = append(result, ipNet)
+	}
+
+	for _, node := range nodes {
+		podCIDRs := node.Spec.PodCIDRs
+		if len(podCIDRs) == 0 && node.Spec.PodCIDR != "" {
+			podCIDRs = []string{node.Spec.PodCIDR}
+		}
+		for _, podCIDR := range podCIDRs {
+			if _, ipNet, err := net.ParseCIDR(podCIDR); err == nil {
+				add(ipNet)
+			}
+		}
+	}
+	for _, ipNet := range serviceCIDRs {
+		add(ipNet)
+	}
+	for _, ipNet := range loopbackIPNets {
+		add(ipNet)
+	}
+	for _, ipNet := range linkLocalIPNets {
+		add(ipNet)
+	}
+	return result
+}
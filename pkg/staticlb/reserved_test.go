@@ -0,0 +1,45 @@
+package staticlb
+
+import (
+	"net"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterIPNets(t *testing.T) {
+	t.Parallel()
+
+	nodes := []corev1.Node{
+		{Spec: corev1.NodeSpec{PodCIDR: "10.244.0.0/24", PodCIDRs: []string{"10.244.0.0/24", "fd00:10:244::/64"}}},
+		{Spec: corev1.NodeSpec{PodCIDR: "10.244.1.0/24"}},
+		{Spec: corev1.NodeSpec{PodCIDRs: []string{"10.244.0.0/24", "invalid"}}},
+	}
+	_, serviceCIDR, _ := net.ParseCIDR("10.96.0.0/12")
+
+	actual := ClusterIPNets(nodes, []*net.IPNet{serviceCIDR})
+
+	strs := make([]string, len(actual))
+	for i, ipNet := range actual {
+		strs[i] = ipNet.String()
+	}
+	assert.Equal(t, []string{
+		"10.244.0.0/24",
+		"fd00:10:244::/64",
+		"10.244.1.0/24",
+		"10.96.0.0/12",
+		"127.0.0.0/8",
+		"::1/128",
+		"169.254.0.0/16",
+		"fe80::/10",
+	}, strs)
+
+	filtered, _ := FilterIPs(
+		IPStatus{IngressIPs: []string{"10.244.1.5", "192.168.0.1"}, ExternalIPs: []string{"169.254.1.1", "fe80::1"}},
+		map[string]string{LabelIncludeIngressIPNets: "10.0.0.0/8,192.168.0.0/16"},
+		Config{ReservedIPNets: actual},
+	)
+	assert.Equal(t, IPStatus{IngressIPs: []string{"192.168.0.1"}}, filtered)
+}
@@ -0,0 +1,183 @@
+package staticlb
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// AddressSource reads addresses of a type from nodes. Sources are registered by the NodeAddressType that mappings
+// refer to, e.g. "ingress=annotation|external".
+type AddressSource interface {
+	// Addresses returns addresses of node, or nil if node has none. Addresses that are not IPs are dropped later.
+	Addresses(node corev1.Node) []string
+}
+
+// AnnotationAddressSource is an AddressSource that reads annotations of nodes. Node annotations are dropped from the
+// informer cache except ones of static-lb and ones that registered sources name by AnnotationKeys.
+type AnnotationAddressSource interface {
+	AddressSource
+	// AnnotationKeys returns keys of node annotations that Addresses reads.
+	AnnotationKeys() []string
+}
+
+// AddressSources are AddressSources by the NodeAddressType that mappings refer to.
+type AddressSources map[NodeAddressType]AddressSource
+
+// AddressSourceFunc is an AddressSource of a function.
+type AddressSourceFunc func(node corev1.Node) []string
+
+func (f AddressSourceFunc) Addresses(node corev1.Node) []string {
+	return f(node)
+}
+
+// LabelNodeAddresses lists, separated by commas, addresses of a Node that NodeAddressTypeAnnotation reads.
+const LabelNodeAddresses = "static-lb.bhyoo.com/node-addresses"
+
+var addressTypePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+var addressSources = struct {
+	sync.RWMutex
+	byType map[NodeAddressType]AddressSource
+}{
+	byType: map[NodeAddressType]AddressSource{
+		NodeAddressTypeInternal:   NodeAddressOfType(corev1.NodeInternalIP),
+		NodeAddressTypeExternal:   NodeAddressOfType(corev1.NodeExternalIP),
+		NodeAddressTypeHostname:   NodeAddressOfType(corev1.NodeHostName),
+		NodeAddressTypeAnnotation: NodeAnnotation(LabelNodeAddresses),
+	},
+}
+
+// RegisterAddressSource registers source as addressType, so that mappings can refer to it. It is meant to be called
+// from init functions of packages that are compiled in. addressType must be a DNS-1123 label that is not registered.
+// A source that reads node annotations out of the static-lb domain must be an AnnotationAddressSource, like ones of
+// NodeAnnotation, or the annotations are not cached.
+func RegisterAddressSource(addressType NodeAddressType, source AddressSource) error {
+	if !addressTypePattern.MatchString(string(addressType)) {
+		return fmt.Errorf("invalid node address type: %s", addressType)
+	}
+
+	addressSources.Lock()
+	defer addressSources.Unlock()
+	if _, exists := addressSources.byType[addressType]; exists {
+		return fmt.Errorf("node address type is already registered: %s", addressType)
+	}
+	addressSources.byType[addressType] = source
+	return nil
+}
+
+// RegisteredAddressTypes returns every registered NodeAddressType in order.
+func RegisteredAddressTypes() []NodeAddressType {
+	addressSources.RLock()
+	defer addressSources.RUnlock()
+
+	types := make([]NodeAddressType, 0, len(addressSources.byType))
+	for addressType := range addressSources.byType {
+		types = append(types, addressType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// RegisteredAddressSources returns a copy of every registered AddressSource.
+func RegisteredAddressSources() AddressSources {
+	addressSources.RLock()
+	defer addressSources.RUnlock()
+
+	sources := make(AddressSources, len(addressSources.byType))
+	for addressType, source := range addressSources.byType {
+		sources[addressType] = source
+	}
+	return sources
+}
+
+// NodeAnnotationKeys returns keys of node annotations that registered AnnotationAddressSources read, in order.
+func NodeAnnotationKeys() []string {
+	addressSources.RLock()
+	defer addressSources.RUnlock()
+
+	var keys []string
+	for _, source := range addressSources.byType {
+		if source, ok := source.(AnnotationAddressSource); ok {
+			keys = append(keys, source.AnnotationKeys()...)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func isRegisteredAddressType(addressType NodeAddressType) bool {
+	addressSources.RLock()
+	defer addressSources.RUnlock()
+	_, exists := addressSources.byType[addressType]
+	return exists
+}
+
+// NodeAddressOfType reads addresses of addressType from status.addresses of nodes.
+func NodeAddressOfType(addressType corev1.NodeAddressType) AddressSource {
+	return AddressSourceFunc(func(node corev1.Node) (addresses []string) {
+		for _, address := range node.Status.Addresses {
+			if address.Type == addressType {
+				addresses = append(addresses, address.Address)
+			}
+		}
+		return addresses
+	})
+}
+
+// NodeAnnotation reads addresses, separated by commas, from the annotation of key of nodes. Invalid addresses are
+// skipped.
+func NodeAnnotation(key string) AnnotationAddressSource {
+	return nodeAnnotation(key)
+}
+
+type nodeAnnotation string
+
+func (key nodeAnnotation) Addresses(node corev1.Node) (addresses []string) {
+	val := strings.TrimSpace(node.Annotations[string(key)])
+	if val == "" {
+		return nil
+	}
+	for _, s := range strings.Split(val, ",") {
+		if ip := net.ParseIP(strings.TrimSpace(s)); ip != nil {
+			addresses = append(addresses, ip.String())
+		}
+	}
+	return addresses
+}
+
+func (key nodeAnnotation) AnnotationKeys() []string {
+	return []string{string(key)}
+}
+
+// NodeIPsOf reads addresses of nodes from every source of s.
+func (s AddressSources) NodeIPsOf(nodes []corev1.Node) (result NodeIPs) {
+	result.Nodes = make([]NodeAddresses, 0, len(nodes))
+	for _, node := range nodes {
+		result.Nodes = append(result.Nodes, s.addressesOf(node))
+	}
+	return result
+}
+
+// addressesOf reads addresses of node from every source of s, along with its zone and region.
+func (s AddressSources) addressesOf(node corev1.Node) NodeAddresses {
+	result := NodeAddresses{
+		Name:   node.Name,
+		Zone:   node.Labels[corev1.LabelTopologyZone],
+		Region: node.Labels[corev1.LabelTopologyRegion],
+	}
+	for addressType, source := range s {
+		if addresses := source.Addresses(node); len(addresses) != 0 {
+			if result.Addresses == nil {
+				result.Addresses = make(map[NodeAddressType][]string)
+			}
+			result.Addresses[addressType] = addresses
+		}
+	}
+	return result
+}
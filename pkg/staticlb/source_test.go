@@ -0,0 +1,76 @@
+package staticlb
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeIPsOf(t *testing.T) {
+	t.Parallel()
+
+	node := corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "a",
+			Annotations: map[string]string{LabelNodeAddresses: "198.51.100.1, invalid,2001:db8::1"},
+		},
+		Status: corev1.NodeStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+				{Type: corev1.NodeInternalIP, Address: "fd00::1"},
+				{Type: corev1.NodeExternalIP, Address: "203.0.113.1"},
+				{Type: corev1.NodeHostName, Address: "node-a"},
+			},
+		},
+	}
+
+	actual := NodeIPsOf([]corev1.Node{node, {ObjectMeta: metav1.ObjectMeta{Name: "b"}}})
+	assert.Equal(t, NodeIPs{Nodes: []NodeAddresses{
+		{
+			Name: "a",
+			Addresses: map[NodeAddressType][]string{
+				NodeAddressTypeInternal:   {"10.0.0.1", "fd00::1"},
+				NodeAddressTypeExternal:   {"203.0.113.1"},
+				NodeAddressTypeHostname:   {"node-a"},
+				NodeAddressTypeAnnotation: {"198.51.100.1", "2001:db8::1"},
+			},
+		},
+		{Name: "b"},
+	}}, actual)
+}
+
+//nolint:paralleltest // modifies the global registry of AddressSources
+func TestRegisterAddressSource(t *testing.T) {
+	source := AddressSourceFunc(func(node corev1.Node) []string {
+		if ip, exists := node.Labels["example.com/ip"]; exists {
+			return []string{ip}
+		}
+		return nil
+	})
+
+	assert.Error(t, RegisterAddressSource(NodeAddressTypeInternal, source), "built-in types are registered")
+	assert.Error(t, RegisterAddressSource("Invalid|Name", source))
+
+	require.NoError(t, RegisterAddressSource("example-label", source))
+	t.Cleanup(func() {
+		addressSources.Lock()
+		defer addressSources.Unlock()
+		delete(addressSources.byType, "example-label")
+		delete(addressSources.byType, "example-annotation")
+	})
+	assert.Contains(t, RegisteredAddressTypes(), NodeAddressType("example-label"))
+
+	require.NoError(t, RegisterAddressSource("example-annotation", NodeAnnotation("example.com/addresses")))
+	assert.Equal(t, []string{"example.com/addresses", LabelNodeAddresses}, NodeAnnotationKeys())
+
+	priority, err := ParseIPMappingPriority("ingress=example-label")
+	require.NoError(t, err)
+
+	node := corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "a", Labels: map[string]string{"example.com/ip": "10.1.0.1"}}}
+	ips, _ := MapIPs(NodeIPsOf([]corev1.Node{node}), nil, Config{IPMappingPriorities: []IPMappingPriority{priority}})
+	assert.Equal(t, IPStatus{IngressIPs: []string{"10.1.0.1"}}, ips)
+}
@@ -0,0 +1,202 @@
+package staticlb
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+)
+
+// Topology limits publishing by zones and regions of nodes. Zones are read from the topology.kubernetes.io/zone
+// label of nodes, or from endpoints of EndpointSlices for Local policy, and regions from topology.kubernetes.io/region.
+type Topology struct {
+	// Zones and Regions, if not empty, publish only IPs of nodes in them.
+	Zones   []string
+	Regions []string
+	// MaxIPsPerZone, if positive, publishes at most that many IPs of each zone, in order of names of nodes and then
+	// IPs, so that IPs are spread over zones without publishing all of them. A zone that has fewer IPs publishes all
+	// of them; no zone is filled up to it.
+	MaxIPsPerZone int
+}
+
+// ArrangeByTopology drops IPs out of zones and regions of annotations or cfg, caps IPs of each zone, and orders IPs
+// zone by zone, in order of zone names. IPs of nodes without a zone come last. IPs of a zone are ordered by names of
+// their nodes and then by IPs, regardless of the order of nodeIPs. ips are IPs that are computed from nodeIPs.
+func ArrangeByTopology(ips IPStatus, nodeIPs NodeIPs, annotations map[string]string, cfg Config) (IPStatus, Trace) {
+	var trace Trace
+	zones := getStrings(annotations, LabelZones, cfg.Topology.Zones)
+	regions := getStrings(annotations, LabelRegions, cfg.Topology.Regions)
+	maxIPsPerZone := getNonNegativeInt(annotations, LabelMaxIPsPerZone, cfg.Topology.MaxIPsPerZone)
+
+	locations := locationsOf(nodeIPs, getNATRules(annotations, LabelNATRules, cfg.NATRules))
+	if len(zones) != 0 {
+		trace.add(StageTopology, "only IPs of zones %v are published (%s)", zones, sourceOf(annotations, LabelZones))
+	}
+	if len(regions) != 0 {
+		trace.add(StageTopology, "only IPs of regions %v are published (%s)", regions,
+			sourceOf(annotations, LabelRegions))
+	}
+	if maxIPsPerZone > 0 {
+		trace.add(StageTopology, "at most %d IPs of each zone are published (%s)", maxIPsPerZone,
+			sourceOf(annotations, LabelMaxIPsPerZone))
+	}
+
+	arrange := func(target IPMappingTarget, ips []string) []string {
+		byZone := make(map[string][]string)
+		for _, ip := range ips {
+			location := locations[canonicalIP(ip)]
+			if len(zones) != 0 && !contains(zones, location.zone) {
+				trace.add(StageTopology, "%s IP %s is dropped: zone %q is not included", target, ip, location.zone)
+				continue
+			}
+			if len(regions) != 0 && !contains(regions, location.region) {
+				trace.add(StageTopology, "%s IP %s is dropped: region %q is not included", target, ip,
+					location.region)
+				continue
+			}
+			byZone[location.zone] = append(byZone[location.zone], ip)
+		}
+
+		zoneNames := make([]string, 0, len(byZone))
+		for zone := range byZone {
+			zoneNames = append(zoneNames, zone)
+		}
+		sort.Slice(zoneNames, func(i, j int) bool {
+			// nodes without a zone come last
+			if zoneNames[i] == "" || zoneNames[j] == "" {
+				return zoneNames[j] == ""
+			}
+			return zoneNames[i] < zoneNames[j]
+		})
+
+		var result []string
+		for _, zone := range zoneNames {
+			zoneIPs := byZone[zone]
+			sortByNodeAndIP(zoneIPs, locations)
+			if maxIPsPerZone > 0 && len(zoneIPs) > maxIPsPerZone {
+				for _, ip := range zoneIPs[maxIPsPerZone:] {
+					trace.add(StageTopology, "%s IP %s is not published: zone %q has enough IPs", target, ip, zone)
+				}
+				zoneIPs = zoneIPs[:maxIPsPerZone]
+			}
+			result = append(result, zoneIPs...)
+		}
+		return result
+	}
+
+	return IPStatus{
+		IngressIPs:  arrange(IPMappingTargetIngress, ips.IngressIPs),
+		ExternalIPs: arrange(IPMappingTargetExternal, ips.ExternalIPs),
+	}, trace
+}
+
+// FillEndpointZones sets zones of nodes that have no zone label to the zone of their endpoints in endpointSlices.
+func FillEndpointZones(nodeIPs NodeIPs, endpointSlices []discoveryv1.EndpointSlice) NodeIPs {
+	zoneOfNode := make(map[string]string)
+	for _, endpointSlice := range endpointSlices {
+		for _, endpoint := range endpointSlice.Endpoints {
+			if endpoint.NodeName != nil && endpoint.Zone != nil && *endpoint.Zone != "" {
+				zoneOfNode[*endpoint.NodeName] = *endpoint.Zone
+			}
+		}
+	}
+
+	result := NodeIPs{Nodes: make([]NodeAddresses, len(nodeIPs.Nodes))}
+	for i, node := range nodeIPs.Nodes {
+		if node.Zone == "" {
+			node.Zone = zoneOfNode[node.Name]
+		}
+		result.Nodes[i] = node
+	}
+	return result
+}
+
+type location struct {
+	node   string
+	zone   string
+	region string
+}
+
+// sortByNodeAndIP sorts ips by names of their nodes in locations, and then by IPs.
+func sortByNodeAndIP(ips []string, locations map[string]location) {
+	sort.SliceStable(ips, func(i, j int) bool {
+		nodeI, nodeJ := locations[canonicalIP(ips[i])].node, locations[canonicalIP(ips[j])].node
+		if nodeI != nodeJ {
+			return nodeI < nodeJ
+		}
+		return bytes.Compare(net.ParseIP(ips[i]).To16(), net.ParseIP(ips[j]).To16()) < 0
+	})
+}
+
+// locationsOf returns the zone and the region of every address of nodeIPs, and of the address translated through
+// rules, by the canonical form of the address.
+func locationsOf(nodeIPs NodeIPs, rules []NATRule) map[string]location {
+	locations := make(map[string]location)
+	for _, node := range nodeIPs.Nodes {
+		loc := location{node: node.Name, zone: node.Zone, region: node.Region}
+		for _, addresses := range node.Addresses {
+			for _, address := range addresses {
+				ip := net.ParseIP(address)
+				if ip == nil {
+					continue
+				}
+				if _, exists := locations[ip.String()]; !exists {
+					locations[ip.String()] = loc
+				}
+				if translated, ok := translateIP(ip, rules); ok {
+					if _, exists := locations[translated.String()]; !exists {
+						locations[translated.String()] = loc
+					}
+				}
+			}
+		}
+	}
+	return locations
+}
+
+func canonicalIP(s string) string {
+	if ip := net.ParseIP(s); ip != nil {
+		return ip.String()
+	}
+	return s
+}
+
+func contains(ss []string, s string) bool {
+	for _, e := range ss {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+func getStrings(annotations map[string]string, annotationName string, defaultVal []string) []string {
+	val, exists := annotations[annotationName]
+	if !exists {
+		return defaultVal
+	}
+
+	var result []string
+	for _, s := range strings.Split(val, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func getNonNegativeInt(annotations map[string]string, annotationName string, defaultVal int) int {
+	val, exists := annotations[annotationName]
+	if !exists {
+		return defaultVal
+	}
+
+	i, err := strconv.Atoi(val)
+	if err != nil || i < 0 {
+		return defaultVal
+	}
+	return i
+}
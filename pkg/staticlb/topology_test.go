@@ -0,0 +1,127 @@
+package staticlb
+
+import (
+	"testing"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArrangeByTopology(t *testing.T) {
+	t.Parallel()
+
+	nodeIPs := NodeIPs{Nodes: []NodeAddresses{
+		{Name: "a", Zone: "zone-b", Region: "r1", Addresses: map[NodeAddressType][]string{
+			NodeAddressTypeExternal: {"1.1.1.1"},
+		}},
+		{Name: "b", Zone: "zone-a", Region: "r1", Addresses: map[NodeAddressType][]string{
+			NodeAddressTypeExternal: {"1.1.1.2"},
+		}},
+		{Name: "c", Zone: "zone-b", Region: "r1", Addresses: map[NodeAddressType][]string{
+			NodeAddressTypeExternal: {"1.1.1.3"},
+		}},
+		{Name: "d", Addresses: map[NodeAddressType][]string{
+			NodeAddressTypeExternal: {"1.1.1.4"},
+		}},
+		{Name: "e", Zone: "zone-c", Region: "r2", Addresses: map[NodeAddressType][]string{
+			NodeAddressTypeInternal: {"10.0.0.5", "10.0.0.12", "10.0.0.9"},
+		}},
+	}}
+	ips := IPStatus{IngressIPs: []string{"1.1.1.4", "1.1.1.1", "1.1.1.2", "1.1.1.3"}}
+
+	tests := []struct {
+		name        string
+		ips         IPStatus
+		annotations map[string]string
+		cfg         Config
+		expected    IPStatus
+	}{
+		{
+			name:     "zone by zone, unknown zone last",
+			ips:      ips,
+			expected: IPStatus{IngressIPs: []string{"1.1.1.2", "1.1.1.1", "1.1.1.3", "1.1.1.4"}},
+		},
+		{
+			name:     "zones of Config",
+			ips:      ips,
+			cfg:      Config{Topology: Topology{Zones: []string{"zone-b"}}},
+			expected: IPStatus{IngressIPs: []string{"1.1.1.1", "1.1.1.3"}},
+		},
+		{
+			name:        "zones of annotation override Config",
+			ips:         ips,
+			annotations: map[string]string{LabelZones: "zone-a, zone-c"},
+			cfg:         Config{Topology: Topology{Zones: []string{"zone-b"}}},
+			expected:    IPStatus{IngressIPs: []string{"1.1.1.2"}},
+		},
+		{
+			name:        "regions",
+			ips:         IPStatus{ExternalIPs: []string{"1.1.1.4", "10.0.0.5", "1.1.1.1"}},
+			annotations: map[string]string{LabelRegions: "r2"},
+			expected:    IPStatus{ExternalIPs: []string{"10.0.0.5"}},
+		},
+		{
+			name:        "max IPs per zone",
+			ips:         ips,
+			annotations: map[string]string{LabelMaxIPsPerZone: "1"},
+			expected:    IPStatus{IngressIPs: []string{"1.1.1.2", "1.1.1.1", "1.1.1.4"}},
+		},
+		{
+			name:        "max IPs per zone by names of nodes, regardless of the order of IPs",
+			ips:         IPStatus{IngressIPs: []string{"1.1.1.3", "1.1.1.1"}},
+			annotations: map[string]string{LabelMaxIPsPerZone: "1"},
+			expected:    IPStatus{IngressIPs: []string{"1.1.1.1"}},
+		},
+		{
+			name: "IPs of a zone by names of nodes, then IPs",
+			ips: IPStatus{
+				IngressIPs:  []string{"1.1.1.3", "1.1.1.1"},
+				ExternalIPs: []string{"10.0.0.12", "10.0.0.9", "10.0.0.5"},
+			},
+			expected: IPStatus{
+				IngressIPs:  []string{"1.1.1.1", "1.1.1.3"},
+				ExternalIPs: []string{"10.0.0.5", "10.0.0.9", "10.0.0.12"},
+			},
+		},
+		{
+			name:        "invalid max IPs per zone falls back to Config",
+			ips:         ips,
+			annotations: map[string]string{LabelMaxIPsPerZone: "-1"},
+			cfg:         Config{Topology: Topology{MaxIPsPerZone: 2}},
+			expected:    IPStatus{IngressIPs: []string{"1.1.1.2", "1.1.1.1", "1.1.1.3", "1.1.1.4"}},
+		},
+		{
+			name:        "translated IPs keep zones of nodes",
+			ips:         IPStatus{IngressIPs: []string{"192.168.0.5", "1.1.1.1"}},
+			annotations: map[string]string{LabelNATRules: "10.0.0.0/24=192.168.0.0/24", LabelZones: "zone-c"},
+			expected:    IPStatus{IngressIPs: []string{"192.168.0.5"}},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			result, _ := ArrangeByTopology(tc.ips, nodeIPs, tc.annotations, tc.cfg)
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestFillEndpointZones(t *testing.T) {
+	t.Parallel()
+
+	zone := "zone-a"
+	endpointSlice := newEndpointSlice("a", "b")
+	for i := range endpointSlice.Endpoints {
+		endpointSlice.Endpoints[i].Zone = &zone
+	}
+	nodeIPs := NodeIPs{Nodes: []NodeAddresses{{Name: "a", Zone: "zone-b"}, {Name: "b"}, {Name: "c"}}}
+
+	result := FillEndpointZones(nodeIPs, []discoveryv1.EndpointSlice{endpointSlice})
+
+	assert.Equal(t, NodeIPs{Nodes: []NodeAddresses{{Name: "a", Zone: "zone-b"}, {Name: "b", Zone: "zone-a"}, {Name: "c"}}},
+		result)
+	assert.Equal(t, []NodeAddresses{{Name: "a", Zone: "zone-b"}, {Name: "b"}, {Name: "c"}}, nodeIPs.Nodes)
+}
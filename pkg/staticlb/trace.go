@@ -0,0 +1,43 @@
+package staticlb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Step explains a decision that a stage of the pipeline made.
+type Step struct {
+	Stage   Stage
+	Message string
+}
+
+func (s Step) String() string {
+	return fmt.Sprintf("%s: %s", s.Stage, s.Message)
+}
+
+// Trace explains how IPs are computed, in the order of decisions.
+type Trace []Step
+
+// String formats t as a line per Step.
+func (t Trace) String() string {
+	var b strings.Builder
+	for _, step := range t {
+		b.WriteString(step.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func (t *Trace) add(stage Stage, format string, args ...interface{}) {
+	*t = append(*t, Step{Stage: stage, Message: fmt.Sprintf(format, args...)})
+}
+
+// sourceOf tells whether any of annotationNames overrides Config.
+func sourceOf(annotations map[string]string, annotationNames ...string) string {
+	for _, name := range annotationNames {
+		if _, exists := annotations[name]; exists {
+			return configSourceAnnotation
+		}
+	}
+	return configSourceDefault
+}
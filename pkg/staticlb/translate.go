@@ -0,0 +1,151 @@
+package staticlb
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ParseNATRule parses either "from-CIDR=to-CIDR" or "ip=ip".
+func ParseNATRule(s string) (NATRule, error) {
+	from, to, found := strings.Cut(strings.TrimSpace(s), "=")
+	if !found {
+		return NATRule{}, fmt.Errorf("NAT rule must be in form of from=to: %s", s)
+	}
+
+	fromNet, err := parseIPOrCIDR(from)
+	if err != nil {
+		return NATRule{}, err
+	}
+	toNet, err := parseIPOrCIDR(to)
+	if err != nil {
+		return NATRule{}, err
+	}
+
+	fromOnes, fromBits := fromNet.Mask.Size()
+	toOnes, toBits := toNet.Mask.Size()
+	if fromOnes != toOnes || fromBits != toBits {
+		return NATRule{}, fmt.Errorf("both sides of NAT rule must have the same IP family and prefix length: %s", s)
+	}
+
+	return NATRule{From: fromNet, To: toNet}, nil
+}
+
+func (r NATRule) String() string {
+	return fmt.Sprintf("%s=%s", r.From, r.To)
+}
+
+func parseIPOrCIDR(s string) (*net.IPNet, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		_, ipNet, err := net.ParseCIDR(s)
+		return ipNet, err
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address: %s", s)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(8*net.IPv4len, 8*net.IPv4len)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(8*net.IPv6len, 8*net.IPv6len)}, nil
+}
+
+func getNATRules(annotations map[string]string, annotationName string, defaultVal []NATRule) []NATRule {
+	val, exists := annotations[annotationName]
+	if !exists {
+		return defaultVal
+	}
+
+	splitted := strings.Split(strings.TrimSpace(val), ",")
+	rules := make([]NATRule, 0, len(splitted))
+	for _, s := range splitted {
+		rule, err := ParseNATRule(s)
+		if err != nil {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+func getBool(annotations map[string]string, annotationName string, defaultVal bool) bool {
+	switch annotations[annotationName] {
+	case "true":
+		return true
+	case "false":
+		return false
+	default:
+		return defaultVal
+	}
+}
+
+// TranslateIPs rewrites IPs through NAT rules of annotations or cfg. IPs that no rule matches are kept as is, or
+// dropped if configured.
+func TranslateIPs(targetIPs IPStatus, annotations map[string]string, cfg Config) (IPStatus, Trace) {
+	var trace Trace
+	rules := getNATRules(annotations, LabelNATRules, cfg.NATRules)
+	if len(rules) == 0 {
+		return targetIPs, trace
+	}
+	dropUntranslated := getBool(annotations, LabelDropUntranslatedIPs, cfg.DropUntranslatedIPs)
+
+	return IPStatus{
+		IngressIPs:  unparseIPs(translateIPs(ParseIPs(targetIPs.IngressIPs), rules, dropUntranslated, &trace)),
+		ExternalIPs: unparseIPs(translateIPs(ParseIPs(targetIPs.ExternalIPs), rules, dropUntranslated, &trace)),
+	}, trace
+}
+
+func translateIPs(src []net.IP, rules []NATRule, dropUntranslated bool, trace *Trace) (result []net.IP) {
+	for _, ip := range src {
+		translated, ok := translateIP(ip, rules)
+		switch {
+		case ok:
+			trace.add(StageTranslate, "%s is translated into %s", ip, translated)
+			result = append(result, translated)
+		case !dropUntranslated:
+			result = append(result, ip)
+		default:
+			trace.add(StageTranslate, "%s is dropped: no NAT rule matches", ip)
+		}
+	}
+	return result
+}
+
+// translateIP translates ip with the most specific rule that contains it.
+func translateIP(ip net.IP, rules []NATRule) (net.IP, bool) {
+	var matched *NATRule
+	matchedOnes := -1
+	for i, rule := range rules {
+		if !rule.From.Contains(ip) {
+			continue
+		}
+		if ones, _ := rule.From.Mask.Size(); ones > matchedOnes {
+			matched = &rules[i]
+			matchedOnes = ones
+		}
+	}
+	if matched == nil {
+		return nil, false
+	}
+
+	src := ip.To16()
+	to := matched.To.IP.To16()
+	mask := matched.To.Mask
+	if len(mask) == net.IPv4len {
+		// align the IPv4 mask with 16 bytes representation of IPv4 address
+		ones, _ := mask.Size()
+		mask = net.CIDRMask(8*(net.IPv6len-net.IPv4len)+ones, 8*net.IPv6len)
+	}
+
+	result := make(net.IP, net.IPv6len)
+	for i := range result {
+		result[i] = (to[i] & mask[i]) | (src[i] &^ mask[i])
+	}
+	if ip.To4() != nil {
+		return result.To4(), true
+	}
+	return result, true
+}
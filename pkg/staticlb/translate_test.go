@@ -0,0 +1,63 @@
+package staticlb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNATRule(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+		hasError bool
+	}{
+		{
+			name:     "IPv4 prefix",
+			value:    "10.0.1.0/24=203.0.113.0/24",
+			expected: "10.0.1.0/24=203.0.113.0/24",
+		},
+		{
+			name:     "IPv4 exact",
+			value:    "10.0.1.1=203.0.113.7",
+			expected: "10.0.1.1/32=203.0.113.7/32",
+		},
+		{
+			name:     "IPv6 prefix",
+			value:    "fd00:1::/64=2001:db8:1::/64",
+			expected: "fd00:1::/64=2001:db8:1::/64",
+		},
+		{
+			name:     "prefix length mismatch",
+			value:    "10.0.1.0/24=203.0.113.0/25",
+			hasError: true,
+		},
+		{
+			name:     "family mismatch",
+			value:    "10.0.1.1=2001:db8::1",
+			hasError: true,
+		},
+		{
+			name:     "no separator",
+			value:    "10.0.1.0/24",
+			hasError: true,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := ParseNATRule(tc.value)
+			if tc.hasError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, actual.String())
+		})
+	}
+}
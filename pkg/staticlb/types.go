@@ -0,0 +1,109 @@
+package staticlb
+
+import (
+	"net"
+)
+
+type IPStatus struct {
+	IngressIPs  []string
+	ExternalIPs []string
+}
+
+func (s IPStatus) IsEmpty() bool {
+	return len(s.IngressIPs) == 0 && len(s.ExternalIPs) == 0
+}
+
+// NodeIPs holds addresses of nodes, grouped by node.
+type NodeIPs struct {
+	Nodes []NodeAddresses
+}
+
+func (n NodeIPs) IsEmpty() bool {
+	for _, node := range n.Nodes {
+		for _, addresses := range node.Addresses {
+			if len(addresses) != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// IPsOf returns addresses of addressType from every node.
+func (n NodeIPs) IPsOf(addressType NodeAddressType) (ips []string) {
+	for _, node := range n.Nodes {
+		ips = append(ips, node.IPsOf(addressType)...)
+	}
+	return ips
+}
+
+type NodeAddresses struct {
+	Name string
+	// Zone and Region of the node, or empty if unknown.
+	Zone   string
+	Region string
+	// Addresses of the node by the type of AddressSource that reads them.
+	Addresses map[NodeAddressType][]string
+}
+
+func (n NodeAddresses) IPsOf(addressType NodeAddressType) []string {
+	return n.Addresses[addressType]
+}
+
+// IPMappingPriority assigns, for each node, addresses of the first type in Types that the node has to Target.
+type IPMappingPriority struct {
+	Target IPMappingTarget
+	Types  []NodeAddressType
+}
+
+// NATRule translates addresses in From into To, keeping host bits. Both networks have the same prefix length.
+type NATRule struct {
+	From *net.IPNet
+	To   *net.IPNet
+}
+
+// Config is the default configuration of the pipeline. Each field is overridden by the annotation of the same
+// meaning on the Service. The zero value maps nothing, so that no IP is published.
+type Config struct {
+	InternalIPMappings   []IPMappingTarget
+	ExternalIPMappings   []IPMappingTarget
+	IPMappingPriorities  []IPMappingPriority
+	NodeAddressSelection NodeAddressSelection
+
+	IncludeIngressIPNets  []*net.IPNet
+	IncludeExternalIPNets []*net.IPNet
+	ExcludeIngressIPNets  []*net.IPNet
+	ExcludeExternalIPNets []*net.IPNet
+
+	NATRules            []NATRule
+	DropUntranslatedIPs bool
+
+	// ReservedIPNets are dropped from both ingress and external IPs before any other filter, e.g. ClusterIPNets.
+	// No annotation overrides them.
+	ReservedIPNets []*net.IPNet
+
+	Topology Topology
+
+	// AddressSources read addresses of nodes. Every registered AddressSource is read if it is nil.
+	AddressSources AddressSources
+}
+
+func (c Config) addressSources() AddressSources {
+	if c.AddressSources == nil {
+		return RegisteredAddressSources()
+	}
+	return c.AddressSources
+}
+
+// Result is the outcome of Compute.
+type Result struct {
+	// Skipped is true if IPs of the Service are left as they are, e.g. for an unknown externalTrafficPolicy.
+	Skipped bool
+	// NodeIPs are addresses of nodes that receive traffic of the Service.
+	NodeIPs NodeIPs
+	// Candidates are NodeIPs mapped to ingress and external IPs, before translation and filtering.
+	Candidates IPStatus
+	// IPs are to be published, ordered zone by zone.
+	IPs   IPStatus
+	Trace Trace
+}